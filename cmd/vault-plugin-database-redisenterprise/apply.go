@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/apply"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+)
+
+// Apply reconciles the Redis ACLs, roles and database bindings in a cluster with a manifest
+func Apply(args []string) error {
+	flags := newFlagSet("apply", "Reconciles the Redis ACLs, roles and database role bindings in a Redis Enterprise cluster with a YAML or JSON manifest.\n"+
+		"The changes are shown before they are made, and only the objects in the manifest are changed.")
+
+	var cluster cluster
+	cluster.register(flags)
+	file := flags.String("file", "", "the manifest to apply")
+	dryRun := flags.Bool("dry-run", false, "show the changes without making them")
+	timeout := flags.Duration("timeout", 5*time.Minute, "how long to wait for the changes to be made")
+
+	if ok, err := parseFlags(flags, args); !ok {
+		return err
+	}
+	if *file == "" {
+		return errors.New("the manifest to apply must be set with -file")
+	}
+	if err := cluster.validate(); err != nil {
+		return err
+	}
+
+	manifest, err := apply.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(cluster.url, cluster.username, cluster.password)
+	defer func() { _ = client.Close() }()
+
+	changes, err := apply.Plan(ctx, client, manifest)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes, the cluster matches the manifest")
+		return nil
+	}
+
+	if err := apply.Describe(os.Stdout, changes); err != nil {
+		return err
+	}
+	fmt.Println()
+	if *dryRun {
+		fmt.Printf("%d changes would be made\n", len(changes))
+		return nil
+	}
+
+	applied, err := apply.Apply(ctx, client, changes)
+	fmt.Printf("%d of %d changes made\n", applied, len(changes))
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/bootstrap"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/plugin"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/database/helper/credsutil"
+)
+
+// Bootstrap creates a user for the plugin with only the management levels it needs, and prints the Vault configuration
+// which uses it
+func Bootstrap(args []string) error {
+	flags := newFlagSet("bootstrap", "Creates a user for the plugin with only the privileges it needs, using the credentials of a cluster administrator,\n"+
+		"and prints the Vault configuration for the plugin to use it.")
+
+	var cluster cluster
+	cluster.register(flags)
+	name := flags.String("name", "vault-plugin", "name of the user to create for the plugin")
+	email := flags.String("email", "", "email of the user to create, which it authenticates with on clusters requiring an email")
+	database := flags.String("database", "", "the database the plugin is configured for")
+	features := flags.String("features", "", "the features the plugin is configured with, e.g. acl_only")
+	config := flags.String("config", "", "name of the Vault database configuration to print (default redis-<database> or redis)")
+	timeout := flags.Duration("timeout", time.Minute, "how long to wait for the cluster API")
+
+	if ok, err := parseFlags(flags, args); !ok {
+		return err
+	}
+	if err := cluster.validate(); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("the name of the user to create must be set")
+	}
+	if *config == "" {
+		*config = strings.TrimSuffix("redis-"+*database, "-")
+	}
+
+	password, err := credsutil.RandomAlphaNumeric(32, true)
+	if err != nil {
+		return fmt.Errorf("cannot generate password: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(cluster.url, cluster.username, cluster.password)
+	defer func() { _ = client.Close() }()
+
+	provisioned, err := bootstrap.Provision(ctx, client, bootstrap.Account{
+		Name:       *name,
+		Email:      *email,
+		Password:   password,
+		Management: plugin.ManagementFor(*features),
+	})
+	if err != nil {
+		return err
+	}
+	for _, role := range provisioned.Roles {
+		if provisioned.Created(role) {
+			fmt.Printf("Created role '%s' with the management level '%s'\n", role.Name, role.Management)
+		} else {
+			fmt.Printf("Reused role '%s' with the management level '%s'\n", role.Name, role.Management)
+		}
+	}
+	fmt.Printf("Created user '%s'\n", provisioned.User.Name)
+
+	login := *name
+	if *email != "" {
+		login = *email
+	}
+	vaultConfig := map[string]interface{}{
+		"url":      cluster.url,
+		"username": login,
+		"password": password,
+		"database": *database,
+		"features": *features,
+	}
+
+	// The plugin checks the user has every privilege it needs when it is configured, so run the same check now
+	if err := verify(ctx, vaultConfig); err != nil {
+		if removeErr := bootstrap.Remove(context.TODO(), client, provisioned); removeErr != nil {
+			err = multierror.Append(err, removeErr)
+		}
+		return fmt.Errorf("the plugin cannot use user '%s', which has been removed: %w", *name, err)
+	}
+
+	fmt.Println()
+	fmt.Println("Configure Vault to use the user with:")
+	fmt.Println()
+	fmt.Printf("vault write database/config/%s \\\n", *config)
+	fmt.Printf("    plugin_name=redisenterprise-database-plugin \\\n")
+	for _, key := range []string{"url", "username", "password", "database", "features"} {
+		if value := vaultConfig[key].(string); value != "" {
+			fmt.Printf("    %s=%q \\\n", key, value)
+		}
+	}
+	fmt.Printf("    allowed_roles=\"*\"\n")
+	fmt.Println()
+	fmt.Println("Then rotate the password, so only Vault knows it:")
+	fmt.Println()
+	fmt.Printf("vault write -f database/rotate-root/%s\n", *config)
+
+	return nil
+}
+
+func verify(ctx context.Context, config map[string]interface{}) error {
+	db := plugin.NewWithLogger(hclog.NewNullLogger())
+	defer func() { _ = db.Close() }()
+
+	_, err := db.Initialize(ctx, dbplugin.InitializeRequest{Config: config, VerifyConnection: true})
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cluster is the cluster API the subcommands connect to
+type cluster struct {
+	url      string
+	username string
+	password string
+}
+
+func (c *cluster) register(flags *flag.FlagSet) {
+	flags.StringVar(&c.url, "url", os.Getenv("RS_API_URL"), "URL of the cluster API, e.g. https://localhost:9443 (default $RS_API_URL)")
+	flags.StringVar(&c.username, "username", os.Getenv("RS_USERNAME"), "user to authenticate to the cluster API with (default $RS_USERNAME)")
+	flags.StringVar(&c.password, "password", os.Getenv("RS_PASSWORD"), "password of the user (default $RS_PASSWORD)")
+}
+
+func (c *cluster) validate() error {
+	if c.url == "" || c.username == "" || c.password == "" {
+		return errors.New("the url, username and password of the cluster API must be set")
+	}
+	return nil
+}
+
+// newFlagSet creates the flags of a subcommand, with usage describing what it does
+func newFlagSet(name string, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage: %s %s [options]\n\n", os.Args[0], name)
+		_, _ = fmt.Fprintln(flags.Output(), description)
+		_, _ = fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the arguments of a subcommand, returning false if only the usage was asked for
+func parseFlags(flags *flag.FlagSet, args []string) (bool, error) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return false, nil
+		}
+		return false, err
+	}
+	if flags.NArg() > 0 {
+		return false, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	return true, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/inventory"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+)
+
+// Inventory lists the users and roles in a cluster that were generated by the plugin
+func Inventory(args []string) error {
+	flags := newFlagSet("inventory", "Lists the users and roles in a Redis Enterprise cluster that were generated by the plugin.")
+
+	var cluster cluster
+	cluster.register(flags)
+	database := flags.String("database", "", "only list the users and roles bound to the database")
+	format := flags.String("format", inventory.FormatTable, "output format, one of "+strings.Join(inventory.Formats, ", "))
+	timeout := flags.Duration("timeout", time.Minute, "how long to wait for the cluster API")
+
+	if ok, err := parseFlags(flags, args); !ok {
+		return err
+	}
+	if !contains(inventory.Formats, *format) {
+		return fmt.Errorf("unknown format '%s', must be one of %s", *format, strings.Join(inventory.Formats, ", "))
+	}
+	if err := cluster.validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	items, err := collect(ctx, cluster, *database)
+	if err != nil {
+		return err
+	}
+
+	return inventory.Write(os.Stdout, *format, items)
+}
+
+func collect(ctx context.Context, cluster cluster, database string) ([]inventory.Item, error) {
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(cluster.url, cluster.username, cluster.password)
+	defer func() { _ = client.Close() }()
+
+	return inventory.Collect(ctx, client, database)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
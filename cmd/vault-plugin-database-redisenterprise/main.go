@@ -8,8 +8,25 @@ import (
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
 )
 
+// commands are run instead of serving the plugin when their name is the first argument. Any other arguments are left
+// for the plugin, as Vault may pass its own.
+var commands = map[string]func(args []string) error{
+	"apply":     Apply,
+	"bootstrap": Bootstrap,
+	"inventory": Inventory,
+	"purge":     Purge,
+	"validate":  Validate,
+}
+
 func main() {
-	if err := Run(); err != nil {
+	run := func() error { return Run() }
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			run = func() error { return command(os.Args[2:]) }
+		}
+	}
+
+	if err := run(); err != nil {
 		log.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/inventory"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/plugin"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+// Purge deletes the users generated by the plugin that match the selection, without going through Vault
+func Purge(args []string) error {
+	flags := newFlagSet("purge", "Deletes the users generated by the plugin, along with the roles generated for them, without going through Vault.\n"+
+		"The leases of the users in Vault are not revoked, and revoking them later succeeds as the users no longer exist.")
+
+	var cluster cluster
+	cluster.register(flags)
+	database := flags.String("database", "", "only delete the users bound to the database")
+	roleName := flags.String("role", "", "only delete the users whose Vault role or display name contains the value")
+	olderThan := flags.Duration("older-than", 0, "only delete the users created longer ago than the duration, e.g. 24h")
+	all := flags.Bool("all", false, "delete every user generated by the plugin, if no other selection is made")
+	features := flags.String("features", "", "the features the plugin is configured with, e.g. authorized_subjects (acl_only is always enabled for a database)")
+	concurrency := flags.Int("concurrency", 4, "how many users to delete at once")
+	dryRun := flags.Bool("dry-run", false, "list the users that would be deleted without deleting them")
+	timeout := flags.Duration("timeout", 10*time.Minute, "how long to wait for the purge to complete")
+
+	if ok, err := parseFlags(flags, args); !ok {
+		return err
+	}
+	if err := cluster.validate(); err != nil {
+		return err
+	}
+	if *database == "" && *roleName == "" && *olderThan == 0 && !*all {
+		return errors.New("select the users to delete with -database, -role or -older-than, or use -all to delete every user")
+	}
+	if *concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	items, err := collect(ctx, cluster, *database)
+	if err != nil {
+		return err
+	}
+	targets := inventory.Select(items, inventory.Selector{RoleName: *roleName, OlderThan: *olderThan, Now: time.Now()})
+
+	if *dryRun {
+		if err := writeTargets(targets); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d users would be deleted\n", len(targets))
+		return nil
+	}
+
+	deleters := newDeleters(cluster, *database, *features)
+	defer deleters.close()
+
+	results := inventory.Purge(ctx, targets, *concurrency, deleters.deleteUser)
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("failed  %s: %s\n", result.Target.Username, result.Err)
+		} else {
+			fmt.Printf("deleted %s\n", result.Target.Username)
+		}
+	}
+	fmt.Printf("\n%d of %d users deleted, %d failed\n", len(results)-failed, len(results), failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d users could not be deleted, run the purge again to retry them", failed)
+	}
+	return nil
+}
+
+func writeTargets(targets []inventory.Target) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "USERNAME\tCREATED\tGENERATED ROLE DATABASE")
+	for _, target := range targets {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", target.Username, target.Created.Format(time.RFC3339), target.Database)
+	}
+	return writer.Flush()
+}
+
+// deleters delete users with an instance of the plugin for each database, so users are deleted the same way as when
+// their leases are revoked by Vault, including the roles generated for them.
+type deleters struct {
+	cluster  cluster
+	database string
+	features string
+
+	lock      sync.Mutex
+	instances map[string]*deleter
+}
+
+type deleter struct {
+	once sync.Once
+	db   dbplugin.Database
+	err  error
+}
+
+func newDeleters(cluster cluster, database string, features string) *deleters {
+	return &deleters{cluster: cluster, database: database, features: features, instances: map[string]*deleter{}}
+}
+
+func (d *deleters) deleteUser(ctx context.Context, target inventory.Target) error {
+	database := target.Database
+	if database == "" {
+		database = d.database
+	}
+
+	db, err := d.instance(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: target.Username})
+	return err
+}
+
+// instance returns the plugin configured for the database, initialising it the first time it is used
+func (d *deleters) instance(ctx context.Context, database string) (dbplugin.Database, error) {
+	d.lock.Lock()
+	instance, ok := d.instances[database]
+	if !ok {
+		instance = &deleter{}
+		d.instances[database] = instance
+	}
+	d.lock.Unlock()
+
+	instance.once.Do(func() {
+		features := d.features
+		if database != "" {
+			features = strings.TrimPrefix(features+",acl_only", ",")
+		}
+
+		logger := hclog.New(&hclog.LoggerOptions{Level: hclog.Warn, Output: os.Stderr})
+		db := plugin.NewWithLogger(logger)
+		_, err := db.Initialize(ctx, dbplugin.InitializeRequest{
+			Config: map[string]interface{}{
+				"url":      d.cluster.url,
+				"username": d.cluster.username,
+				"password": d.cluster.password,
+				"database": database,
+				"features": features,
+			},
+			VerifyConnection: true,
+		})
+		if err != nil {
+			instance.err = fmt.Errorf("cannot initialise the plugin for database '%s': %w", database, err)
+			return
+		}
+		instance.db = db
+	})
+
+	return instance.db, instance.err
+}
+
+func (d *deleters) close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, instance := range d.instances {
+		if instance.db != nil {
+			_ = instance.db.Close()
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/plugin"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+// Validate checks a creation statement can be used with a configuration of the plugin, without creating anything in
+// the cluster
+func Validate(args []string) error {
+	flags := newFlagSet("validate", "Checks a creation statement can be used with a configuration of the plugin, making every check the plugin makes\n"+
+		"when a user is created, without creating anything in the cluster. All the problems which are found are listed.")
+
+	var cluster cluster
+	cluster.register(flags)
+	backend := flags.String("backend", "", "the backend the plugin is configured with: enterprise, cloud or redis (default enterprise)")
+	database := flags.String("database", "", "the database the plugin is configured for")
+	features := flags.String("features", "", "the features the plugin is configured with, e.g. acl_only")
+	statement := flags.String("statement", "", "the creation statement, or @file to read it from a file")
+	roleName := flags.String("role-name", "validate", "name of the Vault role the creation statement is for, used in the messages")
+	credentialType := flags.String("credential-type", dbplugin.CredentialTypePassword.String(), "the type of credential Vault requests users with: password or client_certificate")
+	subject := flags.String("subject", "CN=validate", "the subject of the client certificate, with the client_certificate credential type")
+	timeout := flags.Duration("timeout", time.Minute, "how long to wait for the cluster API")
+
+	if ok, err := parseFlags(flags, args); !ok {
+		return err
+	}
+	if err := cluster.validate(); err != nil {
+		return err
+	}
+	if *statement == "" {
+		return errors.New("the creation statement must be set")
+	}
+	if path, ok := strings.CutPrefix(*statement, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read creation statement: %w", err)
+		}
+		*statement = string(data)
+	}
+
+	req := dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{DisplayName: "validate", RoleName: *roleName},
+		Statements:     dbplugin.Statements{Commands: []string{*statement}},
+		Expiration:     time.Now().Add(time.Hour),
+	}
+	switch *credentialType {
+	case dbplugin.CredentialTypePassword.String():
+		req.CredentialType = dbplugin.CredentialTypePassword
+		req.Password = "validate"
+	case dbplugin.CredentialTypeClientCertificate.String():
+		req.CredentialType = dbplugin.CredentialTypeClientCertificate
+		req.Subject = *subject
+	default:
+		return fmt.Errorf("unknown credential type '%s'", *credentialType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	config := map[string]interface{}{
+		"url":      cluster.url,
+		"username": cluster.username,
+		"password": cluster.password,
+		"backend":  *backend,
+		"database": *database,
+		"features": *features,
+	}
+	if err := plugin.Validate(ctx, hclog.NewNullLogger(), config, req); err != nil {
+		return err
+	}
+
+	fmt.Println("The creation statement is valid")
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package apply reconciles the Redis ACLs, roles and database role bindings that creation statements refer to with a
+// manifest, so they can be kept in version control rather than created by hand.
+//
+// Only the objects in the manifest are managed. Redis ACLs and roles which are not in the manifest are left alone, and
+// only the bindings of the roles in the manifest are changed in the databases it lists.
+package apply
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired state of the objects it manages. It is read from YAML, or JSON which is also valid YAML.
+type Manifest struct {
+	ACLs      []ACL      `yaml:"redis_acls"`
+	Roles     []Role     `yaml:"roles"`
+	Databases []Database `yaml:"databases"`
+}
+
+// ACL is a Redis ACL. An absent ACL is deleted if it exists.
+type ACL struct {
+	Name   string `yaml:"name"`
+	ACL    string `yaml:"acl"`
+	Absent bool   `yaml:"absent"`
+}
+
+// Role is a role with a management level. An absent role is deleted if it exists, along with its bindings.
+type Role struct {
+	Name       string `yaml:"name"`
+	Management string `yaml:"management"`
+	Absent     bool   `yaml:"absent"`
+}
+
+// Database is the bindings of the roles in the manifest to Redis ACLs in a database. Any binding of a role in the
+// manifest which is not listed is removed from the database.
+type Database struct {
+	Name     string    `yaml:"name"`
+	Bindings []Binding `yaml:"bindings"`
+}
+
+// Binding binds a role in the manifest to a Redis ACL, which is either in the manifest or already in the cluster.
+type Binding struct {
+	Role string `yaml:"role"`
+	ACL  string `yaml:"acl"`
+}
+
+// managementLevels are the management levels a role can have.
+var managementLevels = []string{"admin", "cluster_member", "cluster_viewer", "db_member", "db_viewer", "user_manager", "none"}
+
+// Load reads and validates the manifest in the file.
+func Load(path string) (Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	manifest, err := Parse(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Parse reads and validates a manifest.
+func Parse(r io.Reader) (Manifest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return Manifest{}, err
+	}
+
+	if err := manifest.validate(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+func (m Manifest) validate() error {
+	var problems []string
+
+	acls := map[string]ACL{}
+	for _, acl := range m.ACLs {
+		switch {
+		case acl.Name == "":
+			problems = append(problems, "a redis_acl has no name")
+		case acls[acl.Name].Name != "":
+			problems = append(problems, fmt.Sprintf("redis_acl '%s' is listed more than once", acl.Name))
+		case !acl.Absent && acl.ACL == "":
+			problems = append(problems, fmt.Sprintf("redis_acl '%s' has no acl", acl.Name))
+		}
+		acls[acl.Name] = acl
+	}
+
+	roles := map[string]Role{}
+	for _, role := range m.Roles {
+		switch {
+		case role.Name == "":
+			problems = append(problems, "a role has no name")
+		case roles[role.Name].Name != "":
+			problems = append(problems, fmt.Sprintf("role '%s' is listed more than once", role.Name))
+		case !role.Absent && !contains(managementLevels, role.Management):
+			problems = append(problems, fmt.Sprintf("role '%s' has the management level '%s', which is not one of %s", role.Name, role.Management, strings.Join(managementLevels, ", ")))
+		}
+		roles[role.Name] = role
+	}
+
+	databases := map[string]bool{}
+	for _, db := range m.Databases {
+		if db.Name == "" {
+			problems = append(problems, "a database has no name")
+			continue
+		}
+		if databases[db.Name] {
+			problems = append(problems, fmt.Sprintf("database '%s' is listed more than once", db.Name))
+		}
+		databases[db.Name] = true
+
+		bound := map[string]bool{}
+		for _, binding := range db.Bindings {
+			role, ok := roles[binding.Role]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("database '%s' binds role '%s', which is not in the manifest", db.Name, binding.Role))
+			case role.Absent:
+				problems = append(problems, fmt.Sprintf("database '%s' binds role '%s', which is absent", db.Name, binding.Role))
+			case bound[binding.Role]:
+				problems = append(problems, fmt.Sprintf("database '%s' binds role '%s' more than once", db.Name, binding.Role))
+			}
+			bound[binding.Role] = true
+
+			if binding.ACL == "" {
+				problems = append(problems, fmt.Sprintf("database '%s' binds role '%s' to no redis_acl", db.Name, binding.Role))
+			} else if acls[binding.ACL].Absent {
+				problems = append(problems, fmt.Sprintf("database '%s' binds role '%s' to redis_acl '%s', which is absent", db.Name, binding.Role, binding.ACL))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("\n\t* %s", strings.Join(problems, "\n\t* "))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package apply
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	expected := Manifest{
+		ACLs:      []ACL{{Name: "Cache Read", ACL: "+@read ~cache:*"}},
+		Roles:     []Role{{Name: "cache-reader", Management: "db_member"}, {Name: "old", Absent: true}},
+		Databases: []Database{{Name: "mydb", Bindings: []Binding{{Role: "cache-reader", ACL: "Cache Read"}}}},
+	}
+
+	for name, document := range map[string]string{
+		"yaml": `
+redis_acls:
+  - name: Cache Read
+    acl: "+@read ~cache:*"
+roles:
+  - name: cache-reader
+    management: db_member
+  - name: old
+    absent: true
+databases:
+  - name: mydb
+    bindings:
+      - role: cache-reader
+        acl: Cache Read
+`,
+		"json": `{
+  "redis_acls": [{"name": "Cache Read", "acl": "+@read ~cache:*"}],
+  "roles": [{"name": "cache-reader", "management": "db_member"}, {"name": "old", "absent": true}],
+  "databases": [{"name": "mydb", "bindings": [{"role": "cache-reader", "acl": "Cache Read"}]}]
+}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			manifest, err := Parse(strings.NewReader(document))
+			require.NoError(t, err)
+			assert.Equal(t, expected, manifest)
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, spec := range []struct {
+		name     string
+		document string
+		message  string
+	}{
+		{name: "unknown field", document: "users: []", message: "field users not found"},
+		{name: "no acl", document: "redis_acls: [{name: a}]", message: "redis_acl 'a' has no acl"},
+		{name: "duplicate acl", document: "redis_acls: [{name: a, acl: '+@all'}, {name: a, acl: '+@read'}]", message: "redis_acl 'a' is listed more than once"},
+		{name: "management", document: "roles: [{name: r, management: superuser}]", message: "role 'r' has the management level 'superuser'"},
+		{name: "unmanaged role", document: "databases: [{name: mydb, bindings: [{role: r, acl: a}]}]", message: "binds role 'r', which is not in the manifest"},
+		{name: "absent role", document: "roles: [{name: r, absent: true}]\ndatabases: [{name: mydb, bindings: [{role: r, acl: a}]}]", message: "binds role 'r', which is absent"},
+		{name: "absent acl", document: "redis_acls: [{name: a, absent: true}]\nroles: [{name: r, management: db_member}]\ndatabases: [{name: mydb, bindings: [{role: r, acl: a}]}]", message: "redis_acl 'a', which is absent"},
+		{name: "role bound twice", document: "roles: [{name: r, management: db_member}]\ndatabases: [{name: mydb, bindings: [{role: r, acl: a}, {role: r, acl: b}]}]", message: "binds role 'r' more than once"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(spec.document))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+// Client is the part of the cluster API the manifest is applied with.
+type Client interface {
+	ListACLs(ctx context.Context) ([]sdk.ACL, error)
+	CreateACL(ctx context.Context, create sdk.CreateACL) (sdk.ACL, error)
+	UpdateACL(ctx context.Context, id int, update sdk.UpdateACL) error
+	DeleteACL(ctx context.Context, id int) error
+	ListRoles(ctx context.Context) ([]sdk.Role, error)
+	CreateRole(ctx context.Context, create sdk.CreateRole) (sdk.Role, error)
+	UpdateRole(ctx context.Context, id int, update sdk.UpdateRole) error
+	DeleteRole(ctx context.Context, id int) error
+	ListDatabases(ctx context.Context) ([]sdk.Database, error)
+	UpdateDatabaseRolePermissions(ctx context.Context, id int, update func([]sdk.RolePermission) []sdk.RolePermission) ([]sdk.RolePermission, []sdk.RolePermission, error)
+}
+
+// Change is a change to an object in the cluster needed to reconcile it with the manifest.
+type Change struct {
+	Action string
+	Kind   string
+	Name   string
+
+	// Details describe what changes, one per line
+	Details []string
+
+	apply func(ctx context.Context, client Client, uids *uids) error
+}
+
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+var actionSymbols = map[string]string{actionCreate: "+", actionUpdate: "~", actionDelete: "-"}
+
+// uids are the UIDs of the Redis ACLs and roles by name, as they are created while the changes are applied.
+type uids struct {
+	acls  map[string]int
+	roles map[string]int
+}
+
+// Plan returns the changes needed to reconcile the cluster with the manifest, in the order they need to be applied:
+// Redis ACLs and roles are created or updated before databases are bound to them, and deleted afterwards.
+func Plan(ctx context.Context, client Client, manifest Manifest) ([]Change, error) {
+	acls, err := client.ListACLs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list ACLs: %w", err)
+	}
+	roles, err := client.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list roles: %w", err)
+	}
+	databases, err := client.ListDatabases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list databases: %w", err)
+	}
+
+	existingACLs := map[string]sdk.ACL{}
+	aclNames := map[int]string{}
+	for _, acl := range acls {
+		existingACLs[acl.Name] = acl
+		aclNames[acl.UID] = acl.Name
+	}
+	existingRoles := map[string]sdk.Role{}
+	roleNames := map[int]string{}
+	for _, role := range roles {
+		existingRoles[role.Name] = role
+		roleNames[role.UID] = role.Name
+	}
+	existingDatabases := map[string]sdk.Database{}
+	for _, db := range databases {
+		existingDatabases[db.Name] = db
+	}
+
+	var changes, roleDeletions, aclDeletions []Change
+
+	for _, acl := range manifest.ACLs {
+		existing, exists := existingACLs[acl.Name]
+		switch {
+		case acl.Absent && exists:
+			aclDeletions = append(aclDeletions, Change{Action: actionDelete, Kind: "redis_acl", Name: acl.Name,
+				apply: func(ctx context.Context, client Client, _ *uids) error {
+					return client.DeleteACL(ctx, existing.UID)
+				}})
+		case acl.Absent:
+		case !exists:
+			changes = append(changes, Change{Action: actionCreate, Kind: "redis_acl", Name: acl.Name,
+				Details: []string{fmt.Sprintf("acl: %q", acl.ACL)},
+				apply: func(ctx context.Context, client Client, uids *uids) error {
+					created, err := client.CreateACL(ctx, sdk.CreateACL{Name: acl.Name, ACL: acl.ACL})
+					if err != nil {
+						return err
+					}
+					uids.acls[acl.Name] = created.UID
+					return nil
+				}})
+		case existing.ACL != acl.ACL:
+			changes = append(changes, Change{Action: actionUpdate, Kind: "redis_acl", Name: acl.Name,
+				Details: []string{fmt.Sprintf("acl: %q -> %q", existing.ACL, acl.ACL)},
+				apply: func(ctx context.Context, client Client, _ *uids) error {
+					return client.UpdateACL(ctx, existing.UID, sdk.UpdateACL{ACL: acl.ACL})
+				}})
+		}
+	}
+
+	for _, role := range manifest.Roles {
+		existing, exists := existingRoles[role.Name]
+		switch {
+		case role.Absent && exists:
+			// Deleting the role also removes its bindings, so it has to happen before the Redis ACLs are deleted
+			roleDeletions = append(roleDeletions, Change{Action: actionDelete, Kind: "role", Name: role.Name,
+				Details: []string{"its bindings are removed from every database"},
+				apply: func(ctx context.Context, client Client, _ *uids) error {
+					return client.DeleteRole(ctx, existing.UID)
+				}})
+		case role.Absent:
+		case !exists:
+			changes = append(changes, Change{Action: actionCreate, Kind: "role", Name: role.Name,
+				Details: []string{fmt.Sprintf("management: %q", role.Management)},
+				apply: func(ctx context.Context, client Client, uids *uids) error {
+					created, err := client.CreateRole(ctx, sdk.CreateRole{Name: role.Name, Management: role.Management})
+					if err != nil {
+						return err
+					}
+					uids.roles[role.Name] = created.UID
+					return nil
+				}})
+		case existing.Management != role.Management:
+			changes = append(changes, Change{Action: actionUpdate, Kind: "role", Name: role.Name,
+				Details: []string{fmt.Sprintf("management: %q -> %q", existing.Management, role.Management)},
+				apply: func(ctx context.Context, client Client, _ *uids) error {
+					return client.UpdateRole(ctx, existing.UID, sdk.UpdateRole{Management: role.Management})
+				}})
+		}
+	}
+
+	owned := map[string]bool{}
+	for _, role := range manifest.Roles {
+		if !role.Absent {
+			owned[role.Name] = true
+		}
+	}
+
+	for _, desired := range manifest.Databases {
+		db, ok := existingDatabases[desired.Name]
+		if !ok {
+			return nil, fmt.Errorf("database '%s' does not exist", desired.Name)
+		}
+		if db.CRDT {
+			return nil, fmt.Errorf("the bindings of Active-Active database '%s' cannot be applied", db.Name)
+		}
+
+		for _, binding := range desired.Bindings {
+			if _, ok := existingACLs[binding.ACL]; !ok && !inManifest(manifest, binding.ACL) {
+				return nil, fmt.Errorf("database '%s' binds role '%s' to redis_acl '%s', which does not exist", db.Name, binding.Role, binding.ACL)
+			}
+		}
+
+		current := map[string]string{}
+		for _, permission := range db.RolePermissions {
+			if name := roleNames[permission.RoleUID]; owned[name] {
+				current[name] = aclNames[permission.ACLUID]
+			}
+		}
+		wanted := map[string]string{}
+		for _, binding := range desired.Bindings {
+			wanted[binding.Role] = binding.ACL
+		}
+
+		var details []string
+		for _, role := range sortedKeys(current, wanted) {
+			from, bound := current[role]
+			to, wants := wanted[role]
+			switch {
+			case bound && !wants:
+				details = append(details, fmt.Sprintf("- %s -> %s", role, from))
+			case !bound && wants:
+				details = append(details, fmt.Sprintf("+ %s -> %s", role, to))
+			case from != to:
+				details = append(details, fmt.Sprintf("~ %s -> %s (was %s)", role, to, from))
+			}
+		}
+		if len(details) == 0 {
+			continue
+		}
+
+		uid := db.UID
+		changes = append(changes, Change{Action: actionUpdate, Kind: "database", Name: db.Name, Details: details,
+			apply: func(ctx context.Context, client Client, uids *uids) error {
+				owned := map[int]bool{}
+				for name := range wanted {
+					owned[uids.roles[name]] = true
+				}
+				for name := range current {
+					owned[uids.roles[name]] = true
+				}
+
+				_, _, err := client.UpdateDatabaseRolePermissions(ctx, uid, func(permissions []sdk.RolePermission) []sdk.RolePermission {
+					updated := []sdk.RolePermission{}
+					for _, permission := range permissions {
+						if !owned[permission.RoleUID] {
+							updated = append(updated, permission)
+						}
+					}
+					for _, role := range sortedKeys(wanted) {
+						updated = append(updated, sdk.RolePermission{RoleUID: uids.roles[role], ACLUID: uids.acls[wanted[role]]})
+					}
+					return updated
+				})
+				return err
+			}})
+	}
+
+	changes = append(changes, roleDeletions...)
+	return append(changes, aclDeletions...), nil
+}
+
+// Apply makes the changes in order, stopping at the first which fails. It returns how many changes were made.
+func Apply(ctx context.Context, client Client, changes []Change) (int, error) {
+	acls, err := client.ListACLs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cannot list ACLs: %w", err)
+	}
+	roles, err := client.ListRoles(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cannot list roles: %w", err)
+	}
+
+	ids := &uids{acls: map[string]int{}, roles: map[string]int{}}
+	for _, acl := range acls {
+		ids.acls[acl.Name] = acl.UID
+	}
+	for _, role := range roles {
+		ids.roles[role.Name] = role.UID
+	}
+
+	for i, change := range changes {
+		if err := change.apply(ctx, client, ids); err != nil {
+			return i, fmt.Errorf("cannot %s %s '%s': %w", change.Action, change.Kind, change.Name, err)
+		}
+	}
+	return len(changes), nil
+}
+
+// Describe writes the changes as a diff.
+func Describe(w io.Writer, changes []Change) error {
+	for _, change := range changes {
+		if _, err := fmt.Fprintf(w, "%s %s '%s'\n", actionSymbols[change.Action], change.Kind, change.Name); err != nil {
+			return err
+		}
+		for _, detail := range change.Details {
+			if _, err := fmt.Fprintf(w, "    %s\n", detail); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func inManifest(manifest Manifest, aclName string) bool {
+	for _, acl := range manifest.ACLs {
+		if acl.Name == aclName && !acl.Absent {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(maps ...map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
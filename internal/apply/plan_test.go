@@ -0,0 +1,160 @@
+package apply
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	cluster, client := fakeCluster(t)
+	ctx := context.Background()
+
+	fullAccess := cluster.AddACL("Full Access", "+@all ~*")
+	cacheRead := cluster.AddACL("Cache Read", "+@read ~*")
+	cluster.AddACL("Retired", "+@all")
+	unmanaged := cluster.AddRole("DB Member", "db_member")
+	writer := cluster.AddRole("cache-writer", "db_viewer")
+	cluster.AddRole("old", "db_member")
+	db := cluster.AddDatabase("mydb",
+		fake.RolePermission{RoleUID: unmanaged, ACLUID: fullAccess},
+		fake.RolePermission{RoleUID: writer, ACLUID: cacheRead},
+	)
+
+	manifest := Manifest{
+		ACLs: []ACL{
+			{Name: "Cache Read", ACL: "+@read ~cache:*"},
+			{Name: "Cache Write", ACL: "+@write ~cache:*"},
+			{Name: "Retired", Absent: true},
+		},
+		Roles: []Role{
+			{Name: "cache-reader", Management: "db_member"},
+			{Name: "cache-writer", Management: "db_member"},
+			{Name: "old", Absent: true},
+		},
+		Databases: []Database{{Name: "mydb", Bindings: []Binding{
+			{Role: "cache-reader", ACL: "Cache Read"},
+			{Role: "cache-writer", ACL: "Cache Write"},
+		}}},
+	}
+
+	changes, err := Plan(ctx, client, manifest)
+	require.NoError(t, err)
+
+	var diff bytes.Buffer
+	require.NoError(t, Describe(&diff, changes))
+	assert.Equal(t, `~ redis_acl 'Cache Read'
+    acl: "+@read ~*" -> "+@read ~cache:*"
++ redis_acl 'Cache Write'
+    acl: "+@write ~cache:*"
++ role 'cache-reader'
+    management: "db_member"
+~ role 'cache-writer'
+    management: "db_viewer" -> "db_member"
+~ database 'mydb'
+    + cache-reader -> Cache Read
+    ~ cache-writer -> Cache Write (was Cache Read)
+- role 'old'
+    its bindings are removed from every database
+- redis_acl 'Retired'
+`, diff.String())
+
+	applied, err := Apply(ctx, client, changes)
+	require.NoError(t, err)
+	assert.Equal(t, len(changes), applied)
+
+	// The cluster now matches the manifest, so there is nothing left to change
+	changes, err = Plan(ctx, client, manifest)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// The binding of the role which is not in the manifest is left alone
+	database, ok := cluster.Database(db)
+	require.True(t, ok)
+	require.Len(t, database.RolePermissions, 3)
+	assert.Equal(t, fake.RolePermission{RoleUID: unmanaged, ACLUID: fullAccess}, database.RolePermissions[0])
+
+	var names []string
+	for _, role := range cluster.Roles() {
+		names = append(names, role.Name)
+	}
+	assert.ElementsMatch(t, []string{"DB Member", "cache-writer", "cache-reader"}, names)
+	assert.Len(t, cluster.ACLs(), 3)
+}
+
+func TestApply_removesBindings(t *testing.T) {
+	cluster, client := fakeCluster(t)
+	ctx := context.Background()
+
+	acl := cluster.AddACL("Cache Read", "+@read ~cache:*")
+	role := cluster.AddRole("cache-reader", "db_member")
+	db := cluster.AddDatabase("mydb", fake.RolePermission{RoleUID: role, ACLUID: acl})
+
+	changes, err := Plan(ctx, client, Manifest{
+		Roles:     []Role{{Name: "cache-reader", Management: "db_member"}},
+		Databases: []Database{{Name: "mydb"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, []string{"- cache-reader -> Cache Read"}, changes[0].Details)
+
+	_, err = Apply(ctx, client, changes)
+	require.NoError(t, err)
+
+	database, ok := cluster.Database(db)
+	require.True(t, ok)
+	assert.Empty(t, database.RolePermissions)
+}
+
+func TestPlan_invalid(t *testing.T) {
+	cluster, client := fakeCluster(t)
+	cluster.AddDatabase("mydb")
+
+	for _, spec := range []struct {
+		name     string
+		manifest Manifest
+		message  string
+	}{
+		{
+			name:     "unknown database",
+			manifest: Manifest{Databases: []Database{{Name: "other"}}},
+			message:  "database 'other' does not exist",
+		},
+		{
+			name: "unknown acl",
+			manifest: Manifest{
+				Roles:     []Role{{Name: "r", Management: "db_member"}},
+				Databases: []Database{{Name: "mydb", Bindings: []Binding{{Role: "r", ACL: "missing"}}}},
+			},
+			message: "redis_acl 'missing', which does not exist",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			_, err := Plan(context.Background(), client, spec.manifest)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+// fakeCluster starts an in-memory cluster with an admin user and returns a client authenticated as that user.
+func fakeCluster(t *testing.T) (*fake.Cluster, *sdk.Client) {
+	t.Helper()
+
+	cluster := fake.NewCluster("test")
+	cluster.AddUser("admin", "admin@example.com", "Password", "admin")
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(url, "admin@example.com", "Password")
+	t.Cleanup(func() { _ = client.Close() })
+
+	return cluster, client
+}
@@ -0,0 +1,142 @@
+// Package bootstrap provisions a dedicated Redis Enterprise user for the plugin, with roles giving it only the
+// management levels the plugin needs rather than those of the cluster administrator.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Client is the part of the cluster API the account is provisioned with.
+type Client interface {
+	FindUserByName(ctx context.Context, name string) (sdk.User, error)
+	CreateUser(ctx context.Context, create sdk.CreateUser) (sdk.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	FindRoleByName(ctx context.Context, name string) (sdk.Role, error)
+	CreateRole(ctx context.Context, create sdk.CreateRole) (sdk.Role, error)
+	DeleteRole(ctx context.Context, id int) error
+}
+
+// Account is the user to provision for the plugin.
+type Account struct {
+	Name     string
+	Email    string
+	Password string
+
+	// Management is the management levels the user needs, each of which is given to the user by a role named after
+	// the user and the level
+	Management []string
+}
+
+// Provisioned is the user and roles which were provisioned.
+type Provisioned struct {
+	User  sdk.User
+	Roles []sdk.Role
+
+	// created is the roles created for the user, rather than ones left by a previous bootstrap which were reused
+	created []sdk.Role
+}
+
+// RoleName is the name of the role giving the user the management level.
+func RoleName(user string, management string) string {
+	return user + "-" + management
+}
+
+// Provision creates the user and its roles. A user which already exists is not changed, but roles with the expected
+// names and management levels are reused. Any roles which were created are removed again if the user cannot be
+// created.
+func Provision(ctx context.Context, client Client, account Account) (_ Provisioned, err error) {
+	if len(account.Management) == 0 {
+		return Provisioned{}, errors.New("no management levels to give the user")
+	}
+
+	for _, name := range []string{account.Name, account.Email} {
+		if name == "" {
+			continue
+		}
+		if _, err := client.FindUserByName(ctx, name); err == nil {
+			return Provisioned{}, fmt.Errorf("user '%s' already exists, and is left unchanged", name)
+		} else if !errors.Is(err, &sdk.UserNotFoundError{}) {
+			return Provisioned{}, fmt.Errorf("cannot look up user '%s': %w", name, err)
+		}
+	}
+
+	var provisioned Provisioned
+	defer func() {
+		if err != nil {
+			if rollbackErr := deleteRoles(context.TODO(), client, provisioned.created); rollbackErr != nil {
+				err = multierror.Append(err, rollbackErr)
+			}
+		}
+	}()
+
+	for _, management := range account.Management {
+		name := RoleName(account.Name, management)
+
+		role, err := client.FindRoleByName(ctx, name)
+		switch {
+		case err == nil:
+			if role.Management != management {
+				return Provisioned{}, fmt.Errorf("role '%s' already exists with the management level '%s' rather than '%s'", name, role.Management, management)
+			}
+		case errors.Is(err, &sdk.RoleNotFoundError{}):
+			role, err = client.CreateRole(ctx, sdk.CreateRole{Name: name, Management: management})
+			if err != nil {
+				return Provisioned{}, fmt.Errorf("cannot create role '%s': %w", name, err)
+			}
+			provisioned.created = append(provisioned.created, role)
+		default:
+			return Provisioned{}, fmt.Errorf("cannot look up role '%s': %w", name, err)
+		}
+
+		provisioned.Roles = append(provisioned.Roles, role)
+	}
+
+	create := sdk.CreateUser{
+		Name:     account.Name,
+		Email:    account.Email,
+		Password: account.Password,
+	}
+	for _, role := range provisioned.Roles {
+		create.Roles = append(create.Roles, role.UID)
+	}
+
+	provisioned.User, err = client.CreateUser(ctx, create)
+	if err != nil {
+		return Provisioned{}, fmt.Errorf("cannot create user '%s': %w", account.Name, err)
+	}
+
+	return provisioned, nil
+}
+
+// Created returns whether the role was created by Provision, rather than reused.
+func (p Provisioned) Created(role sdk.Role) bool {
+	for _, r := range p.created {
+		if r.UID == role.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes the provisioned user and the roles which were created for it.
+func Remove(ctx context.Context, client Client, provisioned Provisioned) error {
+	if err := client.DeleteUser(ctx, provisioned.User.UID); err != nil {
+		return fmt.Errorf("cannot delete user '%s': %w", provisioned.User.Name, err)
+	}
+	return deleteRoles(ctx, client, provisioned.created)
+}
+
+func deleteRoles(ctx context.Context, client Client, roles []sdk.Role) error {
+	var result error
+	for _, role := range roles {
+		if err := client.DeleteRole(ctx, role.UID); err != nil {
+			result = multierror.Append(result, fmt.Errorf("cannot delete role '%s': %w", role.Name, err))
+		}
+	}
+	return result
+}
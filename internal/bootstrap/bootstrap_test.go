@@ -0,0 +1,124 @@
+package bootstrap
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var account = Account{
+	Name:       "vault-plugin",
+	Password:   "secret",
+	Management: []string{"user_manager", "db_member"},
+}
+
+func TestProvision(t *testing.T) {
+	cluster, client := fakeCluster(t)
+
+	provisioned, err := Provision(context.Background(), client, account)
+	require.NoError(t, err)
+
+	require.Len(t, provisioned.Roles, 2)
+	assert.Equal(t, "vault-plugin-user_manager", provisioned.Roles[0].Name)
+	assert.Equal(t, "user_manager", provisioned.Roles[0].Management)
+	assert.Equal(t, "vault-plugin-db_member", provisioned.Roles[1].Name)
+	assert.Equal(t, "db_member", provisioned.Roles[1].Management)
+	assert.True(t, provisioned.Created(provisioned.Roles[0]))
+
+	user := findUser(t, cluster, "vault-plugin")
+	assert.Equal(t, []int{provisioned.Roles[0].UID, provisioned.Roles[1].UID}, user.Roles)
+	assert.Equal(t, "secret", user.Password)
+
+	require.NoError(t, Remove(context.Background(), client, provisioned))
+	assert.Len(t, cluster.Users(), 1)
+	assert.Empty(t, cluster.Roles())
+}
+
+func TestProvision_reusesRoles(t *testing.T) {
+	cluster, client := fakeCluster(t)
+	uid := cluster.AddRole("vault-plugin-user_manager", "user_manager")
+
+	provisioned, err := Provision(context.Background(), client, account)
+	require.NoError(t, err)
+	assert.Equal(t, uid, provisioned.Roles[0].UID)
+	assert.False(t, provisioned.Created(provisioned.Roles[0]))
+
+	// Only the role which was created is removed
+	require.NoError(t, Remove(context.Background(), client, provisioned))
+	require.Len(t, cluster.Roles(), 1)
+	assert.Equal(t, uid, cluster.Roles()[0].UID)
+}
+
+func TestProvision_invalid(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		setup   func(cluster *fake.Cluster)
+		message string
+	}{
+		{
+			name:    "existing user",
+			setup:   func(cluster *fake.Cluster) { cluster.AddUser("vault-plugin", "", "other", "admin") },
+			message: "user 'vault-plugin' already exists",
+		},
+		{
+			name:    "existing role",
+			setup:   func(cluster *fake.Cluster) { cluster.AddRole("vault-plugin-db_member", "admin") },
+			message: "role 'vault-plugin-db_member' already exists with the management level 'admin'",
+		},
+		{
+			name: "user not created",
+			setup: func(cluster *fake.Cluster) {
+				cluster.InjectError(http.MethodPost, "/v1/users", http.StatusInternalServerError, 1)
+			},
+			message: "cannot create user 'vault-plugin'",
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			cluster, client := fakeCluster(t)
+			spec.setup(cluster)
+			users, roles := len(cluster.Users()), len(cluster.Roles())
+
+			_, err := Provision(context.Background(), client, account)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+
+			// Nothing is left behind
+			assert.Len(t, cluster.Users(), users)
+			assert.Len(t, cluster.Roles(), roles)
+		})
+	}
+}
+
+// fakeCluster starts an in-memory cluster with an admin user and returns a client authenticated as that user.
+func fakeCluster(t *testing.T) (*fake.Cluster, *sdk.Client) {
+	t.Helper()
+
+	cluster := fake.NewCluster("test")
+	cluster.AddUser("admin", "admin@example.com", "Password", "admin")
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(url, "admin@example.com", "Password")
+	t.Cleanup(func() { _ = client.Close() })
+
+	return cluster, client
+}
+
+func findUser(t *testing.T, cluster *fake.Cluster, name string) fake.User {
+	t.Helper()
+
+	for _, user := range cluster.Users() {
+		if user.Name == name {
+			return user
+		}
+	}
+	require.Failf(t, "user not found", "no user %s", name)
+	return fake.User{}
+}
@@ -0,0 +1,178 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+func (c *Client) listRedisRules(ctx context.Context) ([]redisRule, error) {
+	var body redisRules
+	if err := c.request(ctx, http.MethodGet, "/acl/redisRules", nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body.RedisRules, nil
+}
+
+// FindACLByName finds the Redis rule with the name.
+func (c *Client) FindACLByName(ctx context.Context, name string) (*sdk.ACL, error) {
+	rules, err := c.listRedisRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.Name == name {
+			return &sdk.ACL{UID: rule.ID, Name: rule.Name, ACL: rule.ACL}, nil
+		}
+	}
+
+	return nil, sdk.NewACLNotFoundError(name)
+}
+
+func (c *Client) listRoles(ctx context.Context) ([]role, error) {
+	var body roles
+	if err := c.request(ctx, http.MethodGet, "/acl/roles", nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body.Roles, nil
+}
+
+// CreateRole creates a role which does not grant any Redis rules, until it is bound to a database. Redis Cloud has no
+// management roles, so the management of the role is ignored.
+func (c *Client) CreateRole(ctx context.Context, create sdk.CreateRole) (sdk.Role, error) {
+	id, err := c.change(ctx, http.MethodPost, "/acl/roles", updateRole{Name: create.Name, RedisRules: []updateRoleRule{}})
+	if err != nil {
+		return sdk.Role{}, err
+	}
+
+	return sdk.Role{UID: id, Name: create.Name}, nil
+}
+
+func (c *Client) DeleteRole(ctx context.Context, id int) error {
+	_, err := c.change(ctx, http.MethodDelete, fmt.Sprintf("/acl/roles/%d", id), nil)
+	return err
+}
+
+func (c *Client) GetRole(ctx context.Context, id int) (sdk.Role, error) {
+	roles, err := c.listRoles(ctx)
+	if err != nil {
+		return sdk.Role{}, err
+	}
+
+	for _, role := range roles {
+		if role.ID == id {
+			return sdk.Role{UID: role.ID, Name: role.Name}, nil
+		}
+	}
+
+	return sdk.Role{}, sdk.NewRoleNotFoundError(strconv.Itoa(id))
+}
+
+func (c *Client) FindRoleByName(ctx context.Context, name string) (sdk.Role, error) {
+	roles, err := c.listRoles(ctx)
+	if err != nil {
+		return sdk.Role{}, err
+	}
+
+	for _, role := range roles {
+		if role.Name == name {
+			return sdk.Role{UID: role.ID, Name: role.Name}, nil
+		}
+	}
+
+	return sdk.Role{}, sdk.NewRoleNotFoundError(name)
+}
+
+func (c *Client) listUsers(ctx context.Context) ([]user, error) {
+	var body users
+	if err := c.request(ctx, http.MethodGet, "/acl/users", nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body.Users, nil
+}
+
+// CreateUser creates a user with a single role, as a Redis Cloud user cannot have more than one.
+func (c *Client) CreateUser(ctx context.Context, create sdk.CreateUser) (sdk.User, error) {
+	if len(create.Roles) != 1 {
+		return sdk.User{}, errors.New("a Redis Cloud user must have exactly one role")
+	}
+
+	role, err := c.GetRole(ctx, create.Roles[0])
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	id, err := c.change(ctx, http.MethodPost, "/acl/users", createUser{Name: create.Name, Role: role.Name, Password: create.Password})
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	return sdk.User{UID: id, Name: create.Name, Roles: create.Roles}, nil
+}
+
+func (c *Client) UpdateUserPassword(ctx context.Context, id int, update sdk.UpdateUser) error {
+	_, err := c.change(ctx, http.MethodPut, fmt.Sprintf("/acl/users/%d", id), updateUser{Password: update.Password})
+	return err
+}
+
+// RevokeUser always fails, as a Redis Cloud user cannot be left without a role.
+func (c *Client) RevokeUser(_ context.Context, _ int, _ string, _ string) error {
+	return errors.New("a Redis Cloud user must have exactly one role, so cannot be revoked")
+}
+
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	_, err := c.change(ctx, http.MethodDelete, fmt.Sprintf("/acl/users/%d", id), nil)
+	return err
+}
+
+// ListUsers returns the users of the account, with the ID of the role each user has.
+func (c *Client) ListUsers(ctx context.Context) ([]sdk.User, error) {
+	users, err := c.listUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := c.listRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs := map[string]int{}
+	for _, role := range roles {
+		roleIDs[role.Name] = role.ID
+	}
+
+	result := make([]sdk.User, 0, len(users))
+	for _, user := range users {
+		converted := sdk.User{UID: user.ID, Name: user.Name}
+		if id, ok := roleIDs[user.Role]; ok {
+			converted.Roles = []int{id}
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// FindUserByName finds the user with the name. The roles of the user are not set, as Redis Cloud refers to the role
+// by name rather than ID.
+func (c *Client) FindUserByName(ctx context.Context, name string) (sdk.User, error) {
+	users, err := c.listUsers(ctx)
+	if err != nil {
+		return sdk.User{}, err
+	}
+
+	for _, user := range users {
+		if user.Name == name {
+			return sdk.User{UID: user.ID, Name: user.Name}, nil
+		}
+	}
+
+	return sdk.User{}, sdk.NewUserNotFoundError(name)
+}
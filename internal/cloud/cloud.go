@@ -0,0 +1,220 @@
+// Package cloud implements the client used by the plugin against the access management API of Redis Cloud, where the
+// plugin's users, roles and ACLs are the ACL users, roles and Redis rules of the account.
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	goversion "github.com/hashicorp/go-version"
+)
+
+// The timeout for the REST client requests.
+const timeout = 60 * time.Second
+
+// How often a task is checked, and how long to wait for it to finish.
+const (
+	taskPollInterval = 500 * time.Millisecond
+	taskTimeout      = 2 * time.Minute
+)
+
+// Client is a client of the Redis Cloud API with the same methods as the Redis Enterprise sdk client, so the plugin can
+// use either.
+type Client struct {
+	url       string
+	apiKey    string
+	secretKey string
+	log       hclog.Logger
+
+	// clientLock guards client, which is replaced when the pool of connections is changed
+	clientLock sync.RWMutex
+	client     *http.Client
+
+	pollInterval time.Duration
+
+	// subscriptions is the subscription of each database which has been found, as the API identifies a database by
+	// both
+	lock          sync.Mutex
+	subscriptions map[int]int
+}
+
+func NewClient(log hclog.Logger) *Client {
+	return &Client{
+		client:        newHTTPClient(0, 0),
+		log:           log,
+		pollInterval:  taskPollInterval,
+		subscriptions: map[int]int{},
+	}
+}
+
+// Initialise sets the URL of the API, e.g. https://api.redislabs.com/v1, and the account and user API keys used to
+// authenticate.
+func (c *Client) Initialise(url string, apiKey string, secretKey string) {
+	c.url = strings.TrimSuffix(url, "/")
+	c.apiKey = apiKey
+	c.secretKey = secretKey
+}
+
+// newHTTPClient returns a client for the API with its own pool of connections. A zero value uses the setting of
+// http.DefaultTransport.
+func newHTTPClient(maxIdleConns int, idleConnTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if maxIdleConns > 0 {
+		transport.MaxIdleConns = maxIdleConns
+	}
+	if idleConnTimeout > 0 {
+		transport.IdleConnTimeout = idleConnTimeout
+	}
+	transport.MaxIdleConnsPerHost = transport.MaxIdleConns
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// SetConnectionPool sets the number of idle connections to the API that are kept for reuse and how long they are kept
+// for, with a zero value using the default. The requests already being made finish with the previous pool, whose idle
+// connections are closed.
+func (c *Client) SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) {
+	c.clientLock.Lock()
+	previous := c.client
+	c.client = newHTTPClient(maxIdleConns, idleConnTimeout)
+	c.clientLock.Unlock()
+
+	previous.CloseIdleConnections()
+}
+
+// httpClient returns the client for the current pool of connections.
+func (c *Client) httpClient() *http.Client {
+	c.clientLock.RLock()
+	defer c.clientLock.RUnlock()
+	return c.client
+}
+
+// SetTokenAuth does nothing, as Redis Cloud is always authenticated with API keys.
+func (c *Client) SetTokenAuth(_ time.Duration) {
+	c.log.Warn("token authentication is not supported by Redis Cloud, API keys are used instead")
+}
+
+// SetDryRun does nothing, as changes to Redis Cloud cannot be logged rather than made.
+func (c *Client) SetDryRun(dryRun bool) {
+	if dryRun {
+		c.log.Warn("dry run is not supported by Redis Cloud, changes will be made")
+	}
+}
+
+func (c *Client) Close() error {
+	c.httpClient().CloseIdleConnections()
+	return nil
+}
+
+// GetCluster returns the account, which is the closest Redis Cloud has to a cluster.
+func (c *Client) GetCluster(ctx context.Context) (sdk.Cluster, error) {
+	var body account
+	if err := c.request(ctx, http.MethodGet, "/", nil, &body); err != nil {
+		return sdk.Cluster{}, err
+	}
+
+	return sdk.Cluster{Name: body.Account.Name}, nil
+}
+
+// GetVersion always fails, as Redis Cloud does not report a version and is kept up to date by Redis.
+func (c *Client) GetVersion(_ context.Context) (*goversion.Version, error) {
+	return nil, errors.New("Redis Cloud does not report its version")
+}
+
+func (c *Client) request(ctx context.Context, method string, path string, requestBody interface{}, responseBody interface{}) error {
+	url := c.url + path
+
+	requestBodyReader := &bytes.Buffer{}
+	if requestBody != nil {
+		if err := json.NewEncoder(requestBodyReader).Encode(requestBody); err != nil {
+			return fmt.Errorf("unable to encode request body %s %s: %w", method, path, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, requestBodyReader)
+	if err != nil {
+		return fmt.Errorf("unable to perform request %s %s: %w", method, path, err)
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("x-api-secret-key", c.secretKey)
+	req.Header.Set("Accept", "application/json")
+	if requestBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to perform request %s %s: %w", method, path, err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return fmt.Errorf("unable to perform request %s %s (%d): %w", method, path, res.StatusCode, err)
+		}
+		return sdk.NewHttpError(method, path, res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if responseBody != nil {
+		if err := json.NewDecoder(res.Body).Decode(responseBody); err != nil {
+			return fmt.Errorf("unable to decode response %s %s: %w", method, path, err)
+		}
+	}
+
+	return nil
+}
+
+// change makes a request which is processed asynchronously by a task, and waits for the task to finish. The ID of
+// the resource changed is returned.
+func (c *Client) change(ctx context.Context, method string, path string, requestBody interface{}) (int, error) {
+	var t task
+	if err := c.request(ctx, method, path, requestBody, &t); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+	defer cancel()
+
+	for t.Status != taskCompleted && t.Status != taskFailed {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("timed out waiting for task %s of %s %s: %w", t.ID, method, path, ctx.Err())
+		case <-time.After(c.pollInterval):
+		}
+
+		if err := c.request(ctx, http.MethodGet, "/tasks/"+t.ID, nil, &t); err != nil {
+			return 0, err
+		}
+	}
+
+	if t.Status == taskFailed {
+		if t.Response.Error == nil {
+			return 0, fmt.Errorf("task %s of %s %s failed", t.ID, method, path)
+		}
+
+		// The status of the error is the HTTP status the change would have failed with, e.g. "409 CONFLICT", so it
+		// is classified in the same way as an error response
+		status, _ := strconv.Atoi(strings.Fields(t.Response.Error.Status + " 0")[0])
+		return 0, sdk.NewHttpError(method, path, status, fmt.Sprintf("%s: %s", t.Response.Error.Type, t.Response.Error.Description))
+	}
+
+	return t.Response.ResourceID, nil
+}
@@ -0,0 +1,220 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/cloud/fake"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	apiKey    = "account-key"
+	secretKey = "user-key"
+)
+
+func TestClient_GetCluster_rejectsInvalidKeys(t *testing.T) {
+	account, _ := fakeAccount(t)
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(account.url, apiKey, "garbage")
+
+	_, err := subject.GetCluster(context.Background())
+	require.Error(t, err)
+	assert.True(t, sdk.IsUnauthorised(err))
+}
+
+func TestClient_GetCluster(t *testing.T) {
+	_, subject := fakeAccount(t)
+
+	cluster, err := subject.GetCluster(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test-account", cluster.Name)
+}
+
+func TestClient_SetConnectionPool(t *testing.T) {
+	subject := NewClient(hclog.NewNullLogger())
+
+	subject.SetConnectionPool(5, 15*time.Second)
+	transport := subject.httpClient().Transport.(*http.Transport)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 15*time.Second, transport.IdleConnTimeout)
+
+	// Removing the settings restores the defaults
+	subject.SetConnectionPool(0, 0)
+	transport = subject.httpClient().Transport.(*http.Transport)
+	assert.Equal(t, http.DefaultTransport.(*http.Transport).MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, http.DefaultTransport.(*http.Transport).IdleConnTimeout, transport.IdleConnTimeout)
+}
+
+func TestClient_FindDatabaseByName(t *testing.T) {
+	account, subject := fakeAccount(t)
+
+	db, err := subject.FindDatabaseByName(context.Background(), "mydb")
+	require.NoError(t, err)
+	assert.Equal(t, account.database, db.UID)
+	assert.Equal(t, []sdk.RolePermission{{RoleUID: account.role, ACLUID: account.rule}}, db.RolePermissions)
+
+	_, err = subject.FindDatabaseByName(context.Background(), "garbage")
+	assert.True(t, sdk.IsNotFound(err))
+}
+
+func TestClient_CreateUser_reportsTaskErrors(t *testing.T) {
+	account, subject := fakeAccount(t)
+	ctx := context.Background()
+
+	create := sdk.CreateUser{Name: "user", Password: "password", Roles: []int{account.role}}
+	user, err := subject.CreateUser(ctx, create)
+	require.NoError(t, err)
+	assert.NotZero(t, user.UID)
+
+	found, err := subject.FindUserByName(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, user.UID, found.UID)
+
+	// The task creating a second user with the same name fails with a conflict
+	_, err = subject.CreateUser(ctx, create)
+	require.Error(t, err)
+	assert.True(t, sdk.IsConflict(err), err.Error())
+	assert.Contains(t, err.Error(), "ACL_USER_ALREADY_EXISTS")
+
+	require.NoError(t, subject.UpdateUserPassword(ctx, user.UID, sdk.UpdateUser{Password: "changed"}))
+	assert.Equal(t, "changed", account.Users()[0].Password)
+
+	require.NoError(t, subject.DeleteUser(ctx, user.UID))
+	_, err = subject.FindUserByName(ctx, "user")
+	assert.True(t, sdk.IsNotFound(err))
+}
+
+func TestClient_ListUsers(t *testing.T) {
+	account, subject := fakeAccount(t)
+	ctx := context.Background()
+
+	created, err := subject.CreateUser(ctx, sdk.CreateUser{Name: "user", Password: "password", Roles: []int{account.role}})
+	require.NoError(t, err)
+
+	users, err := subject.ListUsers(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, users, sdk.User{UID: created.UID, Name: "user", Roles: []int{account.role}})
+}
+
+func TestClient_UpdateDatabaseRolePermissions(t *testing.T) {
+	account, subject := fakeAccount(t)
+	ctx := context.Background()
+
+	other := account.AddRedisRule("Read Only", "+@read ~*")
+	generated, err := subject.CreateRole(ctx, sdk.CreateRole{Name: "mydb-user"})
+	require.NoError(t, err)
+
+	db, err := subject.FindDatabaseByName(ctx, "mydb")
+	require.NoError(t, err)
+
+	// Bind the new role, and change the rule granted by the existing role
+	before, after, err := subject.UpdateDatabaseRolePermissions(ctx, db.UID, func(permissions []sdk.RolePermission) []sdk.RolePermission {
+		permissions[0].ACLUID = other
+		return append(permissions, sdk.RolePermission{RoleUID: generated.UID, ACLUID: account.rule})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []sdk.RolePermission{{RoleUID: account.role, ACLUID: account.rule}}, before)
+	assert.Len(t, after, 2)
+
+	db, err = subject.FindDatabaseByName(ctx, "mydb")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []sdk.RolePermission{{RoleUID: account.role, ACLUID: other}, {RoleUID: generated.UID, ACLUID: account.rule}}, db.RolePermissions)
+
+	// The rules granted by the role in other databases are kept
+	for _, role := range account.Roles() {
+		if role.ID == account.role {
+			require.Len(t, role.RedisRules, 2)
+		}
+	}
+
+	// Unbind the new role again
+	_, _, err = subject.UpdateDatabaseRolePermissions(ctx, db.UID, func(permissions []sdk.RolePermission) []sdk.RolePermission {
+		var result []sdk.RolePermission
+		for _, permission := range permissions {
+			if permission.RoleUID != generated.UID {
+				result = append(result, permission)
+			}
+		}
+		return result
+	})
+	require.NoError(t, err)
+
+	db, err = subject.FindDatabaseByName(ctx, "mydb")
+	require.NoError(t, err)
+	assert.Equal(t, []sdk.RolePermission{{RoleUID: account.role, ACLUID: other}}, db.RolePermissions)
+
+	require.NoError(t, subject.DeleteRole(ctx, generated.UID))
+}
+
+func TestClient_UpdateDatabaseRolePermissions_rejectsUnknownRole(t *testing.T) {
+	account, subject := fakeAccount(t)
+	ctx := context.Background()
+
+	db, err := subject.FindDatabaseByName(ctx, "mydb")
+	require.NoError(t, err)
+
+	_, _, err = subject.UpdateDatabaseRolePermissions(ctx, db.UID, func(permissions []sdk.RolePermission) []sdk.RolePermission {
+		return append(permissions, sdk.RolePermission{RoleUID: 999, ACLUID: account.rule})
+	})
+	require.Error(t, err)
+	assert.True(t, sdk.IsNotFound(err))
+}
+
+func TestClient_DeleteRole_failsWhileInUse(t *testing.T) {
+	account, subject := fakeAccount(t)
+	ctx := context.Background()
+
+	_, err := subject.CreateUser(ctx, sdk.CreateUser{Name: "user", Password: "password", Roles: []int{account.role}})
+	require.NoError(t, err)
+
+	err = subject.DeleteRole(ctx, account.role)
+	require.Error(t, err)
+	assert.True(t, sdk.IsConflict(err))
+}
+
+func TestClient_request_injectedError(t *testing.T) {
+	account, subject := fakeAccount(t)
+	account.InjectError(http.MethodGet, "/acl/roles", http.StatusServiceUnavailable, 1)
+
+	_, err := subject.FindRoleByName(context.Background(), "DB Member")
+	require.Error(t, err)
+	assert.True(t, sdk.IsRetryable(err))
+}
+
+type testAccount struct {
+	*fake.Account
+	url      string
+	database int
+	rule     int
+	role     int
+}
+
+// fakeAccount starts an account with the database mydb, and a role "DB Member" granting the rule "Not Dangerous" in
+// it and in a second database.
+func fakeAccount(t *testing.T) (testAccount, *Client) {
+	t.Helper()
+
+	account := fake.NewAccount("test-account", apiKey, secretKey)
+	subscription := account.AddSubscription("test")
+	database := account.AddDatabase(subscription, "mydb")
+	otherDatabase := account.AddDatabase(subscription, "other")
+	rule := account.AddRedisRule("Not Dangerous", "+@all -@dangerous ~*")
+	account.AddRedisRule("Full Access", "+@all ~*")
+	role := account.AddRole("DB Member", rule, database, otherDatabase)
+
+	url := account.Start()
+	t.Cleanup(account.Close)
+
+	client := NewClient(hclog.NewNullLogger())
+	client.Initialise(url, apiKey, secretKey)
+	client.pollInterval = 0
+
+	return testAccount{Account: account, url: url, database: database, rule: rule, role: role}, client
+}
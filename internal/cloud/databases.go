@@ -0,0 +1,233 @@
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+// FindDatabaseByName finds the database with the name in any of the subscriptions of the account. The roles_permissions
+// of the database are the Redis rule each role grants in it.
+func (c *Client) FindDatabaseByName(ctx context.Context, name string) (sdk.Database, error) {
+	var subs subscriptions
+	if err := c.request(ctx, http.MethodGet, "/subscriptions", nil, &subs); err != nil {
+		return sdk.Database{}, err
+	}
+
+	var found []sdk.Database
+	for _, sub := range subs.Subscriptions {
+		var body subscriptionDatabases
+		if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/subscriptions/%d/databases", sub.ID), nil, &body); err != nil {
+			return sdk.Database{}, err
+		}
+
+		for _, s := range body.Subscription {
+			for _, db := range s.Databases {
+				if db.Name != name {
+					continue
+				}
+
+				c.lock.Lock()
+				c.subscriptions[db.ID] = s.ID
+				c.lock.Unlock()
+
+				found = append(found, sdk.Database{UID: db.ID, Name: db.Name})
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return sdk.Database{}, sdk.NewDatabaseNotFoundError(name)
+	}
+	if len(found) > 1 {
+		return sdk.Database{}, fmt.Errorf("there is more than one database named %s in the account", name)
+	}
+
+	roles, err := c.listRoles(ctx)
+	if err != nil {
+		return sdk.Database{}, err
+	}
+
+	db := found[0]
+	db.RolePermissions = rolePermissions(roles, c.subscriptionOf(db.UID), db.UID)
+	return db, nil
+}
+
+// UpdateDatabaseRolePermissions applies the update to the roles_permissions of the database, by changing the Redis rule
+// each affected role grants in the database. Each role is updated separately, so only roles whose binding changes
+// are affected by concurrent updates. The database must have been found with FindDatabaseByName first.
+func (c *Client) UpdateDatabaseRolePermissions(ctx context.Context, id int, update func([]sdk.RolePermission) []sdk.RolePermission) ([]sdk.RolePermission, []sdk.RolePermission, error) {
+	subscription := c.subscriptionOf(id)
+	if subscription == 0 {
+		return nil, nil, fmt.Errorf("the subscription of database %d is not known", id)
+	}
+
+	roles, rules, err := c.rolesAndRules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before := rolePermissions(roles, subscription, id)
+	after := update(append([]sdk.RolePermission(nil), before...))
+
+	if err := validate(roles, rules, after); err != nil {
+		return nil, nil, err
+	}
+
+	current := bindings(before)
+	wanted := bindings(after)
+	for _, role := range roles {
+		if current[role.ID] == wanted[role.ID] {
+			continue
+		}
+
+		body := bindRole(role, rules, subscription, id, wanted[role.ID])
+		if _, err := c.change(ctx, http.MethodPut, fmt.Sprintf("/acl/roles/%d", role.ID), body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return before, after, nil
+}
+
+// ValidateDatabaseUpdate checks the roles and Redis rules of the update exist, as Redis Cloud has no way to validate
+// an update without making it.
+func (c *Client) ValidateDatabaseUpdate(ctx context.Context, _ int, update sdk.UpdateDatabase) error {
+	roles, rules, err := c.rolesAndRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	return validate(roles, rules, update.RolePermissions)
+}
+
+// GetCRDB always fails, as Redis Cloud does not have Active-Active database configurations.
+func (c *Client) GetCRDB(_ context.Context, _ string) (sdk.CRDB, error) {
+	return sdk.CRDB{}, errors.New("Redis Cloud does not have Active-Active database configurations")
+}
+
+// UpdateCRDBRolePermissions always fails, as the roles of Redis Cloud are bound to Active-Active databases in the same
+// way as any other database.
+func (c *Client) UpdateCRDBRolePermissions(_ context.Context, _ string, _ func([]sdk.CRDBRolePermission) []sdk.CRDBRolePermission) ([]sdk.CRDBRolePermission, []sdk.CRDBRolePermission, error) {
+	return nil, nil, errors.New("Redis Cloud does not have Active-Active database configurations")
+}
+
+// UpdateDatabaseAuthorizedSubjects always fails, as the client certificates accepted by a Redis Cloud database are
+// configured on the database rather than through ACLs.
+func (c *Client) UpdateDatabaseAuthorizedSubjects(_ context.Context, _ int, _ func([]sdk.Subject) []sdk.Subject) ([]sdk.Subject, []sdk.Subject, error) {
+	return nil, nil, errors.New("Redis Cloud does not have authorized subjects for client certificates")
+}
+
+func (c *Client) subscriptionOf(id int) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.subscriptions[id]
+}
+
+func (c *Client) rolesAndRules(ctx context.Context) ([]role, map[int]redisRule, error) {
+	roles, err := c.listRoles(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err := c.listRedisRules(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := map[int]redisRule{}
+	for _, rule := range list {
+		rules[rule.ID] = rule
+	}
+
+	return roles, rules, nil
+}
+
+// rolePermissions returns the binding of each role which grants a Redis rule in the database.
+func rolePermissions(roles []role, subscription int, id int) []sdk.RolePermission {
+	var permissions []sdk.RolePermission
+	for _, role := range roles {
+		for _, rule := range role.RedisRules {
+			if grantsIn(rule, subscription, id) {
+				permissions = append(permissions, sdk.RolePermission{RoleUID: role.ID, ACLUID: rule.RuleID})
+				break
+			}
+		}
+	}
+	return permissions
+}
+
+func grantsIn(rule roleRule, subscription int, id int) bool {
+	for _, db := range rule.Databases {
+		if db.SubscriptionID == subscription && db.DatabaseID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func bindings(permissions []sdk.RolePermission) map[int]int {
+	result := map[int]int{}
+	for _, permission := range permissions {
+		result[permission.RoleUID] = permission.ACLUID
+	}
+	return result
+}
+
+func validate(roles []role, rules map[int]redisRule, permissions []sdk.RolePermission) error {
+	known := map[int]bool{}
+	for _, role := range roles {
+		known[role.ID] = true
+	}
+
+	seen := map[int]bool{}
+	for _, permission := range permissions {
+		if !known[permission.RoleUID] {
+			return sdk.NewRoleNotFoundError(strconv.Itoa(permission.RoleUID))
+		}
+		if _, ok := rules[permission.ACLUID]; !ok {
+			return sdk.NewACLNotFoundError(strconv.Itoa(permission.ACLUID))
+		}
+		if seen[permission.RoleUID] {
+			return fmt.Errorf("role %d is bound more than once", permission.RoleUID)
+		}
+		seen[permission.RoleUID] = true
+	}
+	return nil
+}
+
+// bindRole returns the update to the role which grants the Redis rule in the database instead of any rule it grants
+// now, or no rule if the ID of the rule is zero. The rules the role grants in other databases are kept.
+func bindRole(r role, rules map[int]redisRule, subscription int, id int, ruleID int) updateRole {
+	update := updateRole{Name: r.Name, RedisRules: []updateRoleRule{}}
+	bound := false
+
+	for _, rule := range r.RedisRules {
+		var databases []roleDatabase
+		for _, db := range rule.Databases {
+			if db.SubscriptionID != subscription || db.DatabaseID != id {
+				databases = append(databases, db)
+			}
+		}
+		if rule.RuleID == ruleID {
+			databases = append(databases, roleDatabase{SubscriptionID: subscription, DatabaseID: id})
+			bound = true
+		}
+		if len(databases) > 0 {
+			update.RedisRules = append(update.RedisRules, updateRoleRule{RuleName: rule.RuleName, Databases: databases})
+		}
+	}
+
+	if ruleID != 0 && !bound {
+		update.RedisRules = append(update.RedisRules, updateRoleRule{
+			RuleName:  rules[ruleID].Name,
+			Databases: []roleDatabase{{SubscriptionID: subscription, DatabaseID: id}},
+		})
+	}
+
+	return update
+}
@@ -0,0 +1,657 @@
+// Package fake provides an in-memory Redis Cloud account serving the parts of the API used by the plugin, so the
+// plugin and the cloud client can be tested end to end without a real account.
+//
+// The account behaves the way the Redis Cloud API documents:
+//   - requests are authenticated with the x-api-key and x-api-secret-key headers
+//   - changes to roles and users are accepted with a task, which reports whether the change succeeded
+//   - names of users and roles must be unique
+//   - a role may only refer to Redis rules and databases which exist, and may not be deleted while it has users
+//
+// Tests can also inject errors.
+package fake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The statuses of a task, which is received and then either completes or fails the next time it is read.
+const (
+	taskReceived  = "received"
+	taskCompleted = "processing-completed"
+	taskFailed    = "processing-error"
+)
+
+type Database struct {
+	SubscriptionID int    `json:"-"`
+	ID             int    `json:"databaseId"`
+	Name           string `json:"name"`
+}
+
+type RedisRule struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ACL       string `json:"acl"`
+	IsDefault bool   `json:"isDefault"`
+	Status    string `json:"status"`
+}
+
+type Role struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	RedisRules []RoleRule `json:"redisRules"`
+	Users      []RoleUser `json:"users"`
+	Status     string     `json:"status"`
+}
+
+// RoleRule grants the Redis rule in each of the databases.
+type RoleRule struct {
+	RuleID    int            `json:"ruleId"`
+	RuleName  string         `json:"ruleName"`
+	Databases []RoleDatabase `json:"databases"`
+}
+
+type RoleDatabase struct {
+	SubscriptionID int    `json:"subscriptionId"`
+	DatabaseID     int    `json:"databaseId"`
+	DatabaseName   string `json:"databaseName"`
+}
+
+type RoleUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type User struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+
+	Password string `json:"-"`
+}
+
+type task struct {
+	ID          string       `json:"taskId"`
+	CommandType string       `json:"commandType"`
+	Status      string       `json:"status"`
+	Description string       `json:"description"`
+	Timestamp   string       `json:"timestamp"`
+	Response    taskResponse `json:"response"`
+
+	// final is the status the task reports once it has been read
+	final string
+}
+
+type taskResponse struct {
+	ResourceID int        `json:"resourceId,omitempty"`
+	Error      *taskError `json:"error,omitempty"`
+}
+
+type taskError struct {
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+type fault struct {
+	method    string
+	path      string
+	status    int
+	remaining int
+}
+
+// Account is an in-memory Redis Cloud account. The zero value is not usable, use NewAccount.
+type Account struct {
+	name      string
+	apiKey    string
+	secretKey string
+
+	lock          sync.Mutex
+	nextID        int
+	subscriptions map[int]string
+	databases     map[int]*Database
+	rules         map[int]*RedisRule
+	roles         map[int]*Role
+	users         map[int]*User
+	tasks         map[string]*task
+	faults        []*fault
+
+	// immediateTasks reports tasks as finished in the response to the change
+	immediateTasks bool
+
+	server *httptest.Server
+}
+
+// NewAccount creates an empty account with the given name, accepting requests made with the API key and secret key.
+// Start must be called before it can be used.
+func NewAccount(name string, apiKey string, secretKey string) *Account {
+	return &Account{
+		name:          name,
+		apiKey:        apiKey,
+		secretKey:     secretKey,
+		nextID:        1,
+		subscriptions: map[int]string{},
+		databases:     map[int]*Database{},
+		rules:         map[int]*RedisRule{},
+		roles:         map[int]*Role{},
+		users:         map[int]*User{},
+		tasks:         map[string]*task{},
+	}
+}
+
+// Start serves the account API on a local port and returns its URL.
+func (a *Account) Start() string {
+	a.server = httptest.NewServer(a.handler())
+	return a.server.URL
+}
+
+// Close stops serving the account API.
+func (a *Account) Close() {
+	if a.server != nil {
+		a.server.Close()
+	}
+}
+
+// SetImmediateTasks sets whether changes respond with a task which has already finished, rather than one which
+// finishes the next time it is read, so clients do not need to wait to poll the task.
+func (a *Account) SetImmediateTasks(immediate bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.immediateTasks = immediate
+}
+
+// InjectError causes the next count requests with the method and path, e.g. POST /acl/users, to fail with the status.
+func (a *Account) InjectError(method string, path string, status int, count int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.faults = append(a.faults, &fault{method: method, path: path, status: status, remaining: count})
+}
+
+func (a *Account) AddSubscription(name string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.id()
+	a.subscriptions[id] = name
+	return id
+}
+
+func (a *Account) AddDatabase(subscriptionID int, name string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.id()
+	a.databases[id] = &Database{SubscriptionID: subscriptionID, ID: id, Name: name}
+	return id
+}
+
+func (a *Account) AddRedisRule(name string, acl string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	id := a.id()
+	a.rules[id] = &RedisRule{ID: id, Name: name, ACL: acl, Status: "active"}
+	return id
+}
+
+// AddRole creates a role granting the rule in the databases.
+func (a *Account) AddRole(name string, ruleID int, databaseIDs ...int) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	rule := RoleRule{RuleID: ruleID, RuleName: a.rules[ruleID].Name}
+	for _, id := range databaseIDs {
+		db := a.databases[id]
+		rule.Databases = append(rule.Databases, RoleDatabase{SubscriptionID: db.SubscriptionID, DatabaseID: db.ID, DatabaseName: db.Name})
+	}
+
+	id := a.id()
+	a.roles[id] = &Role{ID: id, Name: name, RedisRules: []RoleRule{rule}, Status: "active"}
+	return id
+}
+
+// Users returns a copy of the users of the account.
+func (a *Account) Users() []User {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var users []User
+	for _, id := range sortedKeys(a.users) {
+		users = append(users, *a.users[id])
+	}
+	return users
+}
+
+// Roles returns a copy of the roles of the account.
+func (a *Account) Roles() []Role {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var roles []Role
+	for _, id := range sortedKeys(a.roles) {
+		roles = append(roles, a.role(a.roles[id]))
+	}
+	return roles
+}
+
+func (a *Account) id() int {
+	id := a.nextID
+	a.nextID++
+	return id
+}
+
+func (a *Account) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", a.authenticated(a.getAccount))
+
+	mux.HandleFunc("GET /subscriptions", a.authenticated(a.listSubscriptions))
+	mux.HandleFunc("GET /subscriptions/{subscriptionId}/databases", a.authenticated(a.listDatabases))
+
+	mux.HandleFunc("GET /acl/redisRules", a.authenticated(a.listRedisRules))
+
+	mux.HandleFunc("GET /acl/roles", a.authenticated(a.listRoles))
+	mux.HandleFunc("POST /acl/roles", a.authenticated(a.createRole))
+	mux.HandleFunc("PUT /acl/roles/{id}", a.authenticated(a.updateRole))
+	mux.HandleFunc("DELETE /acl/roles/{id}", a.authenticated(a.deleteRole))
+
+	mux.HandleFunc("GET /acl/users", a.authenticated(a.listUsers))
+	mux.HandleFunc("POST /acl/users", a.authenticated(a.createUser))
+	mux.HandleFunc("PUT /acl/users/{id}", a.authenticated(a.updateUser))
+	mux.HandleFunc("DELETE /acl/users/{id}", a.authenticated(a.deleteUser))
+
+	mux.HandleFunc("GET /tasks/{taskId}", a.authenticated(a.getTask))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.lock.Lock()
+		status := a.injectedFault(r)
+		a.lock.Unlock()
+
+		if status != 0 {
+			writeError(w, status, "INJECTED", "injected error")
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// injectedFault returns the status of an error injected for the request, or zero. The caller must hold the lock.
+func (a *Account) injectedFault(r *http.Request) int {
+	for i, f := range a.faults {
+		if f.method == r.Method && f.path == r.URL.Path {
+			f.remaining--
+			if f.remaining <= 0 {
+				a.faults = append(a.faults[:i], a.faults[i+1:]...)
+			}
+			return f.status
+		}
+	}
+	return 0
+}
+
+// authenticated only calls the handler when the request has the API keys of the account. The handler is called with
+// the lock held.
+func (a *Account) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		if r.Header.Get("x-api-key") != a.apiKey || r.Header.Get("x-api-secret-key") != a.secretKey {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication failed")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func (a *Account) getAccount(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"account": map[string]interface{}{"id": 1, "name": a.name},
+	})
+}
+
+func (a *Account) listSubscriptions(w http.ResponseWriter, _ *http.Request) {
+	subscriptions := []map[string]interface{}{}
+	for _, id := range sortedKeys(a.subscriptions) {
+		subscriptions = append(subscriptions, map[string]interface{}{"id": id, "name": a.subscriptions[id], "status": "active"})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"accountId": 1, "subscriptions": subscriptions})
+}
+
+func (a *Account) listDatabases(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := pathID(r, "subscriptionId")
+	if _, ok := a.subscriptions[subscriptionID]; !ok {
+		writeError(w, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND", "subscription not found")
+		return
+	}
+
+	databases := []Database{}
+	for _, id := range sortedKeys(a.databases) {
+		if db := a.databases[id]; db.SubscriptionID == subscriptionID {
+			databases = append(databases, *db)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accountId": 1,
+		"subscription": []map[string]interface{}{
+			{"subscriptionId": subscriptionID, "numberOfDatabases": len(databases), "databases": databases},
+		},
+	})
+}
+
+func (a *Account) listRedisRules(w http.ResponseWriter, _ *http.Request) {
+	rules := []RedisRule{}
+	for _, id := range sortedKeys(a.rules) {
+		rules = append(rules, *a.rules[id])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"accountId": 1, "redisRules": rules})
+}
+
+func (a *Account) listRoles(w http.ResponseWriter, _ *http.Request) {
+	roles := []Role{}
+	for _, id := range sortedKeys(a.roles) {
+		roles = append(roles, a.role(a.roles[id]))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"accountId": 1, "roles": roles})
+}
+
+type roleRequest struct {
+	Name       string `json:"name"`
+	RedisRules []struct {
+		RuleName  string `json:"ruleName"`
+		Databases []struct {
+			SubscriptionID int `json:"subscriptionId"`
+			DatabaseID     int `json:"databaseId"`
+		} `json:"databases"`
+	} `json:"redisRules"`
+}
+
+func (a *Account) createRole(w http.ResponseWriter, r *http.Request) {
+	var body roleRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "name is required")
+		return
+	}
+	for _, role := range a.roles {
+		if role.Name == body.Name {
+			a.writeTask(w, "aclRoleCreateRequest", 0, &taskError{Type: "ACL_ROLE_ALREADY_EXISTS", Status: "409 CONFLICT", Description: "role already exists"})
+			return
+		}
+	}
+
+	rules, taskErr := a.roleRules(body)
+	if taskErr != nil {
+		a.writeTask(w, "aclRoleCreateRequest", 0, taskErr)
+		return
+	}
+
+	id := a.id()
+	a.roles[id] = &Role{ID: id, Name: body.Name, RedisRules: rules, Status: "active"}
+	a.writeTask(w, "aclRoleCreateRequest", id, nil)
+}
+
+func (a *Account) updateRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.roles[pathID(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ACL_ROLE_NOT_FOUND", "role not found")
+		return
+	}
+
+	var body roleRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	rules, taskErr := a.roleRules(body)
+	if taskErr != nil {
+		a.writeTask(w, "aclRoleUpdateRequest", role.ID, taskErr)
+		return
+	}
+
+	if body.Name != "" {
+		role.Name = body.Name
+	}
+	role.RedisRules = rules
+	a.writeTask(w, "aclRoleUpdateRequest", role.ID, nil)
+}
+
+func (a *Account) deleteRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := a.roles[pathID(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ACL_ROLE_NOT_FOUND", "role not found")
+		return
+	}
+
+	for _, user := range a.users {
+		if user.Role == role.Name {
+			a.writeTask(w, "aclRoleDeleteRequest", role.ID, &taskError{Type: "ACL_ROLE_IN_USE", Status: "409 CONFLICT", Description: "role is assigned to users"})
+			return
+		}
+	}
+
+	delete(a.roles, role.ID)
+	a.writeTask(w, "aclRoleDeleteRequest", role.ID, nil)
+}
+
+// roleRules resolves the rules and databases of a role by name and ID. The caller must hold the lock.
+func (a *Account) roleRules(body roleRequest) ([]RoleRule, *taskError) {
+	var rules []RoleRule
+	for _, requested := range body.RedisRules {
+		rule, ok := a.ruleByName(requested.RuleName)
+		if !ok {
+			return nil, &taskError{Type: "ACL_REDIS_RULE_NOT_FOUND", Status: "404 NOT_FOUND", Description: fmt.Sprintf("redis rule %s not found", requested.RuleName)}
+		}
+
+		roleRule := RoleRule{RuleID: rule.ID, RuleName: rule.Name}
+		for _, requestedDB := range requested.Databases {
+			db, ok := a.databases[requestedDB.DatabaseID]
+			if !ok || db.SubscriptionID != requestedDB.SubscriptionID {
+				return nil, &taskError{Type: "DATABASE_NOT_FOUND", Status: "404 NOT_FOUND", Description: fmt.Sprintf("database %d not found", requestedDB.DatabaseID)}
+			}
+			roleRule.Databases = append(roleRule.Databases, RoleDatabase{SubscriptionID: db.SubscriptionID, DatabaseID: db.ID, DatabaseName: db.Name})
+		}
+		rules = append(rules, roleRule)
+	}
+	return rules, nil
+}
+
+// role returns a copy of the role with the users assigned to it. The caller must hold the lock.
+func (a *Account) role(role *Role) Role {
+	copied := *role
+	copied.RedisRules = append([]RoleRule(nil), role.RedisRules...)
+	copied.Users = []RoleUser{}
+	for _, id := range sortedKeys(a.users) {
+		if user := a.users[id]; user.Role == role.Name {
+			copied.Users = append(copied.Users, RoleUser{ID: user.ID, Name: user.Name})
+		}
+	}
+	return copied
+}
+
+func (a *Account) listUsers(w http.ResponseWriter, _ *http.Request) {
+	users := []User{}
+	for _, id := range sortedKeys(a.users) {
+		users = append(users, *a.users[id])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"accountId": 1, "users": users})
+}
+
+type userRequest struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+func (a *Account) createUser(w http.ResponseWriter, r *http.Request) {
+	var body userRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" || body.Role == "" || body.Password == "" {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "name, role and password are required")
+		return
+	}
+	for _, user := range a.users {
+		if user.Name == body.Name {
+			a.writeTask(w, "aclUserCreateRequest", 0, &taskError{Type: "ACL_USER_ALREADY_EXISTS", Status: "409 CONFLICT", Description: "user already exists"})
+			return
+		}
+	}
+	if _, ok := a.roleByName(body.Role); !ok {
+		a.writeTask(w, "aclUserCreateRequest", 0, &taskError{Type: "ACL_ROLE_NOT_FOUND", Status: "404 NOT_FOUND", Description: fmt.Sprintf("role %s not found", body.Role)})
+		return
+	}
+
+	id := a.id()
+	a.users[id] = &User{ID: id, Name: body.Name, Role: body.Role, Status: "active", Password: body.Password}
+	a.writeTask(w, "aclUserCreateRequest", id, nil)
+}
+
+func (a *Account) updateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.users[pathID(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ACL_USER_NOT_FOUND", "user not found")
+		return
+	}
+
+	var body userRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Role != "" {
+		if _, ok := a.roleByName(body.Role); !ok {
+			a.writeTask(w, "aclUserUpdateRequest", user.ID, &taskError{Type: "ACL_ROLE_NOT_FOUND", Status: "404 NOT_FOUND", Description: fmt.Sprintf("role %s not found", body.Role)})
+			return
+		}
+		user.Role = body.Role
+	}
+	if body.Password != "" {
+		user.Password = body.Password
+	}
+	a.writeTask(w, "aclUserUpdateRequest", user.ID, nil)
+}
+
+func (a *Account) deleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.users[pathID(r, "id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ACL_USER_NOT_FOUND", "user not found")
+		return
+	}
+
+	delete(a.users, user.ID)
+	a.writeTask(w, "aclUserDeleteRequest", user.ID, nil)
+}
+
+func (a *Account) getTask(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.tasks[r.PathValue("taskId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "TASK_NOT_FOUND", "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+	t.Status = t.final
+}
+
+// writeTask responds with a task for a change, which has already been made unless it failed. The caller must hold
+// the lock.
+func (a *Account) writeTask(w http.ResponseWriter, commandType string, resourceID int, taskErr *taskError) {
+	t := &task{
+		ID:          randomID(),
+		CommandType: commandType,
+		Status:      taskReceived,
+		Description: "Task request received and is being queued for processing.",
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		final:       taskCompleted,
+	}
+	if taskErr != nil {
+		t.final = taskFailed
+		t.Response.Error = taskErr
+	} else {
+		t.Response.ResourceID = resourceID
+	}
+	if a.immediateTasks {
+		t.Status = t.final
+	}
+	a.tasks[t.ID] = t
+
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+func (a *Account) ruleByName(name string) (*RedisRule, bool) {
+	for _, rule := range a.rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+func (a *Account) roleByName(name string) (*Role, bool) {
+	for _, role := range a.roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return nil, false
+}
+
+func pathID(r *http.Request, name string) int {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+func decode(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, map[string]string{"error": code, "description": description})
+}
+
+// randomID returns a random hex string, as used for the IDs of tasks.
+func randomID() string {
+	value := make([]byte, 16)
+	_, _ = rand.Read(value)
+	return hex.EncodeToString(value)
+}
+
+// sortedKeys returns the IDs in ascending order, so the account lists objects in the order they were created.
+func sortedKeys[T any](m map[int]T) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package cloud
+
+// The statuses of a task which mean the change has finished being processed
+const (
+	taskCompleted = "processing-completed"
+	taskFailed    = "processing-error"
+)
+
+type account struct {
+	Account struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"account"`
+}
+
+type subscriptions struct {
+	Subscriptions []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"subscriptions"`
+}
+
+type subscriptionDatabases struct {
+	Subscription []struct {
+		ID        int        `json:"subscriptionId"`
+		Databases []database `json:"databases"`
+	} `json:"subscription"`
+}
+
+type database struct {
+	ID   int    `json:"databaseId"`
+	Name string `json:"name"`
+}
+
+type redisRules struct {
+	RedisRules []redisRule `json:"redisRules"`
+}
+
+type redisRule struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	ACL  string `json:"acl"`
+}
+
+type roles struct {
+	Roles []role `json:"roles"`
+}
+
+type role struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	RedisRules []roleRule `json:"redisRules"`
+}
+
+// roleRule grants the Redis rule in each of the databases.
+type roleRule struct {
+	RuleID    int            `json:"ruleId"`
+	RuleName  string         `json:"ruleName"`
+	Databases []roleDatabase `json:"databases"`
+}
+
+type roleDatabase struct {
+	SubscriptionID int `json:"subscriptionId"`
+	DatabaseID     int `json:"databaseId"`
+}
+
+type updateRole struct {
+	Name       string           `json:"name"`
+	RedisRules []updateRoleRule `json:"redisRules"`
+}
+
+type updateRoleRule struct {
+	RuleName  string         `json:"ruleName"`
+	Databases []roleDatabase `json:"databases"`
+}
+
+type users struct {
+	Users []user `json:"users"`
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type createUser struct {
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+type updateUser struct {
+	Password string `json:"password,omitempty"`
+}
+
+// task is the progress of a change, which Redis Cloud makes asynchronously.
+type task struct {
+	ID       string `json:"taskId"`
+	Status   string `json:"status"`
+	Response struct {
+		ResourceID int `json:"resourceId"`
+		Error      *struct {
+			Type        string `json:"type"`
+			Status      string `json:"status"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"response"`
+}
@@ -0,0 +1,91 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// Formats are the output formats Write supports.
+var Formats = []string{FormatTable, FormatJSON, FormatCSV}
+
+var header = []string{"kind", "uid", "name", "created", "metadata", "auth_method", "roles", "database", "acl", "orphaned"}
+
+// Write writes the items in the format. The table and CSV formats have a row for each binding of an item, or a single
+// row if it has none.
+func Write(w io.Writer, format string, items []Item) error {
+	switch format {
+	case FormatJSON:
+		if items == nil {
+			items = []Item{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for _, row := range rows(items) {
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatTable:
+		writer := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		if _, err := fmt.Fprintln(writer, strings.ToUpper(strings.Join(header, "\t"))); err != nil {
+			return err
+		}
+		for _, row := range rows(items) {
+			if _, err := fmt.Fprintln(writer, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown format '%s', must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func rows(items []Item) [][]string {
+	var rows [][]string
+	for _, item := range items {
+		row := []string{
+			item.Kind,
+			strconv.Itoa(item.UID),
+			item.Name,
+			item.Created.Format(time.RFC3339),
+			item.Metadata,
+			item.AuthMethod,
+			strings.Join(item.Roles, ";"),
+		}
+
+		orphaned := ""
+		if item.Orphaned {
+			orphaned = "true"
+		}
+
+		if len(item.Bindings) == 0 {
+			rows = append(rows, append(row, "", "", orphaned))
+			continue
+		}
+		for _, binding := range item.Bindings {
+			rows = append(rows, append(append([]string{}, row...), binding.Database, binding.ACL, orphaned))
+		}
+	}
+	return rows
+}
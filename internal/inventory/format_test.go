@@ -0,0 +1,76 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var items = []Item{
+	{
+		Kind:       KindUser,
+		UID:        6,
+		Name:       roleUser,
+		Username:   roleUser,
+		Created:    time.Unix(1700000000, 0).UTC(),
+		Metadata:   "token_billing",
+		AuthMethod: "regular",
+		Roles:      []string{"DB Member"},
+		Bindings:   []Binding{{Database: "billing", ACL: "Full Access"}, {Database: "cache", ACL: "Read Only"}},
+	},
+	{
+		Kind:     KindRole,
+		UID:      4,
+		Name:     "cache-" + orphanUser,
+		Username: orphanUser,
+		Created:  time.Unix(1600000000, 0).UTC(),
+		Metadata: "token_cache",
+		Bindings: []Binding{},
+		Orphaned: true,
+	},
+}
+
+func TestWrite_csv(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatCSV, items))
+
+	assert.Equal(t, `kind,uid,name,created,metadata,auth_method,roles,database,acl,orphaned
+user,6,`+roleUser+`,2023-11-14T22:13:20Z,token_billing,regular,DB Member,billing,Full Access,
+user,6,`+roleUser+`,2023-11-14T22:13:20Z,token_billing,regular,DB Member,cache,Read Only,
+role,4,cache-`+orphanUser+`,2020-09-13T12:26:40Z,token_cache,,,,,true
+`, out.String())
+}
+
+func TestWrite_json(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatJSON, items))
+
+	var actual []Item
+	require.NoError(t, json.Unmarshal(out.Bytes(), &actual))
+	assert.Equal(t, items, actual)
+
+	// An empty inventory is an empty array rather than null
+	out.Reset()
+	require.NoError(t, Write(&out, FormatJSON, nil))
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestWrite_table(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatTable, items))
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 4)
+	assert.Regexp(t, `^KIND\s+UID\s+NAME\s+CREATED`, string(lines[0]))
+	assert.Regexp(t, `^role\s+4\s+cache-`+orphanUser+`\s+2020-09-13T12:26:40Z\s+token_cache\s+true$`, string(lines[3]))
+}
+
+func TestWrite_unknownFormat(t *testing.T) {
+	err := Write(&bytes.Buffer{}, "xml", items)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown format 'xml'")
+}
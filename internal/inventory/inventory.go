@@ -0,0 +1,253 @@
+// Package inventory lists the users and roles in a Redis Enterprise cluster which were generated by the plugin, so
+// what Vault has issued can be audited without going through the cluster UI.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+const (
+	KindUser = "user"
+	KindRole = "role"
+)
+
+// Client is the part of the cluster API the inventory is collected with.
+type Client interface {
+	ListUsers(ctx context.Context) ([]sdk.User, error)
+	ListRoles(ctx context.Context) ([]sdk.Role, error)
+	ListACLs(ctx context.Context) ([]sdk.ACL, error)
+	ListDatabases(ctx context.Context) ([]sdk.Database, error)
+}
+
+// Item is a user or role generated by the plugin.
+type Item struct {
+	Kind string `json:"kind"`
+	UID  int    `json:"uid"`
+	Name string `json:"name"`
+
+	// Username is the user the item was generated for, which is the item itself for users
+	Username string `json:"username"`
+
+	// Created is taken from the epoch at the end of the username
+	Created time.Time `json:"created"`
+
+	// Metadata is the Vault display name and role name the username was generated from, joined by underscores.
+	// They cannot be separated reliably, as either can contain underscores.
+	Metadata string `json:"metadata,omitempty"`
+
+	// AuthMethod and Roles are only set for users
+	AuthMethod string   `json:"auth_method,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+
+	Bindings []Binding `json:"bindings"`
+
+	// Orphaned is set for a generated role when the user it was generated for no longer exists
+	Orphaned bool `json:"orphaned,omitempty"`
+}
+
+// Binding is an ACL a role is bound to in a database.
+type Binding struct {
+	Database string `json:"database"`
+	ACL      string `json:"acl"`
+}
+
+// The usernames generated with credsutil are v_<display name>_<role name>_<20 random characters>_<epoch>, lower-cased
+var usernamePattern = regexp.MustCompile(`^v(?:_(.*))?_[a-z0-9]{20}_([0-9]+)$`)
+
+// ParseUsername returns the creation time and metadata of a username generated by the plugin. ok is false if the
+// username was not generated by the plugin.
+func ParseUsername(username string) (created time.Time, metadata string, ok bool) {
+	match := usernamePattern.FindStringSubmatch(username)
+	if match == nil {
+		return time.Time{}, "", false
+	}
+
+	epoch, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	return time.Unix(epoch, 0).UTC(), match[1], true
+}
+
+// Revoked users are renamed <username>_revoked_<epoch>, the epoch being when their grace period ends
+var revokedPattern = regexp.MustCompile(`^(.+)_revoked_([0-9]+)$`)
+
+// RevokedUsername returns the name a revoked user is given, which records when it is due to be deleted.
+func RevokedUsername(username string, due time.Time) string {
+	return fmt.Sprintf("%s_revoked_%d", username, due.Unix())
+}
+
+// ParseRevokedUsername returns the name a user generated by the plugin had before it was revoked, and when it is due to
+// be deleted. ok is false if the name is not of a revoked user generated by the plugin.
+func ParseRevokedUsername(name string) (username string, due time.Time, ok bool) {
+	match := revokedPattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", time.Time{}, false
+	}
+	if _, _, ok := ParseUsername(match[1]); !ok {
+		return "", time.Time{}, false
+	}
+
+	epoch, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	return match[1], time.Unix(epoch, 0).UTC(), true
+}
+
+// parseUserName returns the creation time and metadata of a user generated by the plugin, which may have been revoked.
+func parseUserName(name string) (created time.Time, metadata string, ok bool) {
+	if username, _, revoked := ParseRevokedUsername(name); revoked {
+		name = username
+	}
+	return ParseUsername(name)
+}
+
+// parseRoleName splits a role generated by the plugin, named <database>-<username>, into the database and username.
+func parseRoleName(name string) (database string, username string, ok bool) {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '-' {
+			continue
+		}
+		if _, _, ok := ParseUsername(name[i+1:]); ok {
+			return name[:i], name[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// Collect returns the users and roles generated by the plugin, ordered by name. If database is set, only the items
+// bound to that database and the roles generated for it are returned.
+func Collect(ctx context.Context, client Client, database string) ([]Item, error) {
+	users, err := client.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list users: %w", err)
+	}
+	roles, err := client.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list roles: %w", err)
+	}
+	acls, err := client.ListACLs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list ACLs: %w", err)
+	}
+	databases, err := client.ListDatabases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list databases: %w", err)
+	}
+
+	roleNames := map[int]string{}
+	for _, role := range roles {
+		roleNames[role.UID] = role.Name
+	}
+	aclNames := map[int]string{}
+	for _, acl := range acls {
+		aclNames[acl.UID] = acl.Name
+	}
+	bindings := map[int][]Binding{}
+	for _, db := range databases {
+		for _, permission := range db.RolePermissions {
+			bindings[permission.RoleUID] = append(bindings[permission.RoleUID], Binding{
+				Database: db.Name,
+				ACL:      aclName(aclNames, permission.ACLUID),
+			})
+		}
+	}
+
+	var items []Item
+	usernames := map[string]bool{}
+
+	for _, user := range users {
+		created, metadata, ok := parseUserName(user.Name)
+		if !ok {
+			continue
+		}
+		usernames[user.Name] = true
+
+		item := Item{
+			Kind:       KindUser,
+			UID:        user.UID,
+			Name:       user.Name,
+			Username:   user.Name,
+			Created:    created,
+			Metadata:   metadata,
+			AuthMethod: user.AuthMethod,
+			Bindings:   []Binding{},
+		}
+		for _, uid := range user.Roles {
+			item.Roles = append(item.Roles, roleNames[uid])
+			item.Bindings = append(item.Bindings, bindings[uid]...)
+		}
+		items = append(items, item)
+	}
+
+	for _, role := range roles {
+		_, username, ok := parseRoleName(role.Name)
+		if !ok {
+			continue
+		}
+		created, metadata, _ := ParseUsername(username)
+
+		item := Item{
+			Kind:     KindRole,
+			UID:      role.UID,
+			Name:     role.Name,
+			Username: username,
+			Created:  created,
+			Metadata: metadata,
+			Bindings: append([]Binding{}, bindings[role.UID]...),
+			Orphaned: !usernames[username],
+		}
+		items = append(items, item)
+	}
+
+	if database != "" {
+		items = boundTo(items, database)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].Kind > items[j].Kind
+	})
+
+	return items, nil
+}
+
+// boundTo returns the items bound to the database, along with the roles generated for it which are no longer bound.
+func boundTo(items []Item, database string) []Item {
+	var filtered []Item
+	for _, item := range items {
+		if item.Kind == KindRole {
+			if db, _, _ := parseRoleName(item.Name); db == database {
+				filtered = append(filtered, item)
+				continue
+			}
+		}
+		for _, binding := range item.Bindings {
+			if binding.Database == database {
+				filtered = append(filtered, item)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func aclName(names map[int]string, uid int) string {
+	if name, ok := names[uid]; ok {
+		return name
+	}
+	// The ACL may have been deleted since the database was read
+	return fmt.Sprintf("uid:%d", uid)
+}
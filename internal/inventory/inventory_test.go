@@ -0,0 +1,175 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	roleUser   = "v_token_billing_abcdefghij0123456789_1700000000"
+	aclUser    = "v_token_cache_0123456789abcdefghij_1700000060"
+	orphanUser = "v_token_cache_zzzzzzzzzz0123456789_1600000000"
+	// revokedUser was revoked with a grace period, and is due to be deleted an hour after it was created
+	revokedUser = "v_token_billing_0123456789abcdefghij_1700000000_revoked_1700003600"
+)
+
+func TestParseUsername(t *testing.T) {
+	for _, spec := range []struct {
+		username string
+		created  time.Time
+		metadata string
+		ok       bool
+	}{
+		{username: roleUser, created: time.Unix(1700000000, 0).UTC(), metadata: "token_billing", ok: true},
+		{username: "v_abcdefghij0123456789_1700000000", created: time.Unix(1700000000, 0).UTC(), ok: true},
+		{username: "v_my_display_my_role_abcdefghij0123456789_1700000000", created: time.Unix(1700000000, 0).UTC(), metadata: "my_display_my_role", ok: true},
+		{username: "admin"},
+		{username: "v_token_billing_ABCDEFGHIJ0123456789_1700000000"},
+		{username: "v_token_billing_abcdefghij0123456789"},
+		{username: "v_token_billing_abcdefghij012345678_1700000000"},
+	} {
+		t.Run(spec.username, func(t *testing.T) {
+			created, metadata, ok := ParseUsername(spec.username)
+			assert.Equal(t, spec.ok, ok)
+			assert.Equal(t, spec.created, created)
+			assert.Equal(t, spec.metadata, metadata)
+		})
+	}
+}
+
+func TestParseRevokedUsername(t *testing.T) {
+	username, due, ok := ParseRevokedUsername(revokedUser)
+	require.True(t, ok)
+	assert.Equal(t, "v_token_billing_0123456789abcdefghij_1700000000", username)
+	assert.Equal(t, time.Unix(1700003600, 0).UTC(), due)
+	assert.Equal(t, revokedUser, RevokedUsername(username, due))
+
+	// Only users generated by the plugin are revoked
+	_, _, ok = ParseRevokedUsername("admin_revoked_1700003600")
+	assert.False(t, ok)
+	_, _, ok = ParseRevokedUsername(roleUser)
+	assert.False(t, ok)
+	_, _, ok = ParseUsername(revokedUser)
+	assert.False(t, ok)
+}
+
+func TestParseRoleName(t *testing.T) {
+	database, username, ok := parseRoleName("my-db-" + aclUser)
+	require.True(t, ok)
+	assert.Equal(t, "my-db", database)
+	assert.Equal(t, aclUser, username)
+
+	_, _, ok = parseRoleName("DB Member")
+	assert.False(t, ok)
+}
+
+func TestCollect(t *testing.T) {
+	client := setupCluster(t)
+
+	items, err := Collect(context.Background(), client, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Item{
+		{
+			Kind:     KindRole,
+			UID:      3,
+			Name:     "cache-" + aclUser,
+			Username: aclUser,
+			Created:  time.Unix(1700000060, 0).UTC(),
+			Metadata: "token_cache",
+			Bindings: []Binding{{Database: "cache", ACL: "Read Only"}},
+		},
+		{
+			Kind:     KindRole,
+			UID:      4,
+			Name:     "cache-" + orphanUser,
+			Username: orphanUser,
+			Created:  time.Unix(1600000000, 0).UTC(),
+			Metadata: "token_cache",
+			Bindings: []Binding{},
+			Orphaned: true,
+		},
+		{
+			Kind:       KindUser,
+			UID:        9,
+			Name:       revokedUser,
+			Username:   revokedUser,
+			Created:    time.Unix(1700000000, 0).UTC(),
+			Metadata:   "token_billing",
+			AuthMethod: "regular",
+			Bindings:   []Binding{},
+		},
+		{
+			Kind:       KindUser,
+			UID:        6,
+			Name:       roleUser,
+			Username:   roleUser,
+			Created:    time.Unix(1700000000, 0).UTC(),
+			Metadata:   "token_billing",
+			AuthMethod: "regular",
+			Roles:      []string{"DB Member"},
+			Bindings:   []Binding{{Database: "billing", ACL: "Full Access"}, {Database: "cache", ACL: "Read Only"}},
+		},
+		{
+			Kind:       KindUser,
+			UID:        7,
+			Name:       aclUser,
+			Username:   aclUser,
+			Created:    time.Unix(1700000060, 0).UTC(),
+			Metadata:   "token_cache",
+			AuthMethod: "regular",
+			Roles:      []string{"cache-" + aclUser},
+			Bindings:   []Binding{{Database: "cache", ACL: "Read Only"}},
+		},
+	}, items)
+}
+
+func TestCollect_database(t *testing.T) {
+	client := setupCluster(t)
+
+	items, err := Collect(context.Background(), client, "billing")
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, roleUser, items[0].Name)
+
+	// Roles generated for the database are listed even when they are no longer bound to it
+	items, err = Collect(context.Background(), client, "cache")
+	require.NoError(t, err)
+	assert.Len(t, items, 4)
+}
+
+// setupCluster starts a cluster with users and roles generated by the plugin, alongside ones which were not.
+func setupCluster(t *testing.T) *sdk.Client {
+	t.Helper()
+
+	cluster := fake.NewCluster("test")
+	fullAccess := cluster.AddACL("Full Access", "+@all ~*")
+	readOnly := cluster.AddACL("Read Only", "+@read ~*")
+	generated := cluster.AddRole("cache-"+aclUser, "db_member")
+	cluster.AddRole("cache-"+orphanUser, "db_member")
+	member := cluster.AddRole("DB Member", "db_member")
+
+	cluster.AddUser(roleUser, "", "secret", "db_member", member)
+	cluster.AddUser(aclUser, "", "secret", "db_member", generated)
+	cluster.AddUser("admin", "admin@example.com", "Password", "admin")
+	cluster.AddUser(revokedUser, "", "secret", "db_member")
+
+	cluster.AddDatabase("billing", fake.RolePermission{RoleUID: member, ACLUID: fullAccess})
+	cluster.AddDatabase("cache", fake.RolePermission{RoleUID: member, ACLUID: readOnly}, fake.RolePermission{RoleUID: generated, ACLUID: readOnly})
+
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	client := sdk.NewClient(hclog.NewNullLogger())
+	client.Initialise(url, "admin@example.com", "Password")
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
@@ -0,0 +1,101 @@
+package inventory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Selector chooses which of the generated users to purge. The zero value selects every user.
+type Selector struct {
+	// RoleName selects the users whose metadata, the Vault display name and role name, contains it
+	RoleName string
+
+	// OlderThan selects the users created more than the duration before Now
+	OlderThan time.Duration
+	Now       time.Time
+}
+
+// Target is a user to purge, along with any role generated for it.
+type Target struct {
+	Username string
+	Created  time.Time
+
+	// Database is set when a role was generated for the user, and is the database the role was generated for
+	Database string
+}
+
+// Select returns the users the selector chooses from the items, ordered by username. A role whose user no longer
+// exists is selected as a user, so the role is still purged.
+func Select(items []Item, selector Selector) []Target {
+	targets := map[string]*Target{}
+	for _, item := range items {
+		if !selector.selects(item) {
+			continue
+		}
+
+		target, ok := targets[item.Username]
+		if !ok {
+			target = &Target{Username: item.Username, Created: item.Created}
+			targets[item.Username] = target
+		}
+		if item.Kind == KindRole {
+			target.Database, _, _ = parseRoleName(item.Name)
+		}
+	}
+
+	selected := make([]Target, 0, len(targets))
+	for _, target := range targets {
+		selected = append(selected, *target)
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].Username < selected[j].Username })
+
+	return selected
+}
+
+func (s Selector) selects(item Item) bool {
+	if s.RoleName != "" && !strings.Contains(item.Metadata, strings.ToLower(s.RoleName)) {
+		return false
+	}
+	if s.OlderThan > 0 && !item.Created.Before(s.Now.Add(-s.OlderThan)) {
+		return false
+	}
+	return true
+}
+
+// Result is the outcome of purging a target.
+type Result struct {
+	Target Target
+	Err    error
+}
+
+// Purge deletes the targets with at most concurrency deletes running at once, returning the results in the order of
+// the targets. Every target is attempted, even after a delete fails.
+func Purge(ctx context.Context, targets []Target, concurrency int, deleteUser func(ctx context.Context, target Target) error) []Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]Result, len(targets))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		results[i].Target = target
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(result *Result) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result.Err = deleteUser(ctx, result.Target)
+		}(&results[i])
+	}
+
+	wg.Wait()
+	return results
+}
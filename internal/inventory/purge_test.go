@@ -0,0 +1,107 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	client := setupCluster(t)
+	items, err := Collect(context.Background(), client, "")
+	require.NoError(t, err)
+
+	now := time.Unix(1700000100, 0).UTC()
+
+	for _, spec := range []struct {
+		name     string
+		selector Selector
+		expected []Target
+	}{
+		{
+			name:     "all",
+			selector: Selector{},
+			expected: []Target{
+				{Username: revokedUser, Created: time.Unix(1700000000, 0).UTC()},
+				{Username: roleUser, Created: time.Unix(1700000000, 0).UTC()},
+				{Username: aclUser, Created: time.Unix(1700000060, 0).UTC(), Database: "cache"},
+				{Username: orphanUser, Created: time.Unix(1600000000, 0).UTC(), Database: "cache"},
+			},
+		},
+		{
+			name:     "role name",
+			selector: Selector{RoleName: "Billing"},
+			expected: []Target{
+				{Username: revokedUser, Created: time.Unix(1700000000, 0).UTC()},
+				{Username: roleUser, Created: time.Unix(1700000000, 0).UTC()},
+			},
+		},
+		{
+			name:     "older than",
+			selector: Selector{OlderThan: time.Minute, Now: now},
+			expected: []Target{
+				{Username: revokedUser, Created: time.Unix(1700000000, 0).UTC()},
+				{Username: roleUser, Created: time.Unix(1700000000, 0).UTC()},
+				{Username: orphanUser, Created: time.Unix(1600000000, 0).UTC(), Database: "cache"},
+			},
+		},
+		{
+			name:     "none",
+			selector: Selector{RoleName: "payments"},
+			expected: []Target{},
+		},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			assert.Equal(t, spec.expected, Select(items, spec.selector))
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	targets := []Target{{Username: "a"}, {Username: "b"}, {Username: "c"}, {Username: "d"}, {Username: "e"}}
+
+	var running, maxRunning atomic.Int32
+	var lock sync.Mutex
+	var deleted []string
+
+	results := Purge(context.Background(), targets, 2, func(ctx context.Context, target Target) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if current <= max || maxRunning.CompareAndSwap(max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if target.Username == "b" {
+			return errors.New("failed")
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		deleted = append(deleted, target.Username)
+		return nil
+	})
+
+	require.Len(t, results, len(targets))
+	for i, result := range results {
+		assert.Equal(t, targets[i], result.Target)
+		if result.Target.Username == "b" {
+			assert.Error(t, result.Err)
+		} else {
+			assert.NoError(t, result.Err)
+		}
+	}
+
+	// Every other target is still deleted after one fails
+	assert.ElementsMatch(t, []string{"a", "c", "d", "e"}, deleted)
+	assert.Equal(t, int32(2), maxRunning.Load())
+}
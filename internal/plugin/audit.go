@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-multierror"
+)
+
+// The types of audit events, one for each kind of change the plugin makes to the cluster.
+const (
+	auditUserCreated            = "user_created"
+	auditUserDeleted            = "user_deleted"
+	auditPasswordChanged        = "password_changed"
+	auditUserRevoked            = "user_revoked"
+	auditRoleCreated            = "role_created"
+	auditRoleDeleted            = "role_deleted"
+	auditRolePermissionsUpdated = "roles_permissions_updated"
+	auditSubjectsUpdated        = "authorized_subjects_updated"
+)
+
+// auditEvent describes a single change made to the cluster. It must never contain a secret, such as a password.
+type auditEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	// The Vault names the change was made for, when known
+	DisplayName string `json:"display_name,omitempty"`
+	VaultRole   string `json:"vault_role,omitempty"`
+
+	User        string `json:"user,omitempty"`
+	UserUID     int    `json:"user_uid,omitempty"`
+	Subject     string `json:"certificate_subject,omitempty"`
+	Role        string `json:"role,omitempty"`
+	RoleUID     int    `json:"role_uid,omitempty"`
+	Database    string `json:"database,omitempty"`
+	DatabaseUID int    `json:"database_uid,omitempty"`
+
+	// The roles_permissions of the database before and after an update
+	Before []sdk.RolePermission `json:"roles_permissions_before,omitempty"`
+	After  []sdk.RolePermission `json:"roles_permissions_after,omitempty"`
+
+	// The authorized_subjects of the database before and after an update
+	SubjectsBefore []sdk.Subject `json:"authorized_subjects_before,omitempty"`
+	SubjectsAfter  []sdk.Subject `json:"authorized_subjects_after,omitempty"`
+
+	// The roles_permissions of an Active-Active database before and after an update
+	CRDB       string                   `json:"crdb_guid,omitempty"`
+	CRDBBefore []sdk.CRDBRolePermission `json:"crdb_roles_permissions_before,omitempty"`
+	CRDBAfter  []sdk.CRDBRolePermission `json:"crdb_roles_permissions_after,omitempty"`
+
+	// RenamedTo is the name a revoked user is kept under until it is deleted
+	RenamedTo string `json:"renamed_to,omitempty"`
+
+	// Secondary is set if the change was made to the secondary cluster
+	Secondary bool `json:"secondary,omitempty"`
+
+	// Reason explains why a change was made when it is not the direct result of a request from Vault
+	Reason string `json:"reason,omitempty"`
+
+	// DryRun is set if the change was only logged, rather than made to the cluster
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// auditSink records the changes made to the cluster in an append-only form.
+type auditSink interface {
+	Record(event auditEvent) error
+	Close() error
+}
+
+// audit records the event in the configured sinks. A failure to record is logged rather than returned, as the
+// change has already been made to the cluster by the time it is recorded.
+func (r *redisEnterpriseDB) audit(event auditEvent) {
+	event.Time = time.Now().UTC()
+	event.Secondary = r.isSecondary
+	event.DryRun = r.config.DryRun
+	if err := r.auditSink.Record(event); err != nil {
+		r.logger.Error("unable to record audit event", "type", event.Type, "err", err)
+	}
+}
+
+// newAuditSink creates the sinks enabled in the configuration.
+func newAuditSink(c config) (auditSink, error) {
+	var sinks multiAuditSink
+
+	if c.AuditFile != "" {
+		sink, err := newFileAuditSink(c.AuditFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if c.AuditSyslog {
+		sink, err := newSyslogAuditSink()
+		if err != nil {
+			_ = sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// multiAuditSink records every event in all of the sinks. No sinks means the events are discarded.
+type multiAuditSink []auditSink
+
+func (m multiAuditSink) Record(event auditEvent) error {
+	var result error
+	for _, sink := range m {
+		if err := sink.Record(event); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func (m multiAuditSink) Close() error {
+	var result error
+	for _, sink := range m {
+		if err := sink.Close(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// fileAuditSink appends each event to a file as a line of JSON.
+type fileAuditSink struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit file: %w", err)
+	}
+
+	return &fileAuditSink{file: file}, nil
+}
+
+func (f *fileAuditSink) Record(event auditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, err = f.file.Write(append(line, '\n'))
+	return err
+}
+
+func (f *fileAuditSink) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.file.Close()
+}
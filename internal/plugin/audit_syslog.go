@@ -0,0 +1,39 @@
+//go:build !windows && !plan9
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+// The tag the audit events are sent to syslog with.
+const auditSyslogTag = "vault-plugin-database-redisenterprise"
+
+// syslogAuditSink sends each event to the local syslog as JSON.
+type syslogAuditSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslogAuditSink() (auditSink, error) {
+	writer, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, auditSyslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to syslog: %w", err)
+	}
+
+	return &syslogAuditSink{writer: writer}, nil
+}
+
+func (s *syslogAuditSink) Record(event auditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return s.writer.Notice(string(line))
+}
+
+func (s *syslogAuditSink) Close() error {
+	return s.writer.Close()
+}
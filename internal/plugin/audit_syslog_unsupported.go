@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package plugin
+
+import "errors"
+
+func newSyslogAuditSink() (auditSink, error) {
+	return nil, errors.New("audit_syslog is not supported on this platform")
+}
@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAuditSink_appendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for i := 0; i < 2; i++ {
+		sink, err := newFileAuditSink(path)
+		require.NoError(t, err)
+		require.NoError(t, sink.Record(auditEvent{Type: auditUserDeleted, User: "user", UserUID: i}))
+		require.NoError(t, sink.Close())
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"time":"0001-01-01T00:00:00Z","type":"user_deleted","user":"user"}`, lines[0])
+	assert.JSONEq(t, `{"time":"0001-01-01T00:00:00Z","type":"user_deleted","user":"user","user_uid":1}`, lines[1])
+}
+
+func TestRedisEnterpriseDB_NewUser_auditsGeneratedRole(t *testing.T) {
+	client := &mockSdk{}
+	sink := &recordingAuditSink{}
+	subject := newRedis(hclog.New(&hclog.LoggerOptions{Level: hclog.Trace}), client)
+	subject.auditSink = sink
+	subject.config = config{
+		Database: "mocked",
+		Features: "acl_only",
+	}
+
+	ctx := testContext(t)
+
+	client.On("FindACLByName", matchesContext(ctx), "expected").Return(&sdk.ACL{UID: 3}, nil)
+	client.On("CreateRole", matchesContext(ctx), matchesCreateRole("db_member", "mocked", "test", "user")).Return(sdk.Role{UID: 4, Name: "generated"}, nil)
+	database := sdk.Database{
+		UID:  5,
+		Name: "mocked",
+		RolePermissions: []sdk.RolePermission{
+			{RoleUID: 5, ACLUID: 6},
+		},
+	}
+	client.On("FindDatabaseByName", matchesContext(ctx), "mocked").Return(database, nil)
+	client.On("GetDatabase", matchesContext(ctx), 5).Return(database, nil)
+	client.On("UpdateDatabaseRolePermissions", matchesContext(ctx), 5, sdk.UpdateDatabase{
+		RolePermissions: []sdk.RolePermission{
+			{RoleUID: 5, ACLUID: 6},
+			{RoleUID: 4, ACLUID: 3},
+		},
+	}).Return(nil)
+	client.On("CreateUser", matchesContext(ctx), matchesCreateUser("test", "user", 4, "secret-password")).Return(sdk.User{UID: 7}, nil)
+
+	res, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "user",
+		},
+		Statements: dbplugin.Statements{
+			Commands: []string{`{"acl": "expected"}`},
+		},
+		Password: "secret-password",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, sink.events, 3)
+
+	assert.Equal(t, auditRoleCreated, sink.events[0].Type)
+	assert.Equal(t, 4, sink.events[0].RoleUID)
+
+	assert.Equal(t, auditRolePermissionsUpdated, sink.events[1].Type)
+	assert.Equal(t, []sdk.RolePermission{{RoleUID: 5, ACLUID: 6}}, sink.events[1].Before)
+	assert.Equal(t, []sdk.RolePermission{{RoleUID: 5, ACLUID: 6}, {RoleUID: 4, ACLUID: 3}}, sink.events[1].After)
+
+	assert.Equal(t, auditUserCreated, sink.events[2].Type)
+	assert.Equal(t, res.Username, sink.events[2].User)
+	assert.Equal(t, 7, sink.events[2].UserUID)
+	assert.Equal(t, "test", sink.events[2].DisplayName)
+	assert.Equal(t, "user", sink.events[2].VaultRole)
+
+	for _, event := range sink.events {
+		assert.False(t, event.Time.IsZero())
+		encoded, err := json.Marshal(event)
+		require.NoError(t, err)
+		assert.NotContains(t, string(encoded), "secret-password")
+	}
+}
+
+var _ auditSink = &recordingAuditSink{}
+
+type recordingAuditSink struct {
+	events []auditEvent
+}
+
+func (r *recordingAuditSink) Record(event auditEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingAuditSink) Close() error {
+	return nil
+}
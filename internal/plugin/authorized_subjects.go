@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+// authorizeSubject adds the subject of a user's client certificate to the authorized_subjects of the database, so the
+// database accepts the certificate with TLS client authentication until the user is deleted.
+func (r *redisEnterpriseDB) authorizeSubject(ctx context.Context, meta dbplugin.UsernameMetadata, username string, line string) error {
+	subject, err := sdk.ParseSubject(line)
+	if err != nil {
+		return err
+	}
+
+	r.databaseUpdates.Lock()
+	defer r.databaseUpdates.Unlock()
+
+	db, err := r.findSubjectDatabase(ctx, subject, line)
+	if err != nil {
+		return err
+	}
+
+	before, after, err := r.client.UpdateDatabaseAuthorizedSubjects(ctx, db.UID, func(subjects []sdk.Subject) []sdk.Subject {
+		if indexOfSubject(subjects, subject) >= 0 {
+			return subjects
+		}
+		return append(subjects, subject)
+	})
+	if err != nil {
+		return r.describeError(err, "")
+	}
+
+	r.audit(auditEvent{
+		Type:           auditSubjectsUpdated,
+		DisplayName:    meta.DisplayName,
+		VaultRole:      meta.RoleName,
+		User:           username,
+		Subject:        line,
+		Database:       db.Name,
+		DatabaseUID:    db.UID,
+		SubjectsBefore: before,
+		SubjectsAfter:  after,
+	})
+
+	return nil
+}
+
+// findSubjectDatabase finds the database the subject is to be authorized by, which must not already authorize it.
+func (r *redisEnterpriseDB) findSubjectDatabase(ctx context.Context, subject sdk.Subject, line string) (sdk.Database, error) {
+	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	if err != nil {
+		return sdk.Database{}, r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
+	}
+	if db.CRDT {
+		return sdk.Database{}, fmt.Errorf("the authorized_subjects of Active-Active database '%s' cannot be managed by the plugin", db.Name)
+	}
+
+	// The subject would be removed when either user is deleted, leaving the other unable to connect
+	if indexOfSubject(db.AuthorizedSubjects, subject) >= 0 {
+		return sdk.Database{}, fmt.Errorf("subject '%s' is already authorized by database '%s' on cluster '%s'", line, db.Name, r.describeCluster())
+	}
+
+	return db, nil
+}
+
+// unauthorizeSubject removes the subject of a user's client certificate from the authorized_subjects of the database.
+// A subject which is not authorized is ignored, so it can be retried.
+func (r *redisEnterpriseDB) unauthorizeSubject(ctx context.Context, meta dbplugin.UsernameMetadata, username string, line string, reason string) error {
+	subject, err := sdk.ParseSubject(line)
+	if err != nil {
+		return err
+	}
+
+	r.databaseUpdates.Lock()
+	defer r.databaseUpdates.Unlock()
+
+	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	if err != nil {
+		return r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
+	}
+	if indexOfSubject(db.AuthorizedSubjects, subject) < 0 {
+		return nil
+	}
+
+	before, after, err := r.client.UpdateDatabaseAuthorizedSubjects(ctx, db.UID, func(subjects []sdk.Subject) []sdk.Subject {
+		if i := indexOfSubject(subjects, subject); i >= 0 {
+			return append(subjects[:i], subjects[i+1:]...)
+		}
+		return subjects
+	})
+	if err != nil {
+		return r.describeError(err, "")
+	}
+
+	r.audit(auditEvent{
+		Type:           auditSubjectsUpdated,
+		DisplayName:    meta.DisplayName,
+		VaultRole:      meta.RoleName,
+		User:           username,
+		Subject:        line,
+		Database:       db.Name,
+		DatabaseUID:    db.UID,
+		SubjectsBefore: before,
+		SubjectsAfter:  after,
+		Reason:         reason,
+	})
+
+	return nil
+}
+
+func (r *redisEnterpriseDB) cleanUpAuthorizedSubjectOnError(originalErr *error, meta dbplugin.UsernameMetadata, username string, line string) {
+	if *originalErr == nil {
+		return
+	}
+
+	// Can't use the 'real' context as there's the possibility that the problem is the context timed out
+	err := r.unauthorizeSubject(context.TODO(), meta, username, line, "rollback")
+	recordRollback(err)
+	if err != nil {
+		*originalErr = multierror.Append(*originalErr, fmt.Errorf("unable to remove subject '%s' from database '%s': %w", line, r.config.Database, err))
+	}
+}
+
+// removeAuthorizedSubject removes the subject of a certificate user from the database before the user is deleted, so
+// the certificate cannot be used to connect even if deleting the user fails.
+func (r *redisEnterpriseDB) removeAuthorizedSubject(ctx context.Context, user sdk.User) error {
+	if user.AuthMethod != authMethodCertificate || user.CertificateSubjectLine == "" {
+		return nil
+	}
+
+	if err := r.unauthorizeSubject(ctx, dbplugin.UsernameMetadata{}, user.Name, user.CertificateSubjectLine, ""); err != nil {
+		if errors.Is(err, &sdk.DatabaseNotFoundError{}) {
+			// The database may have been deleted manually, along with its authorized_subjects
+			return nil
+		}
+		return fmt.Errorf("cannot remove subject '%s' of user %s from database '%s': %w", user.CertificateSubjectLine, user.Name, r.config.Database, err)
+	}
+
+	return nil
+}
+
+func indexOfSubject(subjects []sdk.Subject, subject sdk.Subject) int {
+	for i, s := range subjects {
+		if s.Equal(subject) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_AuthorizedSubjects_Lifecycle(t *testing.T) {
+	cluster := newFakeCluster()
+	db := setupAuthorizedSubjects(t, cluster)
+
+	req := newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`)
+	req.Subject = "CN=billing,OU=Payments,O=Example"
+	created := dbtesting.AssertNewUser(t, db, req)
+
+	assert.Equal(t, []fake.Subject{{CN: "billing", OU: []string{"Payments"}, O: "Example"}}, authorizedSubjects(t, cluster))
+	assert.Equal(t, "certificate", findFakeUser(t, cluster, created.Username).AuthMethod)
+
+	// Password users do not change the authorized_subjects
+	dbtesting.AssertNewUser(t, db, newUserRequest("DB Member", ""))
+	assert.Len(t, authorizedSubjects(t, cluster), 1)
+
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+	assert.Empty(t, authorizedSubjects(t, cluster))
+}
+
+func TestRedisEnterpriseDB_AuthorizedSubjects_rejectsDuplicate(t *testing.T) {
+	cluster := newFakeCluster()
+	db := setupAuthorizedSubjects(t, cluster)
+
+	req := newClientCertificateRequest(`{"acl":"Not Dangerous","credential_type":"client_certificate"}`)
+	dbtesting.AssertNewUser(t, db, req)
+	users, roles := len(cluster.Users()), len(cluster.Roles())
+
+	// The subject would be removed when either user is deleted
+	_, err := db.NewUser(context.Background(), req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subject 'CN=billing,O=Example' is already authorized by database 'mydb'")
+
+	// The role generated for the second user is removed again
+	assert.Len(t, cluster.Users(), users)
+	assert.Len(t, cluster.Roles(), roles)
+	assert.Len(t, authorizedSubjects(t, cluster), 1)
+}
+
+func TestRedisEnterpriseDB_AuthorizedSubjects_rollsBackOnError(t *testing.T) {
+	cluster := newFakeCluster()
+	db := setupAuthorizedSubjects(t, cluster)
+	users := len(cluster.Users())
+
+	cluster.InjectError(http.MethodPost, "/v1/users", http.StatusInternalServerError, 1)
+
+	_, err := db.NewUser(context.Background(), newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`))
+	require.Error(t, err)
+
+	assert.Len(t, cluster.Users(), users)
+	assert.Empty(t, authorizedSubjects(t, cluster))
+}
+
+func TestRedisEnterpriseDB_AuthorizedSubjects_rejectsSubject(t *testing.T) {
+	cluster := newFakeCluster()
+	db := setupAuthorizedSubjects(t, cluster)
+	users := len(cluster.Users())
+
+	req := newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`)
+	req.Subject = "CN=billing,SERIALNUMBER=1234"
+
+	_, err := db.NewUser(context.Background(), req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the subject cannot be authorized by database 'mydb'")
+	assert.Len(t, cluster.Users(), users)
+}
+
+func TestRedisEnterpriseDB_AuthorizedSubjects_Initialize(t *testing.T) {
+	for _, spec := range []struct {
+		name     string
+		version  string
+		database string
+		message  string
+	}{
+		{name: "no database", version: fake.DefaultVersion, message: "the authorized_subjects feature cannot be enabled if there is no database specified"},
+		{name: "old version", version: "6.2.10-100", database: "mydb", message: "the authorized_subjects feature requires Redis Enterprise 6.4.2 or later"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			cluster := newFakeCluster()
+			cluster.SetVersion(spec.version)
+			url := startCluster(t, cluster)
+
+			request := initializeRequest(url, grpcUsername, grpcPassword, spec.database, false)
+			request.Config["features"] = "authorized_subjects"
+
+			db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+			_, err := db.Initialize(context.Background(), request)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+func setupAuthorizedSubjects(t *testing.T, cluster *fake.Cluster) dbplugin.Database {
+	t.Helper()
+
+	url := startCluster(t, cluster)
+
+	request := initializeRequest(url, grpcUsername, grpcPassword, "mydb", false)
+	request.Config["features"] = "acl_only,authorized_subjects"
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, request)
+
+	return db
+}
+
+func authorizedSubjects(t *testing.T, cluster *fake.Cluster) []fake.Subject {
+	t.Helper()
+
+	db, ok := cluster.DatabaseByName("mydb")
+	require.True(t, ok)
+	return db.AuthorizedSubjects
+}
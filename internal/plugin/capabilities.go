@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-version"
+)
+
+// minimumVersion is the oldest version of Redis Enterprise supported by the plugin, which introduced roles, Redis ACLs
+// and the roles_permissions of databases.
+var minimumVersion = version.Must(version.NewVersion("6.0"))
+
+// The capabilities of the cluster which features of the plugin depend on
+const (
+	capabilityTokenAuth       = "token_auth"
+	capabilityCertificateAuth = "certificate_auth"
+)
+
+// capabilityVersions is the version of Redis Enterprise which introduced each capability.
+var capabilityVersions = map[string]*version.Version{
+	capabilityTokenAuth:       version.Must(version.NewVersion("6.2.4")),
+	capabilityCertificateAuth: version.Must(version.NewVersion("6.4.2")),
+}
+
+// capabilities is the set of capabilities of the cluster. A nil set means the capabilities are not known, such as when
+// the connection to the cluster has not been verified, in which case every capability is assumed to be available and
+// the cluster left to reject what it does not support.
+type capabilities map[string]bool
+
+func capabilitiesOf(v *version.Version) capabilities {
+	c := capabilities{}
+	for name, introduced := range capabilityVersions {
+		if v.GreaterThanOrEqual(introduced) {
+			c[name] = true
+		}
+	}
+	return c
+}
+
+func (c capabilities) has(name string) bool {
+	return c == nil || c[name]
+}
+
+func (c capabilities) names() []string {
+	var names []string
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectCapabilities reads the version of the cluster, failing if it is too old to be supported, and records what the
+// cluster is capable of. Any features which have been enabled but need a capability the cluster does not have are
+// reported as errors.
+func (r *redisEnterpriseDB) detectCapabilities(ctx context.Context) error {
+	v, err := r.client.GetVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to find the version of cluster '%s': %w", r.describeCluster(), r.describeError(err, ""))
+	}
+
+	if v.LessThan(minimumVersion) {
+		return fmt.Errorf("cluster '%s' is running Redis Enterprise %s, but the plugin requires %s or later", r.describeCluster(), v, minimumVersion)
+	}
+
+	r.capabilities = capabilitiesOf(v)
+	r.logger.Info("detected cluster", "name", r.clusterName, "version", v.String(), "capabilities", r.capabilities.names())
+
+	if r.config.supportTokenAuth() && !r.capabilities.has(capabilityTokenAuth) {
+		return fmt.Errorf("the token_auth feature requires Redis Enterprise %s or later, but cluster '%s' is running %s", capabilityVersions[capabilityTokenAuth], r.describeCluster(), v)
+	}
+	if r.config.supportAuthorizedSubjects() && !r.capabilities.has(capabilityCertificateAuth) {
+		return fmt.Errorf("the authorized_subjects feature requires Redis Enterprise %s or later, but cluster '%s' is running %s", capabilityVersions[capabilityCertificateAuth], r.describeCluster(), v)
+	}
+
+	return nil
+}
+
+// forgetCluster clears what was detected about the cluster, for when the connection is not going to be verified.
+func (r *redisEnterpriseDB) forgetCluster() {
+	r.clusterName = ""
+	r.capabilities = nil
+}
+
+// enableTokenAuth changes the client to token authentication, if the feature is enabled. It is only called once the
+// capabilities of the cluster have been detected with basic authentication, or are not going to be.
+func (r *redisEnterpriseDB) enableTokenAuth() {
+	if r.config.supportTokenAuth() {
+		r.client.SetTokenAuth(r.config.TokenTTL)
+	}
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilitiesOf(t *testing.T) {
+	c := capabilitiesOf(version.Must(version.NewVersion("6.2.10")))
+
+	assert.Equal(t, []string{capabilityTokenAuth}, c.names())
+	assert.False(t, c.has(capabilityCertificateAuth))
+}
+
+func TestCapabilities_unknownHasEverything(t *testing.T) {
+	var c capabilities
+
+	for name := range capabilityVersions {
+		assert.True(t, c.has(name), name)
+	}
+}
+
+func TestRedisEnterpriseDB_Initialize_detectsCapabilities(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("6.4.2-61")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	_, err := db.Initialize(context.Background(), initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-cluster", db.clusterName)
+	assert.True(t, db.capabilities.has(capabilityCertificateAuth))
+}
+
+func TestRedisEnterpriseDB_Initialize_forgetsCapabilitiesWithoutVerification(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("6.2.10-129")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	request := initializeRequest(url, grpcUsername, grpcPassword, "mydb", false)
+	_, err := db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	require.False(t, db.capabilities.has(capabilityCertificateAuth))
+
+	// The cluster may have changed, so what was detected before no longer applies
+	request.VerifyConnection = false
+	_, err = db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	assert.Empty(t, db.clusterName)
+	assert.Nil(t, db.capabilities)
+}
+
+func TestRedisEnterpriseDB_Initialize_rejectsUnsupportedVersion(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("5.6.0-31")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	_, err := db.Initialize(context.Background(), initializeRequest(url, grpcUsername, grpcPassword, "", false))
+
+	require.Error(t, err)
+	assert.Equal(t, "cluster 'test-cluster' is running Redis Enterprise 5.6.0, but the plugin requires 6.0.0 or later", err.Error())
+}
+
+func TestRedisEnterpriseDB_Initialize_rejectsFeatureWithoutCapability(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("6.0.20-97")
+	url := startCluster(t, cluster)
+
+	request := initializeRequest(url, grpcUsername, grpcPassword, "", false)
+	request.Config["features"] = "token_auth"
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	_, err := db.Initialize(context.Background(), request)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the token_auth feature requires Redis Enterprise 6.2.4 or later")
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/cloud/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The API keys of the account created by newFakeAccount
+const (
+	cloudAPIKey    = "account-key"
+	cloudSecretKey = "user-key"
+)
+
+func TestRedisEnterpriseDB_Cloud_Lifecycle(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		enableACL bool
+		statement string
+		role      string
+	}{
+		{name: "role", statement: `{"role":"DB Member"}`, role: "DB Member"},
+		{name: "role and acl", statement: `{"role":"DB Member","acl":"Not Dangerous"}`, role: "DB Member"},
+		{name: "acl only", enableACL: true, statement: `{"acl":"Full Access"}`, role: "mydb-"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			account := newFakeAccount()
+			db := setupCloud(t, account, spec.enableACL)
+
+			req := newUserRequest("", "")
+			req.Statements.Commands = []string{spec.statement}
+			created := dbtesting.AssertNewUser(t, db, req)
+
+			user := findCloudUser(t, account, created.Username)
+			assert.Equal(t, req.Password, user.Password)
+			assert.Contains(t, user.Role, spec.role)
+
+			if spec.enableACL {
+				role := findCloudRole(t, account, user.Role)
+				require.Len(t, role.RedisRules, 1)
+				assert.Equal(t, "Full Access", role.RedisRules[0].RuleName)
+				require.Len(t, role.RedisRules[0].Databases, 1)
+				assert.Equal(t, "mydb", role.RedisRules[0].Databases[0].DatabaseName)
+			}
+
+			dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+				Username: created.Username,
+				Password: &dbplugin.ChangePassword{NewPassword: "changed"},
+			})
+			assert.Equal(t, "changed", findCloudUser(t, account, created.Username).Password)
+
+			dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+			assert.Empty(t, account.Users())
+			assert.Len(t, account.Roles(), 1, "generated role not deleted")
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Cloud_NewUser_differentBinding(t *testing.T) {
+	account := newFakeAccount()
+	db := setupCloud(t, account, false)
+
+	_, err := db.NewUser(context.Background(), newUserRequest("DB Member", "Full Access"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "different binding")
+	assert.Empty(t, account.Users())
+}
+
+func TestRedisEnterpriseDB_Cloud_Initialize_invalidConfig(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		config  map[string]interface{}
+		message string
+	}{
+		{name: "unknown backend", config: map[string]interface{}{"backend": "garbage"}, message: "backend must be"},
+		{name: "token auth", config: map[string]interface{}{"backend": "cloud", "features": "token_auth"}, message: "token_auth"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			req := initializeRequest("https://localhost", cloudAPIKey, cloudSecretKey, "mydb", false)
+			req.VerifyConnection = false
+			for k, v := range spec.config {
+				req.Config[k] = v
+			}
+
+			subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+			_, err := subject.Initialize(context.Background(), req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Cloud_Initialize_unknownDatabase(t *testing.T) {
+	account := newFakeAccount()
+	url := account.Start()
+	t.Cleanup(account.Close)
+
+	req := initializeRequest(url, cloudAPIKey, cloudSecretKey, "garbage", false)
+	req.Config["backend"] = "cloud"
+
+	mock := &mockSdk{}
+	mock.On("Close").Return(nil)
+
+	subject := newRedis(hclog.NewNullLogger(), mock)
+	_, err := subject.Initialize(context.Background(), req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database 'garbage' does not exist on cluster 'test-account'")
+}
+
+// newFakeAccount creates an account with a database, "mydb", which has the role "DB Member" granting the rule
+// "Not Dangerous". Changes are reported as finished straight away, so the tests do not wait for tasks.
+func newFakeAccount() *fake.Account {
+	account := fake.NewAccount("test-account", cloudAPIKey, cloudSecretKey)
+	account.SetImmediateTasks(true)
+
+	subscription := account.AddSubscription("test")
+	db := account.AddDatabase(subscription, "mydb")
+	rule := account.AddRedisRule("Not Dangerous", "+@all -@dangerous ~*")
+	account.AddRedisRule("Full Access", "+@all ~*")
+	account.AddRole("DB Member", rule, db)
+
+	return account
+}
+
+// setupCloud initialises the plugin with the cloud backend and the database mydb of the account. The plugin starts
+// with a mock client, which is replaced when the backend is configured.
+func setupCloud(t *testing.T, account *fake.Account, enableACL bool) dbplugin.Database {
+	t.Helper()
+
+	url := account.Start()
+	t.Cleanup(account.Close)
+
+	req := initializeRequest(url, cloudAPIKey, cloudSecretKey, "mydb", enableACL)
+	req.Config["backend"] = "cloud"
+
+	mock := &mockSdk{}
+	mock.On("Close").Return(nil)
+
+	db := newRedis(hclog.NewNullLogger(), mock)
+	dbtesting.AssertInitialize(t, db, req)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func findCloudUser(t *testing.T, account *fake.Account, name string) fake.User {
+	t.Helper()
+
+	for _, user := range account.Users() {
+		if user.Name == name {
+			return user
+		}
+	}
+
+	require.Failf(t, "user not found", "user %s does not exist", name)
+	return fake.User{}
+}
+
+func findCloudRole(t *testing.T, account *fake.Account, name string) fake.Role {
+	t.Helper()
+
+	for _, role := range account.Roles() {
+		if role.Name == name {
+			return role
+		}
+	}
+
+	require.Failf(t, "role not found", "role %s does not exist", name)
+	return fake.Role{}
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_NewUser_crdbBindsEveryInstance(t *testing.T) {
+	cluster := newFakeCluster()
+	guid, _ := cluster.AddCRDB("aadb", "other-cluster")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "aadb", true))
+
+	createReq := newUserRequest("", "Not Dangerous")
+	res := dbtesting.AssertNewUser(t, db, createReq)
+
+	crdb, ok := cluster.CRDB(guid)
+	require.True(t, ok)
+	assert.Equal(t, []fake.CRDBRolePermission{{Role: "aadb-" + res.Username, ACL: "Not Dangerous"}}, crdb.DefaultDBConfig.RolePermissions)
+	assertUserHasACL(t, url, grpcUsername, grpcPassword, "aadb", res.Username, "Not Dangerous")
+
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: res.Username})
+
+	crdb, ok = cluster.CRDB(guid)
+	require.True(t, ok)
+	assert.Empty(t, crdb.DefaultDBConfig.RolePermissions)
+	assertRoleDoesNotExists(t, url, grpcUsername, grpcPassword, "aadb-"+res.Username)
+}
+
+func TestRedisEnterpriseDB_NewUser_crdbRollsBackOnError(t *testing.T) {
+	cluster := newFakeCluster()
+	guid, _ := cluster.AddCRDB("aadb", "other-cluster")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "aadb", true))
+
+	cluster.InjectError(http.MethodPost, "/v1/users", http.StatusInternalServerError, 1)
+
+	_, err := db.NewUser(context.Background(), newUserRequest("", "Not Dangerous"))
+	require.Error(t, err)
+
+	crdb, ok := cluster.CRDB(guid)
+	require.True(t, ok)
+	assert.Empty(t, crdb.DefaultDBConfig.RolePermissions)
+	for _, role := range cluster.Roles() {
+		assert.False(t, strings.HasPrefix(role.Name, "aadb-"), "generated role %s was not removed", role.Name)
+	}
+}
+
+func TestRedisEnterpriseDB_NewUser_crdbUnbindFailureIsReported(t *testing.T) {
+	client := &mockSdk{}
+	subject := newRedis(hclog.NewNullLogger(), client)
+	subject.config = config{
+		Database: "aadb",
+		Features: "acl_only",
+	}
+
+	expectedError := errors.New("nope")
+	embeddedError := errors.New("unreachable")
+
+	ctx := testContext(t)
+	crdb := sdk.CRDB{GUID: "guid", DefaultDBConfig: sdk.CRDBConfig{RolePermissions: []sdk.CRDBRolePermission{{Role: "other", ACL: "acl"}}}}
+
+	client.On("FindACLByName", matchesContext(ctx), "expected").Return(&sdk.ACL{UID: 3, Name: "expected"}, nil)
+	client.On("FindDatabaseByName", matchesContext(ctx), "aadb").Return(sdk.Database{UID: 5, Name: "aadb", CRDT: true, CRDTGUID: "guid"}, nil)
+	client.On("CreateRole", matchesContext(ctx), matchesCreateRole("db_member", "aadb", "test", "user")).Return(sdk.Role{UID: 4, Name: "generated"}, nil)
+	client.On("GetCRDB", matchesContext(ctx), "guid").Return(crdb, nil)
+	client.On("UpdateCRDBRolePermissions", matchesContext(ctx), "guid", sdk.UpdateCRDB{DefaultDBConfig: sdk.CRDBConfig{RolePermissions: []sdk.CRDBRolePermission{
+		{Role: "other", ACL: "acl"},
+		{Role: "generated", ACL: "expected"},
+	}}}).Return(nil)
+	client.On("CreateUser", matchesContext(ctx), matchesCreateUser("test", "user", 4, "1234")).Return(sdk.User{}, expectedError)
+	client.On("GetCRDB", context.TODO(), "guid").Return(crdb, embeddedError)
+
+	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "user",
+		},
+		Statements: dbplugin.Statements{
+			Commands: []string{`{"acl": "expected"}`},
+		},
+		Password: "1234",
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, multierror.Append(expectedError, embeddedError), err)
+	client.AssertNotCalled(t, "DeleteRole", context.TODO(), 4)
+}
+
+func TestRedisEnterpriseDB_NewUser_crdbRoleBoundThroughCRDB(t *testing.T) {
+	client := &mockSdk{}
+	subject := newRedis(hclog.NewNullLogger(), client)
+	subject.config = config{Database: "aadb"}
+
+	ctx := testContext(t)
+	crdb := sdk.CRDB{GUID: "guid", DefaultDBConfig: sdk.CRDBConfig{RolePermissions: []sdk.CRDBRolePermission{{Role: "shared", ACL: "acl"}}}}
+
+	client.On("FindRoleByName", matchesContext(ctx), "shared").Return(sdk.Role{UID: 4, Name: "shared"}, nil)
+	client.On("FindDatabaseByName", matchesContext(ctx), "aadb").Return(sdk.Database{UID: 5, Name: "aadb", CRDT: true, CRDTGUID: "guid"}, nil)
+	client.On("GetCRDB", matchesContext(ctx), "guid").Return(crdb, nil)
+	client.On("CreateUser", matchesContext(ctx), matchesCreateUser("test", "user", 4, "1234")).Return(sdk.User{UID: 7}, nil)
+
+	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "user",
+		},
+		Statements: dbplugin.Statements{
+			Commands: []string{`{"role": "shared", "acl": "acl"}`},
+		},
+		Password: "1234",
+	})
+
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestRedisEnterpriseDB_NewUser_crdbRoleBoundOnlyLocally(t *testing.T) {
+	client := &mockSdk{}
+	subject := newRedis(hclog.NewNullLogger(), client)
+	subject.config = config{Database: "aadb"}
+
+	ctx := testContext(t)
+	// The role is bound in the instance of this cluster, but not through the Active-Active database, so the other
+	// instances do not have the binding
+	db := sdk.Database{UID: 5, Name: "aadb", CRDT: true, CRDTGUID: "guid", RolePermissions: []sdk.RolePermission{{RoleUID: 4, ACLUID: 3}}}
+
+	client.On("FindRoleByName", matchesContext(ctx), "shared").Return(sdk.Role{UID: 4, Name: "shared"}, nil)
+	client.On("FindDatabaseByName", matchesContext(ctx), "aadb").Return(db, nil)
+	client.On("GetCRDB", matchesContext(ctx), "guid").Return(sdk.CRDB{GUID: "guid"}, nil)
+
+	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "user",
+		},
+		Statements: dbplugin.Statements{
+			Commands: []string{`{"role": "shared"}`},
+		},
+		Password: "1234",
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "active-active database 'aadb'")
+	assert.Contains(t, err.Error(), "has no binding for role 'shared'")
+	client.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
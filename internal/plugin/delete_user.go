@@ -4,29 +4,63 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// DeleteUser removes a user from the cluster entirely
-func (r *redisEnterpriseDB) DeleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) (dbplugin.DeleteUserResponse, error) {
-	if err := r.findAndDeleteUser(ctx, req.Username); err != nil {
+// DeleteUser removes a user from the cluster entirely or, with a revocation grace period, revokes its access and
+// leaves it to be deleted once the grace period has ended
+func (r *redisEnterpriseDB) DeleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) (_ dbplugin.DeleteUserResponse, err error) {
+	defer func(start time.Time) { recordOperation("delete_user", start, err) }(time.Now())
+	ctx, span := startSpan(ctx, "DeleteUser", attribute.String("username", req.Username))
+	defer func() { endSpan(span, err) }()
+
+	if r.config.RevocationGracePeriod > 0 {
+		if err := r.revoke(ctx, req.Username); err != nil {
+			return dbplugin.DeleteUserResponse{}, err
+		}
+		return dbplugin.DeleteUserResponse{}, nil
+	}
+
+	if err := r.deleteUser(ctx, req.Username, ""); err != nil {
+		return dbplugin.DeleteUserResponse{}, err
+	}
+
+	if err := r.mirror("delete_user", fmt.Sprintf("delete user %s", req.Username), func(secondary *redisEnterpriseDB) error {
+		return secondary.deleteUser(ctx, req.Username, "")
+	}); err != nil {
 		return dbplugin.DeleteUserResponse{}, err
 	}
 
+	return dbplugin.DeleteUserResponse{}, nil
+}
+
+// deleteUser removes the user, and any role generated for it, from the cluster. Users and roles which do not exist
+// are ignored, so it can be retried. The reason is recorded in the audit events.
+func (r *redisEnterpriseDB) deleteUser(ctx context.Context, username string, reason string) error {
+	if r.config.backend() == backendRedis {
+		return r.deleteACLUser(ctx, username)
+	}
+
+	if err := r.findAndDeleteUser(ctx, username, reason); err != nil {
+		return err
+	}
+
 	if r.config.supportAclOnly() {
 		// There's the _possibility_ that a role was created for this user
 
-		if err := r.findAndDeleteRole(ctx, req.Username); err != nil {
-			return dbplugin.DeleteUserResponse{}, err
+		if err := r.findAndDeleteRole(ctx, username, reason); err != nil {
+			return err
 		}
 	}
 
-	return dbplugin.DeleteUserResponse{}, nil
+	return nil
 }
 
-func (r *redisEnterpriseDB) findAndDeleteUser(ctx context.Context, username string) error {
+func (r *redisEnterpriseDB) findAndDeleteUser(ctx context.Context, username string, reason string) error {
 	user, err := r.client.FindUserByName(ctx, username)
 
 	if err != nil {
@@ -38,16 +72,30 @@ func (r *redisEnterpriseDB) findAndDeleteUser(ctx context.Context, username stri
 		return err
 	}
 
+	if r.config.supportAuthorizedSubjects() {
+		if err := r.removeAuthorizedSubject(ctx, user); err != nil {
+			return err
+		}
+	}
+
 	r.logger.Debug("delete user", "user", username, "uid", user.UID)
 
 	if err := r.client.DeleteUser(ctx, user.UID); err != nil {
 		return fmt.Errorf("cannot delete user %s: %w", username, err)
 	}
+	r.quotas.deleted(username)
+
+	r.audit(auditEvent{
+		Type:    auditUserDeleted,
+		User:    username,
+		UserUID: user.UID,
+		Reason:  reason,
+	})
 
 	return nil
 }
 
-func (r redisEnterpriseDB) findAndDeleteRole(ctx context.Context, username string) error {
+func (r redisEnterpriseDB) findAndDeleteRole(ctx context.Context, username string, reason string) error {
 	role, err := r.client.FindRoleByName(ctx, r.generateRoleName(username))
 	if err != nil {
 		if errors.Is(err, &sdk.RoleNotFoundError{}) {
@@ -60,11 +108,30 @@ func (r redisEnterpriseDB) findAndDeleteRole(ctx context.Context, username strin
 
 	r.logger.Debug("delete role", "role", role.Name, "uid", role.UID)
 
+	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	if err != nil {
+		return r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
+	}
+	if db.CRDT {
+		if err := r.unbindCRDBRole(ctx, dbplugin.UsernameMetadata{}, db, role, ""); err != nil {
+			return fmt.Errorf("cannot remove role %s from active-active database %s: %w", role.Name, db.Name, err)
+		}
+	}
+
 	// Found the role with the expected name, so have to assume it was the generated role
 	// Any role permissions associated with the role will be deleted by Redis Enterprise
 	if err := r.client.DeleteRole(ctx, role.UID); err != nil {
 		return err
 	}
 
+	r.audit(auditEvent{
+		Type:     auditRoleDeleted,
+		User:     username,
+		Role:     role.Name,
+		RoleUID:  role.UID,
+		Database: r.config.Database,
+		Reason:   reason,
+	})
+
 	return nil
 }
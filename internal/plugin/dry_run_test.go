@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_dryRun(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+	users, roles := len(cluster.Users()), len(cluster.Roles())
+	before, _ := cluster.DatabaseByName("mydb")
+
+	subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	request := initializeRequest(url, grpcUsername, grpcPassword, "mydb", true)
+	request.Config["dry_run"] = "true"
+	_, err := subject.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	sink := &recordingAuditSink{}
+	subject.auditSink = sink
+
+	ctx := context.Background()
+	for _, statement := range []string{`{"role":"DB Member"}`, `{"acl":"Not Dangerous"}`} {
+		res, err := subject.NewUser(ctx, statementRequest(statement))
+		require.NoError(t, err, statement)
+		assert.NotEmpty(t, res.Username, statement)
+
+		_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: res.Username})
+		require.NoError(t, err, statement)
+	}
+
+	_, err = subject.UpdateUser(ctx, dbplugin.UpdateUserRequest{
+		Username: grpcUsername,
+		Password: &dbplugin.ChangePassword{NewPassword: "rotated"},
+	})
+	assert.ErrorContains(t, err, "cannot be changed with dry_run enabled")
+
+	// Nothing is changed in the cluster
+	assert.Len(t, cluster.Users(), users)
+	assert.Len(t, cluster.Roles(), roles)
+	after, _ := cluster.DatabaseByName("mydb")
+	assert.Equal(t, before.RolePermissions, after.RolePermissions)
+
+	require.NotEmpty(t, sink.events)
+	for _, event := range sink.events {
+		assert.True(t, event.DryRun, event.Type)
+	}
+}
+
+func TestRedisEnterpriseDB_Initialize_dryRunRequiresEnterprise(t *testing.T) {
+	for _, backend := range []string{backendCloud, backendRedis} {
+		subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+		request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+		request.Config["backend"] = backend
+		request.Config["dry_run"] = true
+
+		_, err := subject.Initialize(context.Background(), request)
+		assert.EqualError(t, err, "dry_run cannot be enabled with the "+backend+" backend")
+	}
+}
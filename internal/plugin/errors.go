@@ -0,0 +1,35 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+// describeError explains an error from the cluster in terms of what can be done about it, such as creating something
+// which is missing or checking the credentials of the plugin. The object describes what was being looked up, such as
+// "ACL 'x'", for when it does not exist. Any other errors are returned as they are.
+func (r *redisEnterpriseDB) describeError(err error, object string) error {
+	switch {
+	case err == nil:
+		return nil
+	case object != "" && sdk.IsNotFound(err):
+		return fmt.Errorf("%s does not exist on cluster '%s': %w", object, r.describeCluster(), err)
+	case sdk.IsUnauthorised(err):
+		return fmt.Errorf("the username and password of the plugin were rejected by cluster '%s', or the user does not have permission: %w", r.describeCluster(), err)
+	case sdk.IsRetryable(err):
+		return fmt.Errorf("cluster '%s' is currently unavailable, try again later: %w", r.describeCluster(), err)
+	case sdk.IsValidation(err):
+		return fmt.Errorf("cluster '%s' rejected the request as invalid: %w", r.describeCluster(), err)
+	}
+
+	return err
+}
+
+// describeCluster returns the name of the cluster, or its URL if the name is not known.
+func (r *redisEnterpriseDB) describeCluster() string {
+	if r.clusterName != "" {
+		return r.clusterName
+	}
+	return r.config.Url
+}
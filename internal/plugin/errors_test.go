@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_NewUser_reportsMissingACL(t *testing.T) {
+	client := &mockSdk{}
+	subject := newRedis(hclog.NewNullLogger(), client)
+	subject.clusterName = "mycluster"
+	subject.config = config{
+		Database: "mocked",
+		Features: "acl_only",
+	}
+
+	ctx := testContext(t)
+	client.On("FindACLByName", matchesContext(ctx), "missing").Return((*sdk.ACL)(nil), fmt.Errorf("lookup: %w", &sdk.ACLNotFoundError{}))
+
+	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{
+			DisplayName: "test",
+			RoleName:    "user",
+		},
+		Statements: dbplugin.Statements{
+			Commands: []string{`{"acl": "missing"}`},
+		},
+		Password: "1234",
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ACL 'missing' does not exist on cluster 'mycluster'")
+	assert.True(t, sdk.IsNotFound(err))
+}
+
+func TestRedisEnterpriseDB_NewUser_reportsMissingRole(t *testing.T) {
+	db := setupRedisEnterpriseDB(t, database, false)
+
+	_, err := db.NewUser(context.Background(), newUserRequest("missing", ""))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "role 'missing' does not exist on cluster")
+}
+
+func TestRedisEnterpriseDB_Initialize_reportsRejectedCredentials(t *testing.T) {
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+
+	_, err := db.Initialize(context.Background(), initializeRequest(url, username, "wrong", "", false))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected by cluster")
+	assert.True(t, sdk.IsUnauthorised(err))
+}
+
+func TestRedisEnterpriseDB_Initialize_reportsMissingDatabase(t *testing.T) {
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+
+	_, err := db.Initialize(context.Background(), initializeRequest(url, username, password, "missing", false))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database 'missing' does not exist on cluster")
+}
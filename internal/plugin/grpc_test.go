@@ -0,0 +1,219 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The credentials of the admin user of the cluster created by newFakeCluster
+const (
+	grpcUsername = "admin@example.com"
+	grpcPassword = "xyzzyxyzzy"
+)
+
+func TestGRPC_Type(t *testing.T) {
+	db := servePluginGRPC(t)
+
+	name, err := db.Type()
+	require.NoError(t, err)
+	assert.Equal(t, redisEnterpriseTypeName, name)
+}
+
+func TestGRPC_Initialize_roundTripsConfig(t *testing.T) {
+	url := startGRPCCluster(t)
+	db := servePluginGRPC(t)
+
+	request := initializeRequest(url, grpcUsername, grpcPassword, "mydb", true)
+	request.Config["max_idle_connections"] = "10"
+	request.Config["idle_connection_timeout"] = "30s"
+
+	response := dbtesting.AssertInitialize(t, db, request)
+	for key, value := range request.Config {
+		assert.Equal(t, value, response.Config[key], key)
+	}
+	assert.Equal(t, []interface{}{"password", "client_certificate"}, response.Config[dbplugin.SupportedCredentialTypesKey])
+}
+
+func TestGRPC_Initialize_sanitisesPassword(t *testing.T) {
+	db := servePluginGRPC(t)
+
+	// The password forms part of the URL, so it appears in the error from the failed connection
+	secret := "find-me"
+	request := initializeRequest("redis://127.0.0.1:1/"+secret, grpcUsername, secret, "", false)
+	request.Config["backend"] = "redis"
+
+	_, err := db.Initialize(context.Background(), request)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), secret)
+	assert.Contains(t, err.Error(), "[password]")
+
+	// Errors from HTTP requests are replaced entirely, as they contain the URL
+	request = initializeRequest("https://127.0.0.1:1/"+secret, grpcUsername, secret, "", false)
+
+	_, err = db.Initialize(context.Background(), request)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), secret)
+}
+
+func TestGRPC_Lifecycle(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		database  string
+		enableACL bool
+		statement string
+		role      string
+		acl       string
+	}{
+		{name: "role without database", statement: `{"role":"DB Member"}`, role: "DB Member"},
+		{name: "role with database", database: "mydb", statement: `{"role":"DB Member"}`, role: "DB Member"},
+		{name: "role and acl", database: "mydb", statement: `{"role":"DB Member","acl":"Not Dangerous"}`, role: "DB Member", acl: "Not Dangerous"},
+		{name: "acl only", database: "mydb", enableACL: true, statement: `{"acl":"Not Dangerous"}`, acl: "Not Dangerous"},
+		{name: "acl only with alternative acl", database: "mydb", enableACL: true, statement: `{"acl":"Full Access"}`, acl: "Full Access"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			url := startGRPCCluster(t)
+			db := servePluginGRPC(t)
+			dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, spec.database, spec.enableACL))
+
+			client := sdk.NewClient(hclog.NewNullLogger())
+			client.Initialise(url, grpcUsername, grpcPassword)
+			ctx := context.Background()
+
+			createReq := newUserRequest("", "")
+			createReq.Statements.Commands = []string{spec.statement}
+			created := dbtesting.AssertNewUser(t, db, createReq)
+
+			user, err := client.FindUserByName(ctx, created.Username)
+			require.NoError(t, err)
+			require.Len(t, user.Roles, 1)
+
+			role, err := client.GetRole(ctx, user.Roles[0])
+			require.NoError(t, err)
+			if spec.role != "" {
+				assert.Equal(t, spec.role, role.Name)
+			}
+			if spec.acl != "" {
+				assertUserHasACL(t, url, grpcUsername, grpcPassword, spec.database, created.Username, spec.acl)
+			}
+
+			dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+				Username: created.Username,
+				Password: &dbplugin.ChangePassword{NewPassword: "changed"},
+			})
+
+			dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+			assertUserDoesNotExists(t, url, grpcUsername, grpcPassword, created.Username)
+			if spec.role == "" {
+				// The role generated for the user is removed along with it
+				assertRoleDoesNotExists(t, url, grpcUsername, grpcPassword, role.Name)
+			}
+
+			dbtesting.AssertClose(t, db)
+		})
+	}
+}
+
+func TestGRPC_NewUser_propagatesErrors(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		database  string
+		enableACL bool
+		statement string
+		message   string
+	}{
+		{name: "invalid statement", statement: `{"role":`, message: "cannot parse json"},
+		{name: "no role", statement: `{}`, message: "role"},
+		{name: "unknown role", statement: `{"role":"garbage"}`, message: "garbage"},
+		{name: "acl without database", statement: `{"acl":"Not Dangerous"}`, message: "acl only feature has not been enabled"},
+		{name: "role bound to different acl", database: "mydb", statement: `{"role":"DB Member","acl":"Full Access"}`, message: "different binding"},
+		{name: "unknown acl", database: "mydb", enableACL: true, statement: `{"acl":"garbage"}`, message: "garbage"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			url := startGRPCCluster(t)
+			db := servePluginGRPC(t)
+			dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, spec.database, spec.enableACL))
+
+			createReq := newUserRequest("", "")
+			createReq.Statements.Commands = []string{spec.statement}
+
+			_, err := db.NewUser(context.Background(), createReq)
+			require.Error(t, err)
+			assert.Contains(t, strings.ToLower(err.Error()), strings.ToLower(spec.message))
+		})
+	}
+}
+
+func TestGRPC_UpdateUser_rotateRoot(t *testing.T) {
+	url := startGRPCCluster(t)
+	db := servePluginGRPC(t)
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+
+	dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+		Username: grpcUsername,
+		Password: &dbplugin.ChangePassword{NewPassword: "rotated"},
+	})
+
+	// Vault re-initialises the plugin with the new password once the root credentials have been rotated
+	_, err := db.Initialize(context.Background(), initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+	assert.Error(t, err, "Old root password still accepted")
+
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, "rotated", "mydb", false))
+
+	created := dbtesting.AssertNewUser(t, db, newUserRequest("DB Member", ""))
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+}
+
+func TestGRPC_NewUser_clientCertificate(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+	db := servePluginGRPC(t)
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+
+	// The credential type and subject are carried by the gRPC transport rather than the statement
+	req := newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`)
+	created := dbtesting.AssertNewUser(t, db, req)
+
+	user := findFakeUser(t, cluster, created.Username)
+	assert.Equal(t, "certificate", user.AuthMethod)
+	assert.Equal(t, req.Subject, user.CertificateSubjectLine)
+}
+
+// servePluginGRPC serves the plugin, set up in the same way as the plugin binary, over the dbplugin v5 gRPC transport
+// and returns the client Vault would use to call it.
+func servePluginGRPC(t *testing.T) dbplugin.Database {
+	t.Helper()
+
+	db, err := New()
+	require.NoError(t, err)
+
+	pluginSet := dbplugin.ServeConfig(db).VersionedPlugins[5]
+	// Closing the client also shuts the server down, through the plugin controller
+	client, _ := goplugin.TestPluginGRPCConn(t, false, pluginSet)
+	t.Cleanup(func() { _ = client.Close() })
+
+	raw, err := client.Dispense("database")
+	require.NoError(t, err)
+
+	database, ok := raw.(dbplugin.Database)
+	require.True(t, ok, fmt.Sprintf("unexpected plugin type %T", raw))
+
+	return database
+}
+
+// startGRPCCluster starts a cluster for a single test, so that changes made by the test, such as rotating the root
+// credentials, do not affect any other test.
+func startGRPCCluster(t *testing.T) string {
+	t.Helper()
+
+	return startCluster(t, newFakeCluster())
+}
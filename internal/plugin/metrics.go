@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	prometheussink "github.com/armon/go-metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The prefix of all the metrics emitted by the plugin and the sdk.
+const metricsServiceName = "redisenterprise"
+
+// recordOperation emits the outcome and latency of one of the user operations of the plugin.
+func recordOperation(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	labels := []metrics.Label{{Name: "outcome", Value: outcome}}
+	metrics.IncrCounterWithLabels([]string{"plugin", operation}, 1, labels)
+	metrics.MeasureSinceWithLabels([]string{"plugin", operation, "duration"}, start, labels)
+}
+
+// recordRollback emits whether a generated role was successfully removed after a failure.
+func recordRollback(err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	metrics.IncrCounterWithLabels([]string{"plugin", "role_rollback"}, 1, []metrics.Label{{Name: "outcome", Value: outcome}})
+}
+
+// recordSecondaryFailure emits a change which could not be mirrored to the secondary cluster, and whether the policy
+// failed the operation or only warned.
+func recordSecondaryFailure(operation string, policy string) {
+	labels := []metrics.Label{{Name: "operation", Value: operation}, {Name: "policy", Value: policy}}
+	metrics.IncrCounterWithLabels([]string{"plugin", "secondary_failure"}, 1, labels)
+}
+
+// startMetricsListener sends the metrics to a Prometheus sink, which can be scraped on /metrics at the address.
+// Any previous listener is stopped first.
+func (r *redisEnterpriseDB) startMetricsListener(address string) error {
+	if err := r.stopMetricsListener(); err != nil {
+		return err
+	}
+
+	sink, err := prometheussink.NewPrometheusSink()
+	if err != nil {
+		return fmt.Errorf("unable to create metrics sink: %w", err)
+	}
+
+	// The sink registers itself on the default registry, where a second sink could not be registered. Move it to a
+	// dedicated registry, so the sink can be replaced if the plugin is initialised again
+	prometheus.Unregister(sink)
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(sink); err != nil {
+		return fmt.Errorf("unable to register metrics sink: %w", err)
+	}
+
+	// The runtime metrics are collected by a goroutine which is never stopped, so one would be left behind each time
+	// the plugin is initialised
+	conf := metrics.DefaultConfig(metricsServiceName)
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	if _, err := metrics.NewGlobal(conf, sink); err != nil {
+		return fmt.Errorf("unable to configure metrics: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("unable to listen for metrics on %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	r.metricsServer = &http.Server{Addr: listener.Addr().String(), Handler: mux}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Error("metrics listener stopped", "err", err)
+		}
+	}(r.metricsServer)
+
+	r.logger.Info("serving metrics", "address", listener.Addr().String())
+	return nil
+}
+
+// stopMetricsListener stops serving the metrics, if the listener was started.
+func (r *redisEnterpriseDB) stopMetricsListener() error {
+	if r.metricsServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := r.metricsServer.Shutdown(ctx)
+	r.metricsServer = nil
+	return err
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+func TestRedisEnterpriseDB_Initialize_servesMetrics(t *testing.T) {
+	client := &mockSdk{}
+	db := newRedis(hclog.Default(), client)
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig(""), &metrics.BlackholeSink{})
+	})
+
+	request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+	request.VerifyConnection = false
+	request.Config["metrics_address"] = "127.0.0.1:0"
+
+	client.On("Initialise", "https://localhost:9443", "user", "pass")
+	client.On("SetConnectionPool", 0, mockAnyDuration)
+	client.On("SetDryRun", false)
+	client.On("Close").Return(nil)
+
+	_, err := db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	require.NotNil(t, db.metricsServer)
+
+	ctx := testContext(t)
+	client.On("FindUserByName", matchesContext(ctx), "missing").Return(sdk.User{}, errors.New("not found"))
+	_, err = db.UpdateUser(ctx, dbplugin.UpdateUserRequest{
+		Username: "missing",
+		Password: &dbplugin.ChangePassword{NewPassword: "foo"},
+	})
+	require.Error(t, err)
+
+	res, err := http.Get(fmt.Sprintf("http://%s/metrics", db.metricsServer.Addr))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `redisenterprise_plugin_update_user{outcome="error"} 1`)
+
+	// Vault initialises the plugin again when its configuration is updated
+	_, err = db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	require.NotNil(t, db.metricsServer)
+	address := db.metricsServer.Addr
+
+	// Removing the address stops the listener
+	delete(request.Config, "metrics_address")
+	_, err = db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	assert.Nil(t, db.metricsServer)
+
+	_, err = http.Get(fmt.Sprintf("http://%s/metrics", address))
+	assert.Error(t, err)
+
+	require.NoError(t, db.Close())
+}
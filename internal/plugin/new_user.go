@@ -2,14 +2,14 @@ package plugin
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"time"
 
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
 	"github.com/hashicorp/vault/sdk/database/helper/credsutil"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // NewUser creates a new user and authentication credentials in the cluster.
@@ -28,20 +28,35 @@ import (
 
 // The acl name is must exist the cluster before the user can be created.
 // The acl option can only be used with a database.
+//
+// With the redis backend, the user is created directly in the database with the ACL rules:
+//
+//	{
+//	     "rules" : "~cache:* +@read"
+//	}
+//
+// Users authenticate with a password unless the statement is for client certificates, in which case the user
+// authenticates with the certificate Vault issues for the subject:
+//
+//	{
+//	     "role" : "role_name",
+//	     "credential_type" : "client_certificate"
+//	}
 func (r *redisEnterpriseDB) NewUser(ctx context.Context, req dbplugin.NewUserRequest) (_ dbplugin.NewUserResponse, err error) {
-	r.logger.Debug("new user", "display", req.UsernameConfig.DisplayName, "role", req.UsernameConfig.RoleName, "statements", req.Statements.Commands)
+	defer func(start time.Time) { recordOperation("new_user", start, err) }(time.Now())
+	ctx, span := startSpan(ctx, "NewUser", attribute.String("vault.display_name", req.UsernameConfig.DisplayName), attribute.String("vault.role_name", req.UsernameConfig.RoleName), attribute.String("vault.credential_type", req.CredentialType.String()))
+	defer func() { endSpan(span, err) }()
 
-	if len(req.Statements.Commands) != 1 {
-		return dbplugin.NewUserResponse{}, errors.New("one creation statement is required")
-	}
+	r.logger.Debug("new user", "display", req.UsernameConfig.DisplayName, "role", req.UsernameConfig.RoleName, "statements", req.Statements.Commands)
 
-	var s statement
-	if err := json.Unmarshal([]byte(req.Statements.Commands[0]), &s); err != nil {
-		return dbplugin.NewUserResponse{}, fmt.Errorf("cannot parse JSON for db role: %w", err)
+	s, err := r.parseStatement(req)
+	if err != nil {
+		return dbplugin.NewUserResponse{}, err
 	}
 
-	if !s.hasRole() && !s.hasACL() {
-		return dbplugin.NewUserResponse{}, fmt.Errorf("no 'role' or 'acl' in creation statement for %s", req.UsernameConfig.RoleName)
+	creds, err := r.newCredentials(req, s)
+	if err != nil {
+		return dbplugin.NewUserResponse{}, err
 	}
 
 	// Generate a username which also includes random data (20 characters) and current epoch (11 characters) and the prefix 'v'.
@@ -58,86 +73,243 @@ func (r *redisEnterpriseDB) NewUser(ctx context.Context, req dbplugin.NewUserReq
 		return dbplugin.NewUserResponse{}, fmt.Errorf("cannot generate username: %w", err)
 	}
 
-	if !s.hasRole() && s.hasACL() && !r.config.supportAclOnly() {
-		return dbplugin.NewUserResponse{}, fmt.Errorf("the ACL only feature has not been enabled for %s. You must specify a role name", req.UsernameConfig.RoleName)
+	release, err := r.reserveUser(ctx, req.UsernameConfig.RoleName, username)
+	if err != nil {
+		return dbplugin.NewUserResponse{}, err
 	}
 
-	if !r.config.hasDatabase() && s.hasACL() {
-		return dbplugin.NewUserResponse{}, fmt.Errorf("ACL cannot be used when the database has not been specified for %s", req.UsernameConfig.RoleName)
+	if r.config.backend() == backendRedis {
+		if err := r.createACLUser(ctx, req.UsernameConfig, s, username, req.Password); err != nil {
+			release()
+			return dbplugin.NewUserResponse{}, err
+		}
+		return dbplugin.NewUserResponse{Username: username}, nil
 	}
 
-	var role sdk.Role
+	if err := r.createUser(ctx, req.UsernameConfig, s, username, creds); err != nil {
+		release()
+		return dbplugin.NewUserResponse{}, err
+	}
+	if r.config.DryRun {
+		r.logger.Info("dry run, user not created", "user", username, "role", req.UsernameConfig.RoleName)
+	}
 
-	if s.hasRole() {
-		var err error
-		role, err = r.client.FindRoleByName(ctx, s.Role)
-		if err != nil {
-			return dbplugin.NewUserResponse{}, err
+	if err := r.mirror("new_user", fmt.Sprintf("create user %s", username), func(secondary *redisEnterpriseDB) error {
+		return secondary.createUser(ctx, req.UsernameConfig, s, username, creds)
+	}); err != nil {
+		// Remove the user from the primary cluster again, rather than leave the clusters out of step
+		if rollbackErr := r.deleteUser(context.TODO(), username, ""); rollbackErr != nil {
+			err = multierror.Append(err, fmt.Errorf("unable to remove user %s from cluster '%s': %w", username, r.describeCluster(), rollbackErr))
 		}
+		return dbplugin.NewUserResponse{}, err
+	}
 
-		if r.config.hasDatabase() {
-			db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
-			if err != nil {
-				return dbplugin.NewUserResponse{}, err
-			}
-
-			perm := db.FindPermissionForRole(role.UID)
+	return dbplugin.NewUserResponse{Username: username}, nil
+}
 
-			// If the role specified without an ACL and not bound in the database, this is an error
-			// or
-			// If the role and ACL are specified but unbound in the database, this is an error because it
-			// may cause escalation of privileges for other users with the same role already
-			if perm == nil {
-				return dbplugin.NewUserResponse{}, fmt.Errorf("database %s has no binding for role %s", r.config.Database, s.Role)
-			}
+// createUser creates the user in the cluster with the role, or the ACL through a generated role, in the statement.
+// Any role generated for the user is removed again if the user cannot be created.
+func (r *redisEnterpriseDB) createUser(ctx context.Context, meta dbplugin.UsernameMetadata, s statement, username string, creds credentials) (err error) {
+	if err := r.checkCapabilityFor(creds); err != nil {
+		return err
+	}
 
-			if s.hasACL() {
-				acl, err := r.client.FindACLByName(ctx, s.ACL)
-				if err != nil {
-					return dbplugin.NewUserResponse{}, err
-				}
+	var role sdk.Role
 
-				// If the role and ACL are specified but the binding in the database is different, this is an error
-				if acl.UID != perm.ACLUID {
-					return dbplugin.NewUserResponse{}, fmt.Errorf("database %s has a different binding for role %s", r.config.Database, s.Role)
-				}
-			}
+	if s.hasRole() {
+		role, err = r.findBoundRole(ctx, s)
+		if err != nil {
+			return err
 		}
 	} else if s.hasACL() {
-		role, err = r.generateRole(ctx, s.ACL, r.generateRoleName(username), "db_member")
+		var db sdk.Database
+		role, db, err = r.generateRole(ctx, meta, s.ACL, r.generateRoleName(username), "db_member")
 		if err != nil {
-			return dbplugin.NewUserResponse{}, err
+			return err
 		}
 
-		defer r.cleanUpGeneratedRoleOnError(&err, role)
+		defer r.cleanUpGeneratedRoleOnError(&err, meta, db, role)
+	}
+
+	if creds.authMethod() == authMethodCertificate && r.config.supportAuthorizedSubjects() {
+		if err := r.authorizeSubject(ctx, meta, username, creds.subject); err != nil {
+			return err
+		}
+
+		defer r.cleanUpAuthorizedSubjectOnError(&err, meta, username, creds.subject)
 	}
 
 	// Finally, create the user with the role
-	_, err = r.client.CreateUser(ctx, sdk.CreateUser{
+	user, err := r.client.CreateUser(ctx, sdk.CreateUser{
 		Name:        username,
-		Password:    req.Password,
+		Password:    creds.password,
 		Roles:       []int{role.UID},
 		EmailAlerts: false,
-		AuthMethod:  "regular",
+		AuthMethod:  creds.authMethod(),
+
+		CertificateSubjectLine: creds.subject,
 	})
 	if err != nil {
-		return dbplugin.NewUserResponse{}, err
+		return r.describeError(err, "")
 	}
+	r.quotas.created(username, []int{role.UID})
+
+	r.audit(auditEvent{
+		Type:        auditUserCreated,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		User:        username,
+		UserUID:     user.UID,
+		Subject:     creds.subject,
+		Role:        role.Name,
+		RoleUID:     role.UID,
+		Database:    r.config.Database,
+	})
 
-	return dbplugin.NewUserResponse{Username: username}, nil
+	return nil
+}
+
+// findBoundRole finds the existing role of the statement. If a database is configured, the role must be bound in the
+// database, to the ACL of the statement if it has one.
+func (r *redisEnterpriseDB) findBoundRole(ctx context.Context, s statement) (sdk.Role, error) {
+	role, err := r.client.FindRoleByName(ctx, s.Role)
+	if err != nil {
+		return sdk.Role{}, r.describeError(err, fmt.Sprintf("role '%s'", s.Role))
+	}
+
+	if !r.config.hasDatabase() {
+		return role, nil
+	}
+
+	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	if err != nil {
+		return sdk.Role{}, r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
+	}
+	if db.CRDT {
+		return role, r.checkCRDBBinding(ctx, db, s)
+	}
+
+	perm := db.FindPermissionForRole(role.UID)
+
+	// If the role specified without an ACL and not bound in the database, this is an error
+	// or
+	// If the role and ACL are specified but unbound in the database, this is an error because it
+	// may cause escalation of privileges for other users with the same role already
+	if perm == nil {
+		return sdk.Role{}, fmt.Errorf("database '%s' on cluster '%s' has no binding for role '%s'", r.config.Database, r.describeCluster(), s.Role)
+	}
+
+	if s.hasACL() {
+		acl, err := r.client.FindACLByName(ctx, s.ACL)
+		if err != nil {
+			return sdk.Role{}, r.describeError(err, fmt.Sprintf("ACL '%s'", s.ACL))
+		}
+
+		// If the role and ACL are specified but the binding in the database is different, this is an error
+		if acl.UID != perm.ACLUID {
+			return sdk.Role{}, fmt.Errorf("database '%s' on cluster '%s' has a different binding for role '%s' than ACL '%s'", r.config.Database, r.describeCluster(), s.Role, s.ACL)
+		}
+	}
+
+	return role, nil
+}
+
+// checkCRDBBinding checks the role of the statement is bound in the Active-Active database, to the ACL of the statement
+// if it has one. The binding must be made through the Active-Active database, so it applies to every participating
+// instance rather than only the one in this cluster.
+func (r *redisEnterpriseDB) checkCRDBBinding(ctx context.Context, db sdk.Database, s statement) error {
+	crdb, err := r.client.GetCRDB(ctx, db.CRDTGUID)
+	if err != nil {
+		return r.describeError(err, fmt.Sprintf("active-active database '%s'", r.config.Database))
+	}
+
+	var perm *sdk.CRDBRolePermission
+	for i, permission := range crdb.DefaultDBConfig.RolePermissions {
+		if permission.Role == s.Role {
+			perm = &crdb.DefaultDBConfig.RolePermissions[i]
+			break
+		}
+	}
+
+	if perm == nil {
+		return fmt.Errorf("active-active database '%s' on cluster '%s' has no binding for role '%s'", r.config.Database, r.describeCluster(), s.Role)
+	}
+	if s.hasACL() && perm.ACL != s.ACL {
+		return fmt.Errorf("active-active database '%s' on cluster '%s' has a different binding for role '%s' than ACL '%s'", r.config.Database, r.describeCluster(), s.Role, s.ACL)
+	}
+
+	return nil
+}
+
+// The auth methods of Redis Enterprise users
+const (
+	authMethodRegular     = "regular"
+	authMethodCertificate = "certificate"
+)
+
+// credentials are what a new user authenticates with: a password, or the subject of a client certificate.
+type credentials struct {
+	password string
+	subject  string
+}
+
+func (c credentials) authMethod() string {
+	if c.subject != "" {
+		return authMethodCertificate
+	}
+	return authMethodRegular
+}
+
+// checkCapabilityFor checks the cluster is capable of creating users with the credentials.
+func (r *redisEnterpriseDB) checkCapabilityFor(creds credentials) error {
+	if creds.authMethod() == authMethodCertificate && !r.capabilities.has(capabilityCertificateAuth) {
+		return fmt.Errorf("client certificate credentials require Redis Enterprise %s or later, which cluster '%s' is not running", capabilityVersions[capabilityCertificateAuth], r.describeCluster())
+	}
+	return nil
+}
+
+// newCredentials returns the credentials of the user requested by Vault, which must be of the type the statement is
+// for.
+func (r *redisEnterpriseDB) newCredentials(req dbplugin.NewUserRequest, s statement) (credentials, error) {
+	if req.CredentialType.String() != s.credentialType() {
+		return credentials{}, fmt.Errorf("the creation statement for %s is for %s credentials, but %s credentials were requested", req.UsernameConfig.RoleName, s.credentialType(), req.CredentialType)
+	}
+
+	if req.CredentialType != dbplugin.CredentialTypeClientCertificate {
+		return credentials{password: req.Password}, nil
+	}
+
+	if r.config.backend() != backendEnterprise {
+		return credentials{}, fmt.Errorf("client certificate credentials cannot be used with the %s backend", r.config.backend())
+	}
+	if req.Subject == "" {
+		return credentials{}, fmt.Errorf("no subject for the client certificate of the user for %s", req.UsernameConfig.RoleName)
+	}
+	if r.config.supportAuthorizedSubjects() {
+		if _, err := sdk.ParseSubject(req.Subject); err != nil {
+			return credentials{}, fmt.Errorf("the subject cannot be authorized by database '%s': %w", r.config.Database, err)
+		}
+	}
+
+	return credentials{subject: req.Subject}, nil
 }
 
 func (r redisEnterpriseDB) generateRoleName(username string) string {
 	return r.config.Database + "-" + username
 }
 
-func (r *redisEnterpriseDB) generateRole(ctx context.Context, aclName string, roleName string, roleManagement string) (_ sdk.Role, err error) {
-	r.databaseRolePermissions.Lock()
-	defer r.databaseRolePermissions.Unlock()
+func (r *redisEnterpriseDB) generateRole(ctx context.Context, meta dbplugin.UsernameMetadata, aclName string, roleName string, roleManagement string) (_ sdk.Role, _ sdk.Database, err error) {
+	r.databaseUpdates.Lock()
+	defer r.databaseUpdates.Unlock()
 
 	acl, err := r.client.FindACLByName(ctx, aclName)
 	if err != nil {
-		return sdk.Role{}, err
+		return sdk.Role{}, sdk.Database{}, r.describeError(err, fmt.Sprintf("ACL '%s'", aclName))
+	}
+
+	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	if err != nil {
+		return sdk.Role{}, sdk.Database{}, r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
 	}
 
 	role, err := r.client.CreateRole(ctx, sdk.CreateRole{
@@ -145,51 +317,155 @@ func (r *redisEnterpriseDB) generateRole(ctx context.Context, aclName string, ro
 		Management: roleManagement,
 	})
 	if err != nil {
-		return sdk.Role{}, err
+		return sdk.Role{}, sdk.Database{}, r.describeError(err, "")
 	}
 
-	defer r.cleanUpGeneratedRoleOnError(&err, role)
+	r.audit(auditEvent{
+		Type:        auditRoleCreated,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		Role:        role.Name,
+		RoleUID:     role.UID,
+		Database:    r.config.Database,
+	})
 
-	db, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+	defer r.cleanUpGeneratedRoleOnError(&err, meta, db, role)
+
+	if db.CRDT {
+		err = r.bindCRDBRole(ctx, meta, db, role, acl)
+	} else {
+		err = r.bindDatabaseRole(ctx, meta, db, role, acl)
+	}
 	if err != nil {
-		return sdk.Role{}, err
+		return sdk.Role{}, sdk.Database{}, r.describeError(err, "")
 	}
 
-	permissions := append(db.RolePermissions, sdk.RolePermission{
-		RoleUID: role.UID,
-		ACLUID:  acl.UID,
+	return role, db, nil
+}
+
+// bindDatabaseRole binds the generated role to the ACL in the database.
+func (r *redisEnterpriseDB) bindDatabaseRole(ctx context.Context, meta dbplugin.UsernameMetadata, db sdk.Database, role sdk.Role, acl *sdk.ACL) error {
+	// The lock only prevents concurrent updates from this process, so the binding is added to whatever the database
+	// has at the time of the update, in case other instances of the plugin have changed it since it was read
+	before, after, err := r.client.UpdateDatabaseRolePermissions(ctx, db.UID, func(permissions []sdk.RolePermission) []sdk.RolePermission {
+		return append(permissions, sdk.RolePermission{
+			RoleUID: role.UID,
+			ACLUID:  acl.UID,
+		})
 	})
-	if err := r.client.UpdateDatabaseWithRetry(ctx, db.UID, sdk.UpdateDatabase{
-		RolePermissions: permissions,
-	}); err != nil {
-		return sdk.Role{}, err
+	if err != nil {
+		return err
 	}
 
-	return role, nil
+	r.audit(auditEvent{
+		Type:        auditRolePermissionsUpdated,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		Role:        role.Name,
+		RoleUID:     role.UID,
+		Database:    db.Name,
+		DatabaseUID: db.UID,
+		Before:      before,
+		After:       after,
+	})
+
+	return nil
+}
+
+// bindCRDBRole binds the generated role to the ACL through the Active-Active database, so the binding is applied to
+// every participating instance rather than only the one in this cluster.
+func (r *redisEnterpriseDB) bindCRDBRole(ctx context.Context, meta dbplugin.UsernameMetadata, db sdk.Database, role sdk.Role, acl *sdk.ACL) error {
+	before, after, err := r.client.UpdateCRDBRolePermissions(ctx, db.CRDTGUID, func(permissions []sdk.CRDBRolePermission) []sdk.CRDBRolePermission {
+		return append(permissions, sdk.CRDBRolePermission{
+			Role: role.Name,
+			ACL:  acl.Name,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	r.audit(auditEvent{
+		Type:        auditRolePermissionsUpdated,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		Role:        role.Name,
+		RoleUID:     role.UID,
+		Database:    db.Name,
+		DatabaseUID: db.UID,
+		CRDB:        db.CRDTGUID,
+		CRDBBefore:  before,
+		CRDBAfter:   after,
+	})
+
+	return nil
 }
 
-func (r *redisEnterpriseDB) cleanUpGeneratedRoleOnError(originalErr *error, role sdk.Role) {
+// unbindCRDBRole removes the binding of the generated role from every instance of the Active-Active database. Redis
+// Enterprise only removes the bindings of a deleted role from the instance in the same cluster.
+func (r *redisEnterpriseDB) unbindCRDBRole(ctx context.Context, meta dbplugin.UsernameMetadata, db sdk.Database, role sdk.Role, reason string) error {
+	before, after, err := r.client.UpdateCRDBRolePermissions(ctx, db.CRDTGUID, func(permissions []sdk.CRDBRolePermission) []sdk.CRDBRolePermission {
+		var kept []sdk.CRDBRolePermission
+		for _, permission := range permissions {
+			if permission.Role != role.Name {
+				kept = append(kept, permission)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(before) != len(after) {
+		r.audit(auditEvent{
+			Type:        auditRolePermissionsUpdated,
+			DisplayName: meta.DisplayName,
+			VaultRole:   meta.RoleName,
+			Role:        role.Name,
+			RoleUID:     role.UID,
+			Database:    db.Name,
+			DatabaseUID: db.UID,
+			CRDB:        db.CRDTGUID,
+			CRDBBefore:  before,
+			CRDBAfter:   after,
+			Reason:      reason,
+		})
+	}
+
+	return nil
+}
+
+func (r *redisEnterpriseDB) cleanUpGeneratedRoleOnError(originalErr *error, meta dbplugin.UsernameMetadata, db sdk.Database, role sdk.Role) {
 	if *originalErr == nil {
 		return
 	}
 
 	// Can't use the 'real' context as there's the possibility that the problem is the context timed out
 	// so wouldn't be able to roll back
+	if db.CRDT {
+		if err := r.unbindCRDBRole(context.TODO(), meta, db, role, "rollback"); err != nil {
+			recordRollback(err)
+			*originalErr = multierror.Append(*originalErr, err)
+			return
+		}
+	}
+
 	// Any role permissions associated with the role will be deleted by Redis Enterprise
-	if err := r.client.DeleteRole(context.TODO(), role.UID); err != nil {
+	err := r.client.DeleteRole(context.TODO(), role.UID)
+	recordRollback(err)
+	if err != nil {
 		*originalErr = multierror.Append(*originalErr, err)
+		return
 	}
-}
-
-type statement struct {
-	Role string `json:"role"`
-	ACL  string `json:"acl"`
-}
-
-func (s statement) hasRole() bool {
-	return s.Role != ""
-}
 
-func (s statement) hasACL() bool {
-	return s.ACL != ""
+	r.audit(auditEvent{
+		Type:        auditRoleDeleted,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		Role:        role.Name,
+		RoleUID:     role.UID,
+		Database:    r.config.Database,
+		Reason:      "rollback",
+	})
 }
@@ -187,11 +187,11 @@ func TestRedisEnterpriseDB_NewUser_createUserWithAclFailureRollsBackCorrectly(t
 	expectedError := errors.New("nope")
 	embeddedError := errors.New("failed")
 
-	ctx := context.TODO()
+	ctx := testContext(t)
 
-	client.On("FindACLByName", ctx, "expected").Return(&sdk.ACL{UID: 3}, nil)
+	client.On("FindACLByName", matchesContext(ctx), "expected").Return(&sdk.ACL{UID: 3}, nil)
 	client.On("CreateRole", matchesContext(ctx), matchesCreateRole("db_member", "mocked", "test", "user")).Return(sdk.Role{UID: 4}, nil)
-	client.On("FindDatabaseByName", ctx, "mocked").Return(sdk.Database{
+	database := sdk.Database{
 		UID: 5,
 		RolePermissions: []sdk.RolePermission{
 			{
@@ -199,8 +199,10 @@ func TestRedisEnterpriseDB_NewUser_createUserWithAclFailureRollsBackCorrectly(t
 				ACLUID:  6,
 			},
 		},
-	}, nil)
-	client.On("UpdateDatabaseWithRetry", ctx, 5, sdk.UpdateDatabase{
+	}
+	client.On("FindDatabaseByName", matchesContext(ctx), "mocked").Return(database, nil)
+	client.On("GetDatabase", matchesContext(ctx), 5).Return(database, nil)
+	client.On("UpdateDatabaseRolePermissions", matchesContext(ctx), 5, sdk.UpdateDatabase{
 		RolePermissions: []sdk.RolePermission{
 			{
 				RoleUID: 5,
@@ -213,7 +215,7 @@ func TestRedisEnterpriseDB_NewUser_createUserWithAclFailureRollsBackCorrectly(t
 		},
 	}).Return(nil)
 	client.On("CreateUser", matchesContext(ctx), matchesCreateUser("test", "user", 4, "1234")).Return(sdk.User{}, expectedError)
-	client.On("DeleteRole", ctx, 4).Return(embeddedError)
+	client.On("DeleteRole", context.TODO(), 4).Return(embeddedError)
 
 	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
 		UsernameConfig: dbplugin.UsernameMetadata{
@@ -242,11 +244,11 @@ func TestRedisEnterpriseDB_NewUser_createRoleFailureRollsBackCorrectly(t *testin
 	expectedError := errors.New("broken")
 	embeddedError := errors.New("went wrong")
 
-	ctx := context.TODO()
+	ctx := testContext(t)
 
-	client.On("FindACLByName", ctx, "expected").Return(&sdk.ACL{UID: 3}, nil)
+	client.On("FindACLByName", matchesContext(ctx), "expected").Return(&sdk.ACL{UID: 3}, nil)
 	client.On("CreateRole", matchesContext(ctx), matchesCreateRole("db_member", "mocked", "test", "user")).Return(sdk.Role{UID: 4}, nil)
-	client.On("FindDatabaseByName", ctx, "mocked").Return(sdk.Database{
+	database := sdk.Database{
 		UID: 5,
 		RolePermissions: []sdk.RolePermission{
 			{
@@ -254,8 +256,10 @@ func TestRedisEnterpriseDB_NewUser_createRoleFailureRollsBackCorrectly(t *testin
 				ACLUID:  6,
 			},
 		},
-	}, nil)
-	client.On("UpdateDatabaseWithRetry", ctx, 5, sdk.UpdateDatabase{
+	}
+	client.On("FindDatabaseByName", matchesContext(ctx), "mocked").Return(database, nil)
+	client.On("GetDatabase", matchesContext(ctx), 5).Return(database, nil)
+	client.On("UpdateDatabaseRolePermissions", matchesContext(ctx), 5, sdk.UpdateDatabase{
 		RolePermissions: []sdk.RolePermission{
 			{
 				RoleUID: 5,
@@ -267,7 +271,7 @@ func TestRedisEnterpriseDB_NewUser_createRoleFailureRollsBackCorrectly(t *testin
 			},
 		},
 	}).Return(expectedError)
-	client.On("DeleteRole", ctx, 4).Return(embeddedError)
+	client.On("DeleteRole", context.TODO(), 4).Return(embeddedError)
 
 	_, err := subject.NewUser(ctx, dbplugin.NewUserRequest{
 		UsernameConfig: dbplugin.UsernameMetadata{
@@ -284,8 +288,15 @@ func TestRedisEnterpriseDB_NewUser_createRoleFailureRollsBackCorrectly(t *testin
 	assert.Equal(t, multierror.Append(expectedError, embeddedError), err)
 }
 
+// matchesContext matches the context, or any context the plugin has derived from a context created by testContext
 func matchesContext(ctx context.Context) interface{} {
-	return mock.MatchedBy(func(ctxArg context.Context) bool { return ctxArg == ctx })
+	return mock.MatchedBy(func(ctxArg context.Context) bool {
+		if ctxArg == ctx {
+			return true
+		}
+		name := ctx.Value(testContextKey{})
+		return name != nil && ctxArg.Value(testContextKey{}) == name
+	})
 }
 
 func matchesCreateRole(management string, dbName string, displayName string, roleName string) interface{} {
@@ -341,3 +352,81 @@ func matchesCreateUser(displayName string, roleName string, roleId int, password
 		return true
 	})
 }
+
+func TestRedisEnterpriseDB_NewUser_clientCertificate(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+
+	req := newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`)
+	created := dbtesting.AssertNewUser(t, db, req)
+
+	user := findFakeUser(t, cluster, created.Username)
+	assert.Equal(t, "certificate", user.AuthMethod)
+	assert.Equal(t, "CN=billing,O=Example", user.CertificateSubjectLine)
+	assert.Empty(t, user.Password)
+
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+	for _, u := range cluster.Users() {
+		assert.NotEqual(t, created.Username, u.Name)
+	}
+}
+
+func TestRedisEnterpriseDB_NewUser_rejectsCredentialType(t *testing.T) {
+	url := startCluster(t, newFakeCluster())
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+
+	password := newUserRequest("", "")
+	password.Statements.Commands = []string{`{"role":"DB Member","credential_type":"client_certificate"}`}
+
+	noSubject := newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`)
+	noSubject.Subject = ""
+
+	rsa := newUserRequest("DB Member", "")
+	rsa.CredentialType = dbplugin.CredentialTypeRSAPrivateKey
+
+	for _, spec := range []struct {
+		name    string
+		req     dbplugin.NewUserRequest
+		message string
+	}{
+		{name: "certificate for password statement", req: newClientCertificateRequest(`{"role":"DB Member"}`), message: "is for password credentials, but client_certificate credentials were requested"},
+		{name: "password for certificate statement", req: password, message: "is for client_certificate credentials, but password credentials were requested"},
+		{name: "rsa private key", req: rsa, message: "but rsa_private_key credentials were requested"},
+		{name: "unknown credential type", req: newClientCertificateRequest(`{"role":"DB Member","credential_type":"kerberos"}`), message: "unknown 'credential_type' 'kerberos'"},
+		{name: "no subject", req: noSubject, message: "no subject for the client certificate"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			_, err := db.NewUser(context.Background(), spec.req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_NewUser_clientCertificateRequiresCapability(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("6.2.10-100")
+	url := startCluster(t, cluster)
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+
+	_, err := db.NewUser(context.Background(), newClientCertificateRequest(`{"role":"DB Member","credential_type":"client_certificate"}`))
+	require.Error(t, err)
+	assert.Equal(t, "client certificate credentials require Redis Enterprise 6.4.2 or later, which cluster 'test-cluster' is not running", err.Error())
+	assert.Len(t, cluster.Users(), 1)
+}
+
+func newClientCertificateRequest(statement string) dbplugin.NewUserRequest {
+	req := newUserRequest("", "")
+	req.Statements.Commands = []string{statement}
+	req.Password = ""
+	req.CredentialType = dbplugin.CredentialTypeClientCertificate
+	req.Subject = "CN=billing,O=Example"
+	return req
+}
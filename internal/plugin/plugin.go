@@ -4,21 +4,39 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/cloud"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/redisacl"
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/version"
 	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	goversion "github.com/hashicorp/go-version"
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
 	"github.com/mitchellh/mapstructure"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 const redisEnterpriseTypeName = "redisenterprise"
 
 // Verify interface is implemented
 var _ dbplugin.Database = (*redisEnterpriseDB)(nil)
+var _ sdkClient = (*sdk.Client)(nil)
+var _ sdkClient = (*cloud.Client)(nil)
+var _ aclClient = (*redisacl.Client)(nil)
+
+// The backends the plugin can manage users of
+const (
+	backendEnterprise = "enterprise"
+	backendCloud      = "cloud"
+	backendRedis      = "redis"
+)
 
 // jsonLogging sets whether the logs should be outputted in JSON format or not.
 // This is solely to allow the tests to be able to display messages in a friendly format - Vault needs logging to be in
@@ -30,9 +48,44 @@ type redisEnterpriseDB struct {
 	logger hclog.Logger
 	client sdkClient
 
-	// databaseRolePermissions is used to attempt to avoid buried writes with multiple updates to the database
-	// permissions at the same time, although something may still be updating the database at the same time.
-	databaseRolePermissions *sync.Mutex
+	// backend is the backend the client was created for
+	backend string
+
+	// acl is only set when the redis backend is used, and manages the users of the database directly
+	acl aclClient
+
+	// clusterName is the name of the cluster, and capabilities what it supports, which are only known once the
+	// connection has been verified
+	clusterName  string
+	capabilities capabilities
+
+	// metricsServer is only set when the metrics listener has been enabled
+	metricsServer *http.Server
+
+	// tracerProvider is only set when exporting traces has been enabled
+	tracerProvider *sdktrace.TracerProvider
+
+	// auditSink records every change made to the cluster
+	auditSink auditSink
+
+	// secondary is only set when users are mirrored to a secondary cluster, and isSecondary is set on the plugin
+	// which manages the users of the secondary cluster
+	secondary   *redisEnterpriseDB
+	isSecondary bool
+
+	// newClient creates the client for a backend, when the backend is changed or for the secondary cluster
+	newClient func(logger hclog.Logger, backend string) sdkClient
+
+	// quotas limits the live users the plugin creates
+	quotas *quotas
+
+	// revocations runs the sweeper deleting the revoked users once their grace period has ended
+	revocations *revocations
+
+	// databaseUpdates is used to attempt to avoid buried writes with multiple updates to the database
+	// roles_permissions or authorized_subjects at the same time, although something may still be updating the
+	// database at the same time.
+	databaseUpdates *sync.Mutex
 }
 
 func New() (dbplugin.Database, error) {
@@ -44,18 +97,33 @@ func New() (dbplugin.Database, error) {
 		JSONFormat: jsonLogging,
 	})
 
-	client := sdk.NewClient(logger)
+	return NewWithLogger(logger), nil
+}
 
-	db := newRedis(logger, client)
-	return wrapWithSanitizerMiddleware(db), nil
+// NewWithLogger creates the plugin with the logger, so its operations can be run outside of Vault.
+func NewWithLogger(logger hclog.Logger) dbplugin.Database {
+	db := newRedis(logger, sdk.NewClient(logger))
+	return wrapWithSanitizerMiddleware(db)
 }
 
 func newRedis(logger hclog.Logger, client sdkClient) *redisEnterpriseDB {
 	return &redisEnterpriseDB{
-		logger:                  logger,
-		client:                  client,
-		databaseRolePermissions: &sync.Mutex{},
+		logger:          logger,
+		client:          client,
+		auditSink:       multiAuditSink{},
+		databaseUpdates: &sync.Mutex{},
+		quotas:          newQuotas(),
+		revocations:     newRevocations(),
+		backend:         backendEnterprise,
+		newClient:       newClient,
+	}
+}
+
+func newClient(logger hclog.Logger, backend string) sdkClient {
+	if backend == backendCloud {
+		return cloud.NewClient(logger)
 	}
+	return sdk.NewClient(logger)
 }
 
 func wrapWithSanitizerMiddleware(db *redisEnterpriseDB) dbplugin.Database {
@@ -66,18 +134,28 @@ func wrapWithSanitizerMiddleware(db *redisEnterpriseDB) dbplugin.Database {
 func (r *redisEnterpriseDB) secretValues() map[string]string {
 
 	// mask secret values in the configuration
-	return map[string]string{
+	values := map[string]string{
 		r.config.Password: "[password]",
 	}
+	if r.config.SecondaryPassword != "" {
+		values[r.config.SecondaryPassword] = "[secondary_password]"
+	}
+	return values
 }
 
-// Initialize copies the configuration information and does a GET on /v1/cluster
-// to ensure the cluster is reachable
-func (r *redisEnterpriseDB) Initialize(ctx context.Context, req dbplugin.InitializeRequest) (dbplugin.InitializeResponse, error) {
+// Initialize copies the configuration information and, if the connection is to be verified, does a GET on /v1/cluster
+// to ensure the cluster is reachable and checks the configured user has the privileges the plugin needs. The same
+// checks are made of the secondary cluster, if one is configured.
+func (r *redisEnterpriseDB) Initialize(ctx context.Context, req dbplugin.InitializeRequest) (_ dbplugin.InitializeResponse, err error) {
+	ctx, span := startSpan(ctx, "Initialize", attribute.Bool("verify_connection", req.VerifyConnection))
+	defer func() { endSpan(span, err) }()
 
 	r.logger.Info("initialising plugin", "version", version.Version, "commit", version.GitCommit)
 
-	if err := mapstructure.WeakDecode(req.Config, &r.config); err != nil {
+	// The sweeper uses the configuration, so it is stopped while the configuration changes
+	r.stopSweeper()
+
+	if err := decodeConfig(req.Config, &r.config); err != nil {
 		return dbplugin.InitializeResponse{}, err
 	}
 
@@ -95,45 +173,312 @@ func (r *redisEnterpriseDB) Initialize(ctx context.Context, req dbplugin.Initial
 	if !r.config.hasDatabase() && r.config.supportAclOnly() {
 		return dbplugin.InitializeResponse{}, errors.New("the acl_only feature cannot be enabled if there is no database specified")
 	}
+	if !r.config.hasDatabase() && r.config.supportAuthorizedSubjects() {
+		return dbplugin.InitializeResponse{}, errors.New("the authorized_subjects feature cannot be enabled if there is no database specified")
+	}
 
-	r.client.Initialise(r.config.Url, r.config.Username, r.config.Password)
+	if r.config.MaxIdleConnections < 0 {
+		return dbplugin.InitializeResponse{}, errors.New("max_idle_connections cannot be negative")
+	}
+	if r.config.IdleConnectionTimeout < 0 {
+		return dbplugin.InitializeResponse{}, errors.New("idle_connection_timeout cannot be negative")
+	}
+	if r.config.TokenTTL < 0 {
+		return dbplugin.InitializeResponse{}, errors.New("token_ttl cannot be negative")
+	}
+	if r.config.RevocationGracePeriod < 0 {
+		return dbplugin.InitializeResponse{}, errors.New("revocation_grace_period cannot be negative")
+	}
+	if r.config.MaxUsers < 0 || r.config.MaxUsersPerDatabase < 0 || r.config.MaxUsersPerRole < 0 || r.config.MaxNewUsersPerMinute < 0 {
+		return dbplugin.InitializeResponse{}, errors.New("max_users, max_users_per_database, max_users_per_role and max_new_users_per_minute cannot be negative")
+	}
+	if !r.config.hasDatabase() && r.config.MaxUsersPerDatabase > 0 {
+		return dbplugin.InitializeResponse{}, errors.New("max_users_per_database cannot be set if there is no database specified")
+	}
+	switch r.config.backend() {
+	case backendEnterprise:
+	case backendCloud:
+		if r.config.DryRun {
+			return dbplugin.InitializeResponse{}, errors.New("dry_run cannot be enabled with the cloud backend")
+		}
+		if r.config.RevocationGracePeriod > 0 {
+			return dbplugin.InitializeResponse{}, errors.New("revocation_grace_period cannot be set with the cloud backend, as a user cannot be left without a role")
+		}
+		if r.config.supportTokenAuth() {
+			return dbplugin.InitializeResponse{}, errors.New("the token_auth feature cannot be enabled with the cloud backend")
+		}
+		if r.config.supportAuthorizedSubjects() {
+			return dbplugin.InitializeResponse{}, errors.New("the authorized_subjects feature cannot be enabled with the cloud backend")
+		}
+	case backendRedis:
+		if r.config.Features != "" {
+			return dbplugin.InitializeResponse{}, errors.New("features cannot be enabled with the redis backend")
+		}
+		if r.config.hasDatabase() {
+			return dbplugin.InitializeResponse{}, errors.New("the database cannot be set with the redis backend, the url is of the database")
+		}
+		if r.config.DryRun {
+			return dbplugin.InitializeResponse{}, errors.New("dry_run cannot be enabled with the redis backend")
+		}
+		if r.config.hasUserQuotas() {
+			return dbplugin.InitializeResponse{}, errors.New("max_users and max_users_per_role cannot be set with the redis backend, only max_new_users_per_minute")
+		}
+		if r.config.RevocationGracePeriod > 0 {
+			return dbplugin.InitializeResponse{}, errors.New("revocation_grace_period cannot be set with the redis backend")
+		}
+	default:
+		return dbplugin.InitializeResponse{}, fmt.Errorf("backend must be '%s', '%s' or '%s'", backendEnterprise, backendCloud, backendRedis)
+	}
+
+	if r.config.backend() == backendRedis {
+		if r.acl == nil {
+			r.acl = redisacl.NewClient(r.logger)
+		}
+		if err := r.acl.Initialise(r.config.Url, r.config.Username, r.config.Password); err != nil {
+			return dbplugin.InitializeResponse{}, err
+		}
+		r.acl.SetConnectionPool(r.config.MaxIdleConnections, r.config.IdleConnectionTimeout)
+	} else {
+		if r.backend != r.config.backend() {
+			if err := r.client.Close(); err != nil {
+				r.logger.Warn("unable to close previous client", "err", err)
+			}
+			r.client = r.newClient(r.logger, r.config.backend())
+			r.backend = r.config.backend()
+		}
+
+		r.client.Initialise(r.config.Url, r.config.Username, r.config.Password)
+		r.client.SetConnectionPool(r.config.MaxIdleConnections, r.config.IdleConnectionTimeout)
+		r.client.SetDryRun(r.config.DryRun)
+		if r.config.DryRun {
+			r.logger.Warn("dry run enabled, changes to the cluster are logged rather than made")
+		}
+	}
+
+	// The live users may be of another cluster, or counted for another database
+	r.quotas.reset()
+
+	if r.config.MetricsAddress != "" {
+		if err := r.startMetricsListener(r.config.MetricsAddress); err != nil {
+			return dbplugin.InitializeResponse{}, err
+		}
+	} else if err := r.stopMetricsListener(); err != nil {
+		r.logger.Warn("unable to stop previous metrics listener", "err", err)
+	}
+
+	sink, err := newAuditSink(r.config)
+	if err != nil {
+		return dbplugin.InitializeResponse{}, err
+	}
+	if err := r.auditSink.Close(); err != nil {
+		r.logger.Warn("unable to close previous audit sink", "err", err)
+	}
+	r.auditSink = sink
+
+	if err := r.initialiseSecondary(); err != nil {
+		return dbplugin.InitializeResponse{}, err
+	}
+
+	if r.config.TracingEndpoint != "" {
+		if err := r.startTracing(ctx, r.config.TracingEndpoint); err != nil {
+			return dbplugin.InitializeResponse{}, err
+		}
+	} else if err := r.stopTracing(ctx); err != nil {
+		r.logger.Warn("unable to stop previous trace exporter", "err", err)
+	}
 
 	// Verify the connection to the database if requested.
 	if req.VerifyConnection {
-		_, err := r.client.GetCluster(ctx)
-		if err != nil {
-			return dbplugin.InitializeResponse{}, fmt.Errorf("could not verify connection to cluster: %w", err)
+		if err := r.verifyConnection(ctx); err != nil {
+			return dbplugin.InitializeResponse{}, err
 		}
 
-		if r.config.hasDatabase() {
-			_, err := r.client.FindDatabaseByName(ctx, r.config.Database)
-			if err != nil {
-				return dbplugin.InitializeResponse{}, fmt.Errorf("could not verify connection to cluster: %w", err)
+		if r.secondary != nil {
+			if err := r.secondary.verifyConnection(ctx); err != nil {
+				return dbplugin.InitializeResponse{}, fmt.Errorf("secondary cluster: %w", err)
 			}
 		}
+	} else {
+		// The capabilities of the clusters are not known, and any detected before may be of another cluster, so the
+		// clusters are left to reject token authentication
+		r.forgetCluster()
+		r.enableTokenAuth()
+		if r.secondary != nil {
+			r.secondary.forgetCluster()
+			r.secondary.enableTokenAuth()
+		}
+	}
+
+	if r.config.RevocationGracePeriod > 0 {
+		r.startSweeper()
 	}
 
 	response := dbplugin.InitializeResponse{
 		Config: req.Config,
 	}
+	response.SetSupportedCredentialTypes(r.config.credentialTypes())
 
 	return response, nil
 }
 
+// verifyConnection ensures the cluster is reachable, supported by the plugin, and that the configured user has the
+// privileges the plugin needs.
+func (r *redisEnterpriseDB) verifyConnection(ctx context.Context) error {
+	if r.config.backend() == backendRedis {
+		return r.verifyACLConnection(ctx)
+	}
+
+	cluster, err := r.client.GetCluster(ctx)
+	if err != nil {
+		return fmt.Errorf("could not verify connection to cluster: %w", r.describeError(err, ""))
+	}
+	r.clusterName = cluster.Name
+
+	// Redis Cloud does not report its version, and is always up to date
+	if r.backend == backendEnterprise {
+		if err := r.detectCapabilities(ctx); err != nil {
+			return err
+		}
+		r.enableTokenAuth()
+	}
+
+	var db sdk.Database
+	if r.config.hasDatabase() {
+		db, err = r.client.FindDatabaseByName(ctx, r.config.Database)
+		if err != nil {
+			return fmt.Errorf("could not verify connection to cluster: %w", r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database)))
+		}
+	}
+
+	// The API keys of Redis Cloud are not limited by the management roles of a user
+	if r.backend == backendCloud {
+		return nil
+	}
+
+	return r.verifyPrivileges(ctx, db)
+}
+
 func (r *redisEnterpriseDB) Type() (string, error) {
 	return redisEnterpriseTypeName, nil
 }
 
 func (r *redisEnterpriseDB) Close() error {
-	return r.client.Close()
+	r.stopSweeper()
+
+	var result error
+	if err := r.stopMetricsListener(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := r.stopTracing(context.Background()); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := r.auditSink.Close(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := r.client.Close(); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if r.acl != nil {
+		if err := r.acl.Close(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	if r.secondary != nil {
+		if err := r.secondary.client.Close(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
 }
 
 type config struct {
+	// Backend is either enterprise (the default) for a Redis Enterprise cluster, cloud for a Redis Cloud account, in
+	// which case Username and Password are the API keys of the account and the user, or redis for a single database
+	// whose users are managed with the ACL commands, in which case Url is the redis:// or rediss:// URL of the database.
+	Backend string `mapstructure:"backend,omitempty"`
+
 	Features string `mapstructure:"features,omitempty"`
 	Database string `mapstructure:"database,omitempty"`
 	Username string `mapstructure:"username,omitempty"`
 	Password string `mapstructure:"password,omitempty"`
 	Url      string `mapstructure:"url,omitempty"`
+
+	// MaxIdleConnections and IdleConnectionTimeout control the pool of connections kept open to the cluster API.
+	// Zero values use the defaults of the sdk client.
+	MaxIdleConnections    int           `mapstructure:"max_idle_connections,omitempty"`
+	IdleConnectionTimeout time.Duration `mapstructure:"idle_connection_timeout,omitempty"`
+
+	// TokenTTL is the lifetime of the API tokens requested when the token_auth feature is enabled.
+	TokenTTL time.Duration `mapstructure:"token_ttl,omitempty"`
+
+	// MetricsAddress is the address, e.g. 127.0.0.1:9102, of the listener serving the metrics to be scraped by
+	// Prometheus. The listener is disabled if not set.
+	MetricsAddress string `mapstructure:"metrics_address,omitempty"`
+
+	// TracingEndpoint is the URL, e.g. http://otel-collector:4318, of the OTLP/HTTP endpoint the traces are exported
+	// to. Traces are not exported if not set.
+	TracingEndpoint string `mapstructure:"tracing_endpoint,omitempty"`
+
+	// AuditFile is the path of a file every change to the cluster is appended to as a line of JSON, and AuditSyslog
+	// sends the same events to the local syslog.
+	AuditFile   string `mapstructure:"audit_file,omitempty"`
+	AuditSyslog bool   `mapstructure:"audit_syslog,omitempty"`
+
+	// The secondary cluster, such as a disaster recovery cluster, which every user is mirrored to. SecondaryDatabase
+	// defaults to Database, and SecondaryPolicy is either fail (the default) or warn.
+	SecondaryUrl      string `mapstructure:"secondary_url,omitempty"`
+	SecondaryUsername string `mapstructure:"secondary_username,omitempty"`
+	SecondaryPassword string `mapstructure:"secondary_password,omitempty"`
+	SecondaryDatabase string `mapstructure:"secondary_database,omitempty"`
+	SecondaryPolicy   string `mapstructure:"secondary_policy,omitempty"`
+
+	// MaxUsers, MaxUsersPerDatabase and MaxUsersPerRole limit the live users generated by the plugin in the cluster,
+	// in the configured database and for each Vault role, and MaxNewUsersPerMinute how many users are created in a
+	// minute. Zero values are unlimited.
+	MaxUsers             int `mapstructure:"max_users,omitempty"`
+	MaxUsersPerDatabase  int `mapstructure:"max_users_per_database,omitempty"`
+	MaxUsersPerRole      int `mapstructure:"max_users_per_role,omitempty"`
+	MaxNewUsersPerMinute int `mapstructure:"max_new_users_per_minute,omitempty"`
+
+	// RevocationGracePeriod is how long a user is kept after Vault revokes it, with no roles and a random password, before
+	// it is deleted. Users are deleted straight away if it is not set.
+	RevocationGracePeriod time.Duration `mapstructure:"revocation_grace_period,omitempty"`
+
+	// DryRun logs every change the plugin would make to the cluster, with any secret redacted, rather than making it.
+	// New users are not created, although their usernames are returned to Vault.
+	DryRun bool `mapstructure:"dry_run,omitempty"`
+}
+
+// decodeConfig decodes the raw configuration from Vault, accepting durations as strings such as "90s".
+func decodeConfig(raw map[string]interface{}, c *config) error {
+	// Decode from scratch, so a setting removed when the plugin is initialised again goes back to its default
+	*c = config{}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           c,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(raw)
+}
+
+func (c config) backend() string {
+	if c.Backend == "" {
+		return backendEnterprise
+	}
+	return c.Backend
+}
+
+// credentialTypes returns the types of credential the backend can create users with.
+func (c config) credentialTypes() []dbplugin.CredentialType {
+	if c.backend() == backendEnterprise {
+		return []dbplugin.CredentialType{dbplugin.CredentialTypePassword, dbplugin.CredentialTypeClientCertificate}
+	}
+	return []dbplugin.CredentialType{dbplugin.CredentialTypePassword}
 }
 
 func (c config) hasDatabase() bool {
@@ -154,22 +499,57 @@ func (c config) hasFeature(name string) bool {
 	return false
 }
 
+// hasUserQuotas returns whether any of the quotas on the live users is set.
+func (c config) hasUserQuotas() bool {
+	return c.MaxUsers > 0 || c.MaxUsersPerDatabase > 0 || c.MaxUsersPerRole > 0
+}
+
 func (c config) supportAclOnly() bool {
 	return c.hasFeature("acl_only")
 }
 
+func (c config) supportTokenAuth() bool {
+	return c.hasFeature("token_auth")
+}
+
+func (c config) supportAuthorizedSubjects() bool {
+	return c.hasFeature("authorized_subjects")
+}
+
 type sdkClient interface {
 	Initialise(url string, username string, password string)
+	SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration)
+	SetTokenAuth(ttl time.Duration)
+	SetDryRun(dryRun bool)
 	Close() error
 	FindACLByName(ctx context.Context, name string) (*sdk.ACL, error)
 	GetCluster(ctx context.Context) (sdk.Cluster, error)
-	UpdateDatabaseWithRetry(ctx context.Context, id int, update sdk.UpdateDatabase) error
+	GetVersion(ctx context.Context) (*goversion.Version, error)
+	UpdateDatabaseRolePermissions(ctx context.Context, id int, update func([]sdk.RolePermission) []sdk.RolePermission) ([]sdk.RolePermission, []sdk.RolePermission, error)
 	FindDatabaseByName(ctx context.Context, name string) (sdk.Database, error)
+	ValidateDatabaseUpdate(ctx context.Context, id int, update sdk.UpdateDatabase) error
+	GetCRDB(ctx context.Context, guid string) (sdk.CRDB, error)
+	UpdateCRDBRolePermissions(ctx context.Context, guid string, update func([]sdk.CRDBRolePermission) []sdk.CRDBRolePermission) ([]sdk.CRDBRolePermission, []sdk.CRDBRolePermission, error)
+	UpdateDatabaseAuthorizedSubjects(ctx context.Context, id int, update func([]sdk.Subject) []sdk.Subject) ([]sdk.Subject, []sdk.Subject, error)
 	CreateRole(ctx context.Context, create sdk.CreateRole) (sdk.Role, error)
 	DeleteRole(ctx context.Context, id int) error
+	GetRole(ctx context.Context, id int) (sdk.Role, error)
 	FindRoleByName(ctx context.Context, name string) (sdk.Role, error)
 	CreateUser(ctx context.Context, create sdk.CreateUser) (sdk.User, error)
+	ListUsers(ctx context.Context) ([]sdk.User, error)
 	UpdateUserPassword(ctx context.Context, id int, update sdk.UpdateUser) error
+	RevokeUser(ctx context.Context, id int, name string, password string) error
 	DeleteUser(ctx context.Context, id int) error
 	FindUserByName(ctx context.Context, name string) (sdk.User, error)
 }
+
+// aclClient manages the users of a database directly with the ACL commands, for the redis backend.
+type aclClient interface {
+	Initialise(url string, username string, password string) error
+	SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration)
+	Close() error
+	WhoAmI(ctx context.Context) (string, error)
+	SetUser(ctx context.Context, name string, rules ...string) error
+	UserExists(ctx context.Context, name string) (bool, error)
+	DelUser(ctx context.Context, name string) (bool, error)
+}
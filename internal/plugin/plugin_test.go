@@ -92,8 +92,8 @@ func TestRedisEnterpriseDB_SecretValues_passwordSanitisedFromErrors(t *testing.T
 	db.config.Password = "find-me"
 	subject := wrapWithSanitizerMiddleware(db)
 
-	ctx := context.Background()
-	client.On("FindUserByName", ctx, "expected-user").Return(sdk.User{}, fmt.Errorf("some error containing the password %s", db.config.Password))
+	ctx := testContext(t)
+	client.On("FindUserByName", matchesContext(ctx), "expected-user").Return(sdk.User{}, fmt.Errorf("some error containing the password %s", db.config.Password))
 
 	_, err := subject.UpdateUser(ctx, dbplugin.UpdateUserRequest{
 		Username: "expected-user",
@@ -103,6 +103,62 @@ func TestRedisEnterpriseDB_SecretValues_passwordSanitisedFromErrors(t *testing.T
 	assert.Error(t, err, "some error containing the password [password]")
 }
 
+func TestRedisEnterpriseDB_Initialize_configuresConnectionPool(t *testing.T) {
+	client := &mockSdk{}
+	db := newRedis(hclog.Default(), client)
+
+	request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+	request.VerifyConnection = false
+	request.Config["max_idle_connections"] = "10"
+	request.Config["idle_connection_timeout"] = "30s"
+
+	client.On("Initialise", "https://localhost:9443", "user", "pass")
+	client.On("SetConnectionPool", 10, 30*time.Second).Once()
+	client.On("SetDryRun", false)
+
+	_, err := db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	// Removing the settings when the plugin is initialised again restores the defaults
+	delete(request.Config, "max_idle_connections")
+	delete(request.Config, "idle_connection_timeout")
+	client.On("SetConnectionPool", 0, time.Duration(0)).Once()
+
+	_, err = db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	client.AssertExpectations(t)
+}
+
+func TestRedisEnterpriseDB_Initialize_enablesTokenAuth(t *testing.T) {
+	client := &mockSdk{}
+	db := newRedis(hclog.Default(), client)
+
+	request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+	request.VerifyConnection = false
+	request.Config["features"] = "token_auth"
+	request.Config["token_ttl"] = "10m"
+
+	client.On("Initialise", "https://localhost:9443", "user", "pass")
+	client.On("SetConnectionPool", 0, time.Duration(0))
+	client.On("SetDryRun", false)
+	client.On("SetTokenAuth", 10*time.Minute)
+
+	_, err := db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	client.AssertExpectations(t)
+}
+
+func TestRedisEnterpriseDB_Initialize_shouldErrorWithNegativePool(t *testing.T) {
+	request := initializeRequest(url, username, password, "", false)
+	request.Config["max_idle_connections"] = -1
+	db := newRedis(hclog.Default(), sdk.NewClient(hclog.Default()))
+
+	_, err := db.Initialize(context.Background(), request)
+	assert.Error(t, err, "Failed to detect negative max_idle_connections")
+}
+
 func assertUserExists(t *testing.T, url string, username string, password string, generatedUser string) {
 	t.Helper()
 	client := sdk.NewClient(hclog.Default())
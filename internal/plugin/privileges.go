@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+// privilege is something the plugin needs to be able to do in the cluster, and the management levels of the roles
+// which allow it, from the least privileged. The management level of a user's own role, from before roles were
+// introduced, is treated the same.
+type privilege struct {
+	description string
+	management  []string
+
+	// feature is set if the privilege is only needed when the feature is enabled
+	feature string
+}
+
+var privileges = []privilege{
+	{
+		description: "create, update and delete users",
+		management:  []string{"user_manager", "admin"},
+	},
+	{
+		description: "create and delete roles",
+		management:  []string{"user_manager", "admin"},
+		feature:     "acl_only",
+	},
+	{
+		description: "update the roles_permissions of databases",
+		management:  []string{"user_manager", "cluster_member", "admin"},
+		feature:     "acl_only",
+	},
+	{
+		description: "update the authorized_subjects of databases",
+		management:  []string{"db_member", "cluster_member", "admin"},
+		feature:     "authorized_subjects",
+	},
+}
+
+// verifyPrivileges checks the configured user is able to do everything the plugin needs to do in the cluster, given the
+// features enabled. The database is only needed if the acl_only feature is enabled, to check it accepts updates to its
+// roles_permissions. All the missing privileges are reported together.
+func (r *redisEnterpriseDB) verifyPrivileges(ctx context.Context, db sdk.Database) error {
+	user, err := r.client.FindUserByName(ctx, r.config.Username)
+	if err != nil {
+		return fmt.Errorf("unable to look up the privileges of user '%s': %w", r.config.Username, r.describeError(err, fmt.Sprintf("user '%s'", r.config.Username)))
+	}
+
+	management := map[string]bool{}
+	if user.Role != "" {
+		management[user.Role] = true
+	}
+	for _, uid := range user.Roles {
+		role, err := r.client.GetRole(ctx, uid)
+		if err != nil {
+			return fmt.Errorf("unable to look up the privileges of user '%s': %w", r.config.Username, r.describeError(err, ""))
+		}
+		management[role.Management] = true
+	}
+
+	var missing []string
+	for _, p := range privileges {
+		if p.feature != "" && !r.config.hasFeature(p.feature) {
+			continue
+		}
+		if !allowedBy(p, management) {
+			missing = append(missing, p.description)
+		}
+	}
+
+	if r.config.supportAclOnly() {
+		// Updating the database with its current bindings shows whether it accepts changes to them at all
+		if err := r.client.ValidateDatabaseUpdate(ctx, db.UID, sdk.UpdateDatabase{RolePermissions: db.RolePermissions}); err != nil {
+			missing = append(missing, fmt.Sprintf("update the roles_permissions of database '%s' (%s)", db.Name, err))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("user '%s' is missing privileges needed by the plugin on cluster '%s':\n\t* %s", r.config.Username, r.describeCluster(), strings.Join(missing, "\n\t* "))
+	}
+
+	return nil
+}
+
+// ManagementFor returns the management levels of the roles a user needs for the plugin to be able to do everything it
+// needs to with the features enabled, choosing the least privileged level for each privilege not already allowed.
+func ManagementFor(features string) []string {
+	c := config{Features: features}
+
+	var levels []string
+	management := map[string]bool{}
+	for _, p := range privileges {
+		if p.feature != "" && !c.hasFeature(p.feature) {
+			continue
+		}
+		if !allowedBy(p, management) {
+			levels = append(levels, p.management[0])
+			management[p.management[0]] = true
+		}
+	}
+	return levels
+}
+
+func allowedBy(p privilege, management map[string]bool) bool {
+	for _, m := range p.management {
+		if management[m] {
+			return true
+		}
+	}
+	return false
+}
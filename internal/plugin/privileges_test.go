@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_Initialize_verifiesPrivileges(t *testing.T) {
+	for _, spec := range []struct {
+		name       string
+		management string
+		roles      []string
+		enableACL  bool
+		subjects   bool
+		missing    []string
+	}{
+		{name: "admin", management: "admin", enableACL: true},
+		{name: "user manager role", roles: []string{"user_manager"}, enableACL: true},
+		{name: "db viewer", management: "db_viewer", missing: []string{"create, update and delete users"}},
+		{name: "db viewer with acl_only", management: "db_viewer", enableACL: true, missing: []string{
+			"create, update and delete users",
+			"create and delete roles",
+			"update the roles_permissions of databases",
+		}},
+		{name: "cluster member role with acl_only", roles: []string{"cluster_member"}, enableACL: true, missing: []string{
+			"create, update and delete users",
+			"create and delete roles",
+		}},
+		{name: "combined roles", roles: []string{"cluster_member", "user_manager"}, enableACL: true},
+		{name: "user manager role with authorized_subjects", roles: []string{"user_manager"}, subjects: true, missing: []string{
+			"update the authorized_subjects of databases",
+		}},
+		{name: "db member and user manager roles with authorized_subjects", roles: []string{"db_member", "user_manager"}, subjects: true},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			cluster := newFakeCluster()
+			var roleUIDs []int
+			for _, management := range spec.roles {
+				roleUIDs = append(roleUIDs, cluster.AddRole("plugin "+management, management))
+			}
+			cluster.AddUser("plugin", "plugin@example.com", "Password", spec.management, roleUIDs...)
+			url := startCluster(t, cluster)
+
+			request := initializeRequest(url, "plugin@example.com", "Password", "mydb", spec.enableACL)
+			if spec.subjects {
+				request.Config["features"] = "authorized_subjects"
+			}
+
+			db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+			_, err := db.Initialize(context.Background(), request)
+
+			if len(spec.missing) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "user 'plugin@example.com' is missing privileges needed by the plugin on cluster 'test-cluster'")
+			for _, p := range privileges {
+				if contains(spec.missing, p.description) {
+					assert.Contains(t, err.Error(), p.description)
+				} else {
+					assert.NotContains(t, err.Error(), p.description)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Initialize_verifiesDatabaseAcceptsRolePermissions(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+
+	db, ok := cluster.DatabaseByName("mydb")
+	require.True(t, ok)
+	cluster.InjectError(http.MethodPut, fmt.Sprintf("/v1/bdbs/%d", db.UID), http.StatusBadRequest, 1)
+
+	subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	_, err := subject.Initialize(context.Background(), initializeRequest(url, grpcUsername, grpcPassword, "mydb", true))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "update the roles_permissions of database 'mydb'")
+}
+
+// startCluster starts serving the cluster for a single test
+func startCluster(t *testing.T, cluster *fake.Cluster) string {
+	t.Helper()
+
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	return url
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestManagementFor(t *testing.T) {
+	assert.Equal(t, []string{"user_manager"}, ManagementFor(""))
+	assert.Equal(t, []string{"user_manager"}, ManagementFor("acl_only,token_auth"))
+	assert.Equal(t, []string{"user_manager", "db_member"}, ManagementFor("acl_only,authorized_subjects"))
+}
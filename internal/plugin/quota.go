@@ -0,0 +1,317 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/inventory"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+// How long the list of live users is used for before it is read from the cluster again, and the window the rate of
+// new users is limited over.
+const (
+	liveUsersTTL   = 30 * time.Second
+	creationWindow = time.Minute
+)
+
+// ErrQuotaExceeded is returned, wrapped, by NewUser when creating the user would exceed one of the quotas.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// quotas limits the live users generated by the plugin, and how quickly they are created, so an application which
+// requests credentials in a loop cannot flood the cluster with users and roles before their leases expire.
+type quotas struct {
+	lock sync.Mutex
+
+	// users are the live generated users by name. They are listed from the cluster at most every liveUsersTTL, and
+	// kept up to date with the users created and deleted by the plugin in between.
+	users  map[string]liveUser
+	listed time.Time
+
+	// creations are when each of the users created in the last creationWindow was allowed
+	creations []time.Time
+
+	now func() time.Time
+}
+
+// liveUser is a user generated by the plugin which exists in the cluster, or is being created.
+type liveUser struct {
+	// metadata is the Vault display name and role name the username was generated from, joined by an underscore
+	metadata string
+
+	// role is the Vault role name in the form it appears in the username, lowercase and at most 50 characters. It is
+	// known exactly for the users reserved by the plugin, and otherwise only if the metadata can be split
+	// unambiguously, see roleOfMetadata.
+	role string
+
+	// roles are the UIDs of the roles of the user, which are not known while it is being created
+	roles []int
+
+	// pending is set while the plugin is creating the user, for the configured database
+	pending bool
+
+	// reserved is when the plugin started creating the user, which is not set for the users listed from the cluster
+	reserved time.Time
+}
+
+func newQuotas() *quotas {
+	return &quotas{now: time.Now}
+}
+
+// reset forgets the live users and recent creations, such as when the plugin is configured for another cluster.
+func (q *quotas) reset() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.users = nil
+	q.listed = time.Time{}
+	q.creations = nil
+}
+
+// reserveUser checks creating the user for the Vault role would not exceed any of the quotas, and counts it as a live
+// user straight away so users created at the same time cannot exceed them either. release must be called if the user
+// is not created after all.
+func (r *redisEnterpriseDB) reserveUser(ctx context.Context, roleName string, username string) (release func(), err error) {
+	// The calls to the cluster are made before taking the lock, so they do not hold up the users created at the same
+	// time
+	var db *sdk.Database
+	if r.config.hasUserQuotas() {
+		if err := r.refreshLiveUsers(ctx); err != nil {
+			return nil, err
+		}
+
+		if r.config.MaxUsersPerDatabase > 0 {
+			found, err := r.client.FindDatabaseByName(ctx, r.config.Database)
+			if err != nil {
+				return nil, r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database))
+			}
+			db = &found
+		}
+	}
+
+	q := r.quotas
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := q.now()
+
+	if limit := r.config.MaxNewUsersPerMinute; limit > 0 {
+		recent := q.creations[:0]
+		for _, created := range q.creations {
+			if now.Sub(created) < creationWindow {
+				recent = append(recent, created)
+			}
+		}
+		q.creations = recent
+
+		if len(q.creations) >= limit {
+			return nil, fmt.Errorf("%w: %d users have been created for %s and other roles in the last minute, the limit of max_new_users_per_minute", ErrQuotaExceeded, len(q.creations), roleName)
+		}
+	}
+
+	if r.config.hasUserQuotas() {
+		if err := r.checkLiveUsers(roleName, db); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only count the user against the quotas once it is within all of them
+	if r.config.MaxNewUsersPerMinute > 0 {
+		q.creations = append(q.creations, now)
+	}
+	if q.users != nil {
+		_, metadata, _ := inventory.ParseUsername(username)
+		q.users[username] = liveUser{metadata: metadata, role: usernameRole(roleName), pending: true, reserved: now}
+	}
+
+	return func() {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		if q.users != nil {
+			delete(q.users, username)
+		}
+		for i, created := range q.creations {
+			if created.Equal(now) {
+				q.creations = append(q.creations[:i], q.creations[i+1:]...)
+				break
+			}
+		}
+	}, nil
+}
+
+// checkLiveUsers checks there is room for another live user within each of the quotas on them. db is the configured
+// database, which is only needed with max_users_per_database. The caller must hold the lock of the quotas.
+func (r *redisEnterpriseDB) checkLiveUsers(roleName string, db *sdk.Database) error {
+	q := r.quotas
+
+	if limit := r.config.MaxUsers; limit > 0 && len(q.users) >= limit {
+		return fmt.Errorf("%w: cannot create a user for %s, as there are already %d users generated by the plugin on cluster '%s', the limit of max_users", ErrQuotaExceeded, roleName, len(q.users), r.describeCluster())
+	}
+
+	if limit := r.config.MaxUsersPerRole; limit > 0 {
+		if count := q.countForRole(roleName); count >= limit {
+			return fmt.Errorf("%w: cannot create a user for %s, as there are already %d users for the role on cluster '%s', the limit of max_users_per_role", ErrQuotaExceeded, roleName, count, r.describeCluster())
+		}
+	}
+
+	if limit := r.config.MaxUsersPerDatabase; limit > 0 && db != nil {
+		if count := q.countForDatabase(*db); count >= limit {
+			return fmt.Errorf("%w: cannot create a user for %s, as there are already %d users generated by the plugin for database '%s' on cluster '%s', the limit of max_users_per_database", ErrQuotaExceeded, roleName, count, db.Name, r.describeCluster())
+		}
+	}
+
+	return nil
+}
+
+// refreshLiveUsers reads the users generated by the plugin from the cluster, unless they were read in the last
+// liveUsersTTL.
+func (r *redisEnterpriseDB) refreshLiveUsers(ctx context.Context) error {
+	q := r.quotas
+	q.lock.Lock()
+	fresh := q.users != nil && q.now().Sub(q.listed) < liveUsersTTL
+	q.lock.Unlock()
+	if fresh {
+		return nil
+	}
+
+	started := q.now()
+	users, err := r.client.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot count the users generated by the plugin: %w", r.describeError(err, ""))
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// Another request may have read the users more recently while these were being read
+	if q.users != nil && !q.listed.Before(started) {
+		return nil
+	}
+
+	live := map[string]liveUser{}
+	for _, user := range users {
+		if metadata, ok := liveUserMetadata(user.Name); ok {
+			role := roleOfMetadata(metadata)
+			// The role of a user reserved by the plugin is known exactly, even when the metadata is ambiguous
+			if known, ok := q.users[user.Name]; ok && known.role != "" {
+				role = known.role
+			}
+			live[user.Name] = liveUser{metadata: metadata, role: role, roles: user.Roles}
+		}
+	}
+
+	// Keep the users which are still being created, or were reserved after the users were read, as they may not be
+	// listed yet
+	for name, user := range q.users {
+		if _, listed := live[name]; !listed && (user.pending || !user.reserved.Before(started)) {
+			live[name] = user
+		}
+	}
+
+	q.users = live
+	q.listed = started
+	return nil
+}
+
+// liveUserMetadata returns the metadata of a user generated by the plugin, which still counts as a live user while it
+// is revoked and waiting to be deleted.
+func liveUserMetadata(name string) (string, bool) {
+	if username, _, ok := inventory.ParseRevokedUsername(name); ok {
+		name = username
+	}
+	_, metadata, ok := inventory.ParseUsername(name)
+	return metadata, ok
+}
+
+// created records the roles of a user once it has been created.
+func (q *quotas) created(username string, roles []int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if user, ok := q.users[username]; ok {
+		user.roles = roles
+		user.pending = false
+		q.users[username] = user
+	}
+}
+
+// renamed keeps counting a user under its new name once it has been revoked, without the roles it no longer has.
+func (q *quotas) renamed(username string, name string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if user, ok := q.users[username]; ok {
+		delete(q.users, username)
+		user.roles = nil
+		q.users[name] = user
+	}
+}
+
+// deleted stops counting a user once it has been deleted.
+func (q *quotas) deleted(username string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.users, username)
+}
+
+// countForRole returns how many live users were generated for the Vault role.
+func (q *quotas) countForRole(roleName string) int {
+	role := usernameRole(roleName)
+
+	count := 0
+	for _, user := range q.users {
+		if user.role == role {
+			count++
+		}
+	}
+	return count
+}
+
+// usernameRole returns the Vault role name in the form credsutil adds it to generated usernames.
+func usernameRole(roleName string) string {
+	role := strings.ToLower(roleName)
+	if len(role) > 50 {
+		role = role[:50]
+	}
+	return role
+}
+
+// roleOfMetadata returns the Vault role name from the metadata of a username which was not reserved by the plugin, such
+// as one created before a restart or by another Vault cluster. The display name and role name can both contain
+// underscores, so the role is only known when the metadata has at most one: the role alone, as the display name is
+// left out when it is empty, or the display name and role, taking the display name to be set as Vault does for every
+// token. Otherwise an empty role is returned, and the user only counts towards the quotas which do not depend on the
+// role.
+func roleOfMetadata(metadata string) string {
+	switch strings.Count(metadata, "_") {
+	case 0:
+		return metadata
+	case 1:
+		return metadata[strings.Index(metadata, "_")+1:]
+	default:
+		return ""
+	}
+}
+
+// countForDatabase returns how many live users have a role bound in the database, or are being created for it.
+func (q *quotas) countForDatabase(db sdk.Database) int {
+	count := 0
+	for _, user := range q.users {
+		if user.pending {
+			count++
+			continue
+		}
+		for _, uid := range user.roles {
+			if db.FindPermissionForRole(uid) != nil {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,258 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupQuotas initialises the plugin against a fake cluster with the quotas.
+func setupQuotas(t *testing.T, quotas map[string]interface{}) *redisEnterpriseDB {
+	t.Helper()
+
+	cluster := newFakeCluster()
+	cluster.AddUser("v_other_test_abcdefghij0123456789_1700000000", "", "password", "db_member")
+	url := startCluster(t, cluster)
+
+	subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	request := initializeRequest(url, grpcUsername, grpcPassword, "mydb", true)
+	for key, value := range quotas {
+		request.Config[key] = value
+	}
+	_, err := subject.Initialize(context.Background(), request)
+	require.NoError(t, err)
+
+	return subject
+}
+
+func quotaRequest(roleName string) dbplugin.NewUserRequest {
+	req := statementRequest(`{"role":"DB Member"}`)
+	req.UsernameConfig.RoleName = roleName
+	return req
+}
+
+func TestRedisEnterpriseDB_NewUser_maxUsersPerRole(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users_per_role": 2})
+	ctx := context.Background()
+
+	// The user already in the cluster was generated for the role
+	first, err := subject.NewUser(ctx, quotaRequest("test"))
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.ErrorContains(t, err, "max_users_per_role")
+
+	_, err = subject.NewUser(ctx, quotaRequest("other"))
+	require.NoError(t, err)
+
+	_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: first.Username})
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	require.NoError(t, err)
+}
+
+func TestRedisEnterpriseDB_NewUser_maxUsersPerRoleCountsOnlyTheRole(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users_per_role": 1})
+	ctx := context.Background()
+
+	// The users of app_test end with the name of the role test, but are not counted for it
+	_, err := subject.NewUser(ctx, quotaRequest("app_test"))
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("app_test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// The user already in the cluster was generated for test
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// Once listed again, the role of the users created by the plugin is still known exactly
+	subject.quotas.listed = time.Time{}
+	_, err = subject.NewUser(ctx, quotaRequest("app_test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestRedisEnterpriseDB_NewUser_revokedUsersCount(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users_per_role": 2, "revocation_grace_period": "1h"})
+	ctx := context.Background()
+
+	// The user already in the cluster was generated for the role
+	created, err := subject.NewUser(ctx, quotaRequest("test"))
+	require.NoError(t, err)
+	_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// Still counted once the users are listed again under the name it was revoked with
+	subject.quotas.listed = time.Time{}
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
+
+func TestRoleOfMetadata(t *testing.T) {
+	assert.Equal(t, "test", roleOfMetadata("test"))
+	assert.Equal(t, "test", roleOfMetadata("token_test"))
+	assert.Equal(t, "", roleOfMetadata("token_app_test"))
+}
+
+func TestRedisEnterpriseDB_NewUser_maxUsers(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users": "3"})
+	ctx := context.Background()
+
+	_, err := subject.NewUser(ctx, quotaRequest("first"))
+	require.NoError(t, err)
+	_, err = subject.NewUser(ctx, quotaRequest("second"))
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("third"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.ErrorContains(t, err, "max_users")
+}
+
+func TestRedisEnterpriseDB_NewUser_maxUsersPerDatabase(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users_per_database": 2})
+	ctx := context.Background()
+
+	// The user already in the cluster has no role bound in the database, so is not counted
+	_, err := subject.NewUser(ctx, quotaRequest("first"))
+	require.NoError(t, err)
+	_, err = subject.NewUser(ctx, statementRequest(`{"acl":"Full Access"}`))
+	require.NoError(t, err)
+
+	_, err = subject.NewUser(ctx, quotaRequest("third"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.ErrorContains(t, err, "database 'mydb'")
+}
+
+func TestRedisEnterpriseDB_NewUser_maxNewUsersPerMinute(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_new_users_per_minute": 2})
+	ctx := context.Background()
+
+	now := time.Now()
+	subject.quotas.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := subject.NewUser(ctx, quotaRequest("test"))
+		require.NoError(t, err)
+	}
+
+	_, err := subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.ErrorContains(t, err, "max_new_users_per_minute")
+
+	now = now.Add(time.Minute)
+	_, err = subject.NewUser(ctx, quotaRequest("test"))
+	require.NoError(t, err)
+}
+
+func TestRedisEnterpriseDB_NewUser_concurrentUsersWithinQuota(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users": 3})
+	ctx := context.Background()
+
+	// The users are listed outside the lock, but each is still reserved before the next is checked
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := subject.NewUser(ctx, quotaRequest("test"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+	}
+	assert.Equal(t, 2, created)
+}
+
+func TestRedisEnterpriseDB_NewUser_noRateLimitKeepsNoCreations(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users": 10})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := subject.NewUser(ctx, quotaRequest("test"))
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, subject.quotas.creations)
+}
+
+func TestRedisEnterpriseDB_NewUser_failureReleasesQuota(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_users": 3})
+	ctx := context.Background()
+
+	// Each failure would leave no room for the next if it were still counted
+	for i := 0; i < 3; i++ {
+		created, err := subject.NewUser(ctx, quotaRequest("test"))
+		require.NoError(t, err)
+		_, err = subject.NewUser(ctx, statementRequest(`{"role":"Missing"}`))
+		require.ErrorContains(t, err, "role 'Missing'")
+		_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+		require.NoError(t, err)
+	}
+}
+
+func TestRedisEnterpriseDB_NewUser_failureReleasesCreation(t *testing.T) {
+	subject := setupQuotas(t, map[string]interface{}{"max_new_users_per_minute": 1, "max_users_per_role": 1})
+	ctx := context.Background()
+
+	now := time.Now()
+	subject.quotas.now = func() time.Time { return now }
+
+	// Neither a user over another quota nor a user which cannot be created uses up the rate
+	_, err := subject.NewUser(ctx, quotaRequest("test"))
+	assert.ErrorContains(t, err, "max_users_per_role")
+	missing := statementRequest(`{"role":"Missing"}`)
+	missing.UsernameConfig.RoleName = "other"
+	_, err = subject.NewUser(ctx, missing)
+	require.ErrorContains(t, err, "role 'Missing'")
+	assert.Empty(t, subject.quotas.creations)
+
+	_, err = subject.NewUser(ctx, quotaRequest("first"))
+	require.NoError(t, err)
+}
+
+func TestRedisEnterpriseDB_Initialize_invalidQuotas(t *testing.T) {
+	for _, spec := range []struct {
+		name     string
+		database string
+		config   map[string]interface{}
+		message  string
+	}{
+		{name: "negative", database: "mydb", config: map[string]interface{}{"max_users": -1}, message: "cannot be negative"},
+		{name: "no database", config: map[string]interface{}{"max_users_per_database": 1}, message: "max_users_per_database cannot be set if there is no database specified"},
+		{name: "redis backend", config: map[string]interface{}{"backend": backendRedis, "max_users": 1}, message: "cannot be set with the redis backend"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+			request := initializeRequest("https://localhost:9443", "user", "pass", spec.database, false)
+			request.VerifyConnection = false
+			for key, value := range spec.config {
+				request.Config[key] = value
+			}
+
+			_, err := subject.Initialize(context.Background(), request)
+			assert.ErrorContains(t, err, spec.message)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+// verifyACLConnection ensures the database is reachable and that the configured user is able to manage users with
+// the ACL commands.
+func (r *redisEnterpriseDB) verifyACLConnection(ctx context.Context) error {
+	name, err := r.acl.WhoAmI(ctx)
+	if err != nil {
+		return fmt.Errorf("could not verify connection to database '%s': %w", r.config.Url, err)
+	}
+
+	// ACL GETUSER needs the same privileges as ACL SETUSER and ACL DELUSER
+	if _, err := r.acl.UserExists(ctx, name); err != nil {
+		return fmt.Errorf("user '%s' is missing privileges needed by the plugin on database '%s': %w", name, r.config.Url, err)
+	}
+
+	return nil
+}
+
+// createACLUser creates the user in the database with the rules of the statement, starting from no permissions.
+func (r *redisEnterpriseDB) createACLUser(ctx context.Context, meta dbplugin.UsernameMetadata, s statement, username string, password string) error {
+	if err := checkRules(s.Rules, meta.RoleName); err != nil {
+		return err
+	}
+
+	rules := strings.Fields(s.Rules)
+	args := append([]string{"reset"}, rules...)
+	args = append(args, "on", ">"+password)
+
+	r.logger.Debug("set user", "user", username, "rules", rules)
+
+	if err := r.acl.SetUser(ctx, username, args...); err != nil {
+		return fmt.Errorf("cannot create user %s: %w", username, err)
+	}
+
+	r.audit(auditEvent{
+		Type:        auditUserCreated,
+		DisplayName: meta.DisplayName,
+		VaultRole:   meta.RoleName,
+		User:        username,
+	})
+
+	return nil
+}
+
+func (r *redisEnterpriseDB) updateACLPassword(ctx context.Context, username string, password string) error {
+	// ACL SETUSER creates a user which does not exist, so the user must be found first
+	exists, err := r.acl.UserExists(ctx, username)
+	if err != nil {
+		return fmt.Errorf("cannot find user %s: %w", username, err)
+	}
+	if !exists {
+		return fmt.Errorf("cannot find user %s", username)
+	}
+
+	r.logger.Debug("change password", "user", username)
+
+	if err := r.acl.SetUser(ctx, username, "resetpass", ">"+password); err != nil {
+		return fmt.Errorf("cannot change user password: %w", err)
+	}
+
+	r.audit(auditEvent{
+		Type: auditPasswordChanged,
+		User: username,
+	})
+
+	return nil
+}
+
+func (r *redisEnterpriseDB) deleteACLUser(ctx context.Context, username string) error {
+	r.logger.Debug("delete user", "user", username)
+
+	// If the user does not exist, it may have been deleted manually, which is fine
+	deleted, err := r.acl.DelUser(ctx, username)
+	if err != nil {
+		return fmt.Errorf("cannot delete user %s: %w", username, err)
+	}
+
+	if deleted {
+		r.audit(auditEvent{
+			Type: auditUserDeleted,
+			User: username,
+		})
+	}
+
+	return nil
+}
+
+// checkRules checks the rules of a statement leave the password of the user to the plugin.
+func checkRules(rules string, roleName string) error {
+	for _, rule := range strings.Fields(rules) {
+		if isPasswordRule(rule) {
+			return fmt.Errorf("the 'rules' in the creation statement for %s cannot change the passwords of the user", roleName)
+		}
+	}
+	return nil
+}
+
+// isPasswordRule returns true if the ACL rule adds, removes or resets the passwords of a user, which is left to Vault.
+func isPasswordRule(rule string) bool {
+	if rule == "nopass" || rule == "resetpass" {
+		return true
+	}
+	return strings.HasPrefix(rule, ">") || strings.HasPrefix(rule, "<") || strings.HasPrefix(rule, "#") || strings.HasPrefix(rule, "!")
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/redisacl"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/redisacl/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_Redis_Lifecycle(t *testing.T) {
+	url, username, password := startRedis(t)
+	db := setupRedisBackend(t, url, username, password)
+	ctx := context.Background()
+
+	req := newUserRequest("", "")
+	req.Statements.Commands = []string{`{"rules":"~cache:* +@read"}`}
+	created := dbtesting.AssertNewUser(t, db, req)
+	assertRedisLogin(t, url, created.Username, req.Password, true)
+
+	dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+		Username: created.Username,
+		Password: &dbplugin.ChangePassword{NewPassword: "changed"},
+	})
+	assertRedisLogin(t, url, created.Username, req.Password, false)
+	assertRedisLogin(t, url, created.Username, "changed", true)
+
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+	assertRedisLogin(t, url, created.Username, "changed", false)
+
+	// Deleting a user which no longer exists succeeds, so revocation can be retried
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+
+	// The password of a user which does not exist cannot be changed, as that would create the user
+	_, err := db.UpdateUser(ctx, dbplugin.UpdateUserRequest{
+		Username: created.Username,
+		Password: &dbplugin.ChangePassword{NewPassword: "changed"},
+	})
+	assert.Error(t, err)
+	assertRedisLogin(t, url, created.Username, "changed", false)
+}
+
+func TestRedisEnterpriseDB_Redis_NewUser_invalidStatement(t *testing.T) {
+	url, username, password := startRedis(t)
+	db := setupRedisBackend(t, url, username, password)
+
+	for _, spec := range []struct {
+		name      string
+		statement string
+		message   string
+	}{
+		{name: "no rules", statement: `{}`, message: "no 'rules'"},
+		{name: "role", statement: `{"role":"DB Member"}`, message: "cannot be used with the redis backend"},
+		{name: "password", statement: `{"rules":"+@all >hardcoded"}`, message: "cannot change the passwords"},
+		{name: "nopass", statement: `{"rules":"+@all nopass"}`, message: "cannot change the passwords"},
+		{name: "invalid rule", statement: `{"rules":"garbage"}`, message: "garbage"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			req := newUserRequest("", "")
+			req.Statements.Commands = []string{spec.statement}
+
+			_, err := db.NewUser(context.Background(), req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Redis_Initialize_verifiesPrivileges(t *testing.T) {
+	url, username, password := startRedis(t)
+
+	admin := redisacl.NewClient(hclog.NewNullLogger())
+	require.NoError(t, admin.Initialise(url, username, password))
+	t.Cleanup(func() { _ = admin.Close() })
+	require.NoError(t, admin.SetUser(context.Background(), "vault-limited", "reset", "on", ">limited", "+@read", "~*"))
+	t.Cleanup(func() { _, _ = admin.DelUser(context.Background(), "vault-limited") })
+
+	subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+	_, err := subject.Initialize(context.Background(), redisInitializeRequest(url, "vault-limited", "limited"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "user 'vault-limited' is missing privileges")
+
+	_, err = subject.Initialize(context.Background(), redisInitializeRequest(url, username, "garbage"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not verify connection")
+}
+
+func TestRedisEnterpriseDB_Redis_Initialize_invalidConfig(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		config  map[string]interface{}
+		message string
+	}{
+		{name: "features", config: map[string]interface{}{"features": "token_auth"}, message: "features cannot be enabled"},
+		{name: "database", config: map[string]interface{}{"database": "mydb"}, message: "database cannot be set"},
+		{name: "url", config: map[string]interface{}{"url": "https://localhost:9443"}, message: "redis://"},
+		{name: "secondary", config: map[string]interface{}{"secondary_url": "redis://localhost", "secondary_username": "a", "secondary_password": "b"}, message: "secondary cluster cannot be configured"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			req := redisInitializeRequest("redis://localhost:6379", "admin", "password")
+			req.VerifyConnection = false
+			for k, v := range spec.config {
+				req.Config[k] = v
+			}
+
+			subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+			_, err := subject.Initialize(context.Background(), req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+// startRedis returns the database the tests are run against, which is an in-memory server unless REDIS_URL is set.
+func startRedis(t *testing.T) (string, string, string) {
+	t.Helper()
+
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		return url, os.Getenv("REDIS_USERNAME"), os.Getenv("REDIS_PASSWORD")
+	}
+
+	server := fake.NewServer()
+	server.AddUser("admin", "xyzzyxyzzy", "+@all", "~*")
+	url := server.Start()
+	t.Cleanup(server.Close)
+
+	return url, "admin", "xyzzyxyzzy"
+}
+
+func redisInitializeRequest(url string, username string, password string) dbplugin.InitializeRequest {
+	req := initializeRequest(url, username, password, "", false)
+	req.Config["backend"] = "redis"
+	return req
+}
+
+func setupRedisBackend(t *testing.T, url string, username string, password string) dbplugin.Database {
+	t.Helper()
+
+	mock := &mockSdk{}
+	mock.On("Close").Return(nil)
+
+	db := newRedis(hclog.NewNullLogger(), mock)
+	dbtesting.AssertInitialize(t, db, redisInitializeRequest(url, username, password))
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// assertRedisLogin checks whether the user can authenticate to the database with the password.
+func assertRedisLogin(t *testing.T, url string, username string, password string, expected bool) {
+	t.Helper()
+
+	client := redisacl.NewClient(hclog.NewNullLogger())
+	require.NoError(t, client.Initialise(url, username, password))
+	defer func() { _ = client.Close() }()
+
+	err := client.Ping(context.Background())
+	if expected {
+		assert.NoError(t, err, "user %s cannot log in", username)
+	} else {
+		assert.Error(t, err, "user %s can still log in", username)
+	}
+}
@@ -0,0 +1,191 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/inventory"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/vault/sdk/database/helper/credsutil"
+)
+
+// How often the revoked users are checked for any whose grace period has ended, unless the grace period is shorter.
+const sweepInterval = time.Minute
+
+// The reasons recorded in the audit events of a revoked user, when its generated role is deleted at once and when the
+// user is deleted by the sweeper.
+const (
+	reasonRevoked          = "revoked"
+	reasonGracePeriodEnded = "revocation_grace_period ended"
+)
+
+// revocations runs the sweeper, which deletes the revoked users once their grace period has ended. When a user is due to
+// be deleted is recorded in its name rather than here, so the users revoked before a restart are still deleted on time.
+type revocations struct {
+	lock sync.Mutex
+
+	now func() time.Time
+
+	// stop and done are only set while the sweeper is running
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+func newRevocations() *revocations {
+	return &revocations{now: time.Now}
+}
+
+// revoke revokes the user in the cluster, and the secondary cluster if there is one, so it is deleted once the grace
+// period has ended.
+func (r *redisEnterpriseDB) revoke(ctx context.Context, username string) error {
+	due := r.revocations.now().Add(r.config.RevocationGracePeriod)
+
+	if err := r.revokeUser(ctx, username, due); err != nil {
+		return err
+	}
+
+	return r.mirror("delete_user", fmt.Sprintf("revoke user %s", username), func(secondary *redisEnterpriseDB) error {
+		return secondary.revokeUser(ctx, username, due)
+	})
+}
+
+// revokeUser ends the access of the user straight away, by removing its roles and replacing its password with a random
+// one which nobody knows, but keeps the user itself for forensics. The user is renamed to record when it is due to be
+// deleted, and any role generated for it is deleted. A user which does not exist, or has already been revoked, is
+// ignored.
+func (r *redisEnterpriseDB) revokeUser(ctx context.Context, username string, due time.Time) error {
+	if err := r.findAndRevokeUser(ctx, username, due); err != nil {
+		return err
+	}
+
+	if r.config.supportAclOnly() {
+		// Deleting the role also removes its binding from every instance of an Active-Active database
+		if err := r.findAndDeleteRole(ctx, username, reasonRevoked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *redisEnterpriseDB) findAndRevokeUser(ctx context.Context, username string, due time.Time) error {
+	user, err := r.client.FindUserByName(ctx, username)
+	if err != nil {
+		if errors.Is(err, &sdk.UserNotFoundError{}) {
+			return nil
+		}
+		return err
+	}
+
+	if r.config.supportAuthorizedSubjects() {
+		if err := r.removeAuthorizedSubject(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	// A user authenticating with a client certificate has no password to replace
+	var password string
+	if user.AuthMethod != authMethodCertificate {
+		password, err = credsutil.RandomAlphaNumeric(32, true)
+		if err != nil {
+			return fmt.Errorf("cannot generate password: %w", err)
+		}
+	}
+
+	revoked := inventory.RevokedUsername(username, due)
+	r.logger.Debug("revoke user", "user", username, "uid", user.UID, "renamed", revoked)
+
+	if err := r.client.RevokeUser(ctx, user.UID, revoked, password); err != nil {
+		return fmt.Errorf("cannot revoke user %s: %w", username, r.describeError(err, ""))
+	}
+	r.quotas.renamed(username, revoked)
+
+	r.audit(auditEvent{
+		Type:      auditUserRevoked,
+		User:      username,
+		UserUID:   user.UID,
+		RenamedTo: revoked,
+		Reason:    fmt.Sprintf("deleted after the revocation_grace_period of %s", r.config.RevocationGracePeriod),
+	})
+
+	return nil
+}
+
+// startSweeper starts deleting the revoked users in the background once their grace period has ended.
+func (r *redisEnterpriseDB) startSweeper() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	r.revocations.lock.Lock()
+	r.revocations.stop = cancel
+	r.revocations.done = done
+	r.revocations.lock.Unlock()
+
+	interval := min(sweepInterval, r.config.RevocationGracePeriod)
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.sweep(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// stopSweeper stops the sweeper, if it is running, and waits for it to finish.
+func (r *redisEnterpriseDB) stopSweeper() {
+	r.revocations.lock.Lock()
+	stop, done := r.revocations.stop, r.revocations.done
+	r.revocations.stop, r.revocations.done = nil, nil
+	r.revocations.lock.Unlock()
+
+	if stop == nil {
+		return
+	}
+	stop()
+	<-done
+}
+
+// sweep deletes the revoked users whose grace period has ended from the cluster, and from the secondary cluster if there
+// is one. Each cluster is swept on its own, so a user which could not be deleted from one is still deleted from the
+// other, and a user which cannot be deleted is tried again by the next sweep.
+func (r *redisEnterpriseDB) sweep(ctx context.Context) {
+	r.sweepCluster(ctx)
+	if r.secondary != nil {
+		r.secondary.sweepCluster(ctx)
+	}
+}
+
+func (r *redisEnterpriseDB) sweepCluster(ctx context.Context) {
+	users, err := r.client.ListUsers(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Warn("unable to find revoked users, will try again", "err", r.describeError(err, ""))
+		}
+		return
+	}
+
+	now := r.revocations.now()
+	for _, user := range users {
+		if _, due, ok := inventory.ParseRevokedUsername(user.Name); !ok || due.After(now) {
+			continue
+		}
+
+		if err := r.findAndDeleteUser(ctx, user.Name, reasonGracePeriodEnded); err != nil {
+			if ctx.Err() == nil {
+				r.logger.Warn("unable to delete revoked user, will try again", "user", user.Name, "err", err)
+			}
+		}
+	}
+}
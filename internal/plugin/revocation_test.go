@@ -0,0 +1,187 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/inventory"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRevocation initialises the plugin against the cluster with a grace period of an hour. The sweeper is stopped,
+// so the tests can sweep at the times they choose.
+func setupRevocation(t *testing.T, cluster *fake.Cluster) (*redisEnterpriseDB, *recordingAuditSink, *time.Time) {
+	t.Helper()
+	url := startCluster(t, cluster)
+	return initialiseRevocation(t, initializeRequest(url, grpcUsername, grpcPassword, "mydb", true))
+}
+
+func initialiseRevocation(t *testing.T, request dbplugin.InitializeRequest) (*redisEnterpriseDB, *recordingAuditSink, *time.Time) {
+	t.Helper()
+
+	subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	request.Config["revocation_grace_period"] = "1h"
+	_, err := subject.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = subject.Close() })
+
+	subject.stopSweeper()
+	now := time.Now()
+	subject.revocations.now = func() time.Time { return now }
+
+	sink := &recordingAuditSink{}
+	subject.auditSink = sink
+
+	return subject, sink, &now
+}
+
+func hasFakeUser(cluster *fake.Cluster, name string) bool {
+	for _, user := range cluster.Users() {
+		if user.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedisEnterpriseDB_DeleteUser_revokesUntilGracePeriodEnds(t *testing.T) {
+	cluster := newFakeCluster()
+	subject, sink, now := setupRevocation(t, cluster)
+	ctx := context.Background()
+
+	for _, statement := range []string{`{"role":"DB Member"}`, `{"acl":"Not Dangerous"}`} {
+		t.Run(statement, func(t *testing.T) {
+			created, err := subject.NewUser(ctx, statementRequest(statement))
+			require.NoError(t, err)
+			sink.events = nil
+
+			_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+			require.NoError(t, err)
+
+			// The user is kept, without any access, under a name recording when it is due to be deleted
+			revoked := inventory.RevokedUsername(created.Username, now.Add(time.Hour))
+			assert.False(t, hasFakeUser(cluster, created.Username))
+			user := findFakeUser(t, cluster, revoked)
+			assert.Empty(t, user.Roles)
+			assert.NotEqual(t, "password", user.Password)
+
+			require.NotEmpty(t, sink.events)
+			assert.Equal(t, auditUserRevoked, sink.events[0].Type)
+			assert.Equal(t, created.Username, sink.events[0].User)
+			assert.Equal(t, revoked, sink.events[0].RenamedTo)
+
+			// Revoking the user again does not delay its deletion
+			*now = now.Add(30 * time.Minute)
+			_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+			require.NoError(t, err)
+
+			subject.sweep(ctx)
+			assert.True(t, hasFakeUser(cluster, revoked), "deleted before the grace period ended")
+
+			*now = now.Add(30 * time.Minute)
+			subject.sweep(ctx)
+			assert.False(t, hasFakeUser(cluster, revoked), "not deleted after the grace period ended")
+
+			deleted := sink.events[len(sink.events)-1]
+			assert.Equal(t, auditUserDeleted, deleted.Type)
+			assert.Equal(t, revoked, deleted.User)
+			assert.Equal(t, reasonGracePeriodEnded, deleted.Reason)
+		})
+	}
+
+	// The role generated for the ACL is deleted when the user is revoked
+	for _, role := range cluster.Roles() {
+		assert.NotContains(t, role.Name, "mydb-v_", "generated role was not deleted")
+	}
+}
+
+func TestRedisEnterpriseDB_DeleteUser_revokeDeletesGeneratedRole(t *testing.T) {
+	cluster := newFakeCluster()
+	subject, sink, _ := setupRevocation(t, cluster)
+	ctx := context.Background()
+
+	created, err := subject.NewUser(ctx, statementRequest(`{"acl":"Not Dangerous"}`))
+	require.NoError(t, err)
+	sink.events = nil
+
+	_, err = subject.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+	require.NoError(t, err)
+
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, auditUserRevoked, sink.events[0].Type)
+	assert.Equal(t, auditRoleDeleted, sink.events[1].Type)
+	assert.Equal(t, "mydb-"+created.Username, sink.events[1].Role)
+	assert.Equal(t, reasonRevoked, sink.events[1].Reason)
+}
+
+func TestRedisEnterpriseDB_sweep_deletesUsersRevokedBeforeRestart(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+	ctx := context.Background()
+
+	// A user created from a statement naming an existing role has no generated role to find it by
+	before, _, now := initialiseRevocation(t, initializeRequest(url, grpcUsername, grpcPassword, "mydb", false))
+	created, err := before.NewUser(ctx, statementRequest(`{"role":"DB Member"}`))
+	require.NoError(t, err)
+	_, err = before.DeleteUser(ctx, dbplugin.DeleteUserRequest{Username: created.Username})
+	require.NoError(t, err)
+	revoked := inventory.RevokedUsername(created.Username, now.Add(time.Hour))
+	require.NoError(t, before.Close())
+
+	// The plugin is restarted, now without a database, and the user is still deleted once its grace period has ended
+	after, _, later := initialiseRevocation(t, initializeRequest(url, grpcUsername, grpcPassword, "", false))
+	*later = now.Add(59 * time.Minute)
+	after.sweep(ctx)
+	assert.True(t, hasFakeUser(cluster, revoked), "deleted before the grace period ended")
+
+	*later = now.Add(time.Hour)
+	after.sweep(ctx)
+	assert.False(t, hasFakeUser(cluster, revoked), "revoked user was not deleted")
+}
+
+func TestRedisEnterpriseDB_sweep_onlyDeletesRevokedUsers(t *testing.T) {
+	cluster := newFakeCluster()
+	role := cluster.AddRole("Other", "db_member")
+	cluster.AddUser("v_tester_test_abcdefghij0123456789_1700000000", "", "password", "")
+	cluster.AddUser("v_tester_test_0123456789abcdefghij_1700000000", "", "password", "", role)
+	cluster.AddUser("manual_revoked_1700000000", "", "password", "")
+	cluster.AddUser("v_tester_test_zzzzzzzzzz0123456789_1700000000_revoked_1700003600", "", "password", "")
+	subject, _, _ := setupRevocation(t, cluster)
+
+	subject.sweep(context.Background())
+
+	assert.False(t, hasFakeUser(cluster, "v_tester_test_zzzzzzzzzz0123456789_1700000000_revoked_1700003600"), "revoked user was not deleted")
+	assert.True(t, hasFakeUser(cluster, "v_tester_test_abcdefghij0123456789_1700000000"), "user without roles was deleted")
+	assert.True(t, hasFakeUser(cluster, "v_tester_test_0123456789abcdefghij_1700000000"), "user with a role was deleted")
+	assert.True(t, hasFakeUser(cluster, "manual_revoked_1700000000"), "user not generated by the plugin was deleted")
+}
+
+func TestRedisEnterpriseDB_Initialize_revocationGracePeriod(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		config  map[string]interface{}
+		message string
+	}{
+		{name: "negative", config: map[string]interface{}{"revocation_grace_period": "-1m"}, message: "revocation_grace_period cannot be negative"},
+		{name: "cloud backend", config: map[string]interface{}{"backend": backendCloud, "revocation_grace_period": "1h"}, message: "revocation_grace_period cannot be set with the cloud backend, as a user cannot be left without a role"},
+		{name: "redis backend", config: map[string]interface{}{"backend": backendRedis, "revocation_grace_period": "1h"}, message: "revocation_grace_period cannot be set with the redis backend"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			subject := newRedis(hclog.NewNullLogger(), &mockSdk{})
+			request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+			request.VerifyConnection = false
+			for key, value := range spec.config {
+				request.Config[key] = value
+			}
+
+			_, err := subject.Initialize(context.Background(), request)
+			assert.EqualError(t, err, spec.message)
+		})
+	}
+}
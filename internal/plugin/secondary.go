@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The policies for when a change cannot be mirrored to the secondary cluster
+const (
+	// secondaryPolicyFail fails the request, after undoing the change to the primary cluster where possible
+	secondaryPolicyFail = "fail"
+
+	// secondaryPolicyWarn logs the failure and succeeds, leaving the secondary cluster out of step with the primary
+	secondaryPolicyWarn = "warn"
+)
+
+// initialiseSecondary validates the configuration of the secondary cluster and sets up the plugin which manages its
+// users, or removes it if the secondary cluster is no longer configured.
+func (r *redisEnterpriseDB) initialiseSecondary() error {
+	if r.secondary != nil {
+		if err := r.secondary.client.Close(); err != nil {
+			r.logger.Warn("unable to close previous secondary client", "err", err)
+		}
+		r.secondary = nil
+	}
+
+	if r.config.SecondaryUrl == "" {
+		if r.config.SecondaryUsername != "" || r.config.SecondaryPassword != "" || r.config.SecondaryDatabase != "" {
+			return errors.New("secondary_url is required when a secondary cluster is configured")
+		}
+		return nil
+	}
+
+	if r.config.backend() == backendRedis {
+		return errors.New("a secondary cluster cannot be configured with the redis backend")
+	}
+	if r.config.SecondaryUsername == "" {
+		return errors.New("secondary_username is required when secondary_url is set")
+	}
+	if r.config.SecondaryPassword == "" {
+		return errors.New("secondary_password is required when secondary_url is set")
+	}
+	if !r.config.hasDatabase() && r.config.SecondaryDatabase != "" {
+		return errors.New("secondary_database cannot be set if there is no database specified")
+	}
+
+	switch r.config.SecondaryPolicy {
+	case "":
+		r.config.SecondaryPolicy = secondaryPolicyFail
+	case secondaryPolicyFail, secondaryPolicyWarn:
+	default:
+		return fmt.Errorf("secondary_policy must be '%s' or '%s'", secondaryPolicyFail, secondaryPolicyWarn)
+	}
+
+	database := r.config.SecondaryDatabase
+	if database == "" {
+		database = r.config.Database
+	}
+
+	logger := r.logger.Named("secondary")
+	secondary := newRedis(logger, r.newClient(logger, r.backend))
+	secondary.backend = r.backend
+	secondary.isSecondary = true
+	secondary.auditSink = r.auditSink
+	secondary.config = config{
+		Backend:               r.config.Backend,
+		Features:              r.config.Features,
+		Database:              database,
+		Username:              r.config.SecondaryUsername,
+		Password:              r.config.SecondaryPassword,
+		Url:                   r.config.SecondaryUrl,
+		MaxIdleConnections:    r.config.MaxIdleConnections,
+		IdleConnectionTimeout: r.config.IdleConnectionTimeout,
+		TokenTTL:              r.config.TokenTTL,
+		DryRun:                r.config.DryRun,
+		RevocationGracePeriod: r.config.RevocationGracePeriod,
+	}
+
+	secondary.client.Initialise(secondary.config.Url, secondary.config.Username, secondary.config.Password)
+	secondary.client.SetConnectionPool(secondary.config.MaxIdleConnections, secondary.config.IdleConnectionTimeout)
+	secondary.client.SetDryRun(secondary.config.DryRun)
+
+	r.secondary = secondary
+	return nil
+}
+
+// mirror makes the same change to the secondary cluster, if one is configured, as has been made to the primary
+// cluster. A failure is only returned if the policy is to fail, otherwise it is logged.
+func (r *redisEnterpriseDB) mirror(operation string, description string, change func(secondary *redisEnterpriseDB) error) error {
+	if r.secondary == nil {
+		return nil
+	}
+
+	err := change(r.secondary)
+	if err == nil {
+		return nil
+	}
+
+	recordSecondaryFailure(operation, r.config.SecondaryPolicy)
+	err = fmt.Errorf("unable to %s on secondary cluster '%s': %w", description, r.secondary.describeCluster(), err)
+
+	if r.config.SecondaryPolicy == secondaryPolicyWarn {
+		r.logger.Warn("secondary cluster is out of step with the primary", "err", err)
+		return nil
+	}
+
+	return err
+}
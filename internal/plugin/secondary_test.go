@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_Secondary_mirrorsUsers(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		enableACL bool
+		statement string
+	}{
+		{name: "role", statement: `{"role":"DB Member"}`},
+		{name: "acl only", enableACL: true, statement: `{"acl":"Not Dangerous"}`},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			primary, secondary := newFakeCluster(), newFakeCluster()
+			db := setupSecondary(t, primary, secondary, spec.enableACL, "")
+
+			req := newUserRequest("", "")
+			req.Statements.Commands = []string{spec.statement}
+			created := dbtesting.AssertNewUser(t, db, req)
+
+			primaryUser := findFakeUser(t, primary, created.Username)
+			secondaryUser := findFakeUser(t, secondary, created.Username)
+			assert.Equal(t, req.Password, secondaryUser.Password)
+			if spec.enableACL {
+				assertClusterMatchesLiveUsers(t, primary, map[string]bool{created.Username: true})
+				assertClusterMatchesLiveUsers(t, secondary, map[string]bool{created.Username: true})
+			} else {
+				assert.Equal(t, primaryUser.Roles, secondaryUser.Roles)
+			}
+
+			dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+				Username: created.Username,
+				Password: &dbplugin.ChangePassword{NewPassword: "changed"},
+			})
+			assert.Equal(t, "changed", findFakeUser(t, primary, created.Username).Password)
+			assert.Equal(t, "changed", findFakeUser(t, secondary, created.Username).Password)
+
+			dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+			assertClusterMatchesLiveUsers(t, primary, map[string]bool{})
+			assertClusterMatchesLiveUsers(t, secondary, map[string]bool{})
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Secondary_failPolicyRollsBackPrimary(t *testing.T) {
+	primary, secondary := newFakeCluster(), newFakeCluster()
+	db := setupSecondary(t, primary, secondary, true, secondaryPolicyFail)
+
+	secondary.InjectError(http.MethodPost, "/v1/users", http.StatusInternalServerError, 1)
+
+	req := newUserRequest("", "Not Dangerous")
+	_, err := db.NewUser(context.Background(), req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "on secondary cluster 'test-cluster'")
+
+	assertClusterMatchesLiveUsers(t, primary, map[string]bool{})
+	assertClusterMatchesLiveUsers(t, secondary, map[string]bool{})
+}
+
+func TestRedisEnterpriseDB_Secondary_warnPolicySucceeds(t *testing.T) {
+	primary, secondary := newFakeCluster(), newFakeCluster()
+	db := setupSecondary(t, primary, secondary, true, secondaryPolicyWarn)
+
+	secondary.InjectError(http.MethodPost, "/v1/users", http.StatusInternalServerError, 1)
+	created := dbtesting.AssertNewUser(t, db, newUserRequest("", "Not Dangerous"))
+
+	assertClusterMatchesLiveUsers(t, primary, map[string]bool{created.Username: true})
+	assertClusterMatchesLiveUsers(t, secondary, map[string]bool{})
+
+	// The user is still removed from the primary cluster, even though it was never created in the secondary cluster
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: created.Username})
+	assertClusterMatchesLiveUsers(t, primary, map[string]bool{})
+}
+
+func TestRedisEnterpriseDB_Secondary_rotateRootNotMirrored(t *testing.T) {
+	primary, secondary := newFakeCluster(), newFakeCluster()
+	db := setupSecondary(t, primary, secondary, false, "")
+
+	dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+		Username: grpcUsername,
+		Password: &dbplugin.ChangePassword{NewPassword: "rotated"},
+	})
+
+	assert.Equal(t, "rotated", findFakeUser(t, primary, "admin").Password)
+	assert.Equal(t, grpcPassword, findFakeUser(t, secondary, "admin").Password)
+}
+
+func TestRedisEnterpriseDB_Secondary_invalidConfig(t *testing.T) {
+	for _, spec := range []struct {
+		name    string
+		config  map[string]interface{}
+		message string
+	}{
+		{name: "no url", config: map[string]interface{}{"secondary_username": "a", "secondary_password": "b"}, message: "secondary_url is required"},
+		{name: "no username", config: map[string]interface{}{"secondary_url": "https://localhost", "secondary_password": "b"}, message: "secondary_username is required"},
+		{name: "no password", config: map[string]interface{}{"secondary_url": "https://localhost", "secondary_username": "a"}, message: "secondary_password is required"},
+		{name: "unknown policy", config: map[string]interface{}{"secondary_url": "https://localhost", "secondary_username": "a", "secondary_password": "b", "secondary_policy": "ignore"}, message: "secondary_policy must be"},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			req := initializeRequest("https://localhost", grpcUsername, grpcPassword, "mydb", false)
+			req.VerifyConnection = false
+			for k, v := range spec.config {
+				req.Config[k] = v
+			}
+
+			subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+			_, err := subject.Initialize(context.Background(), req)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+func TestRedisEnterpriseDB_Secondary_verifiesConnection(t *testing.T) {
+	primary, secondary := newFakeCluster(), newFakeCluster()
+	primaryURL := startCluster(t, primary)
+	secondaryURL := startCluster(t, secondary)
+
+	req := initializeRequest(primaryURL, grpcUsername, grpcPassword, "mydb", false)
+	req.Config["secondary_url"] = secondaryURL
+	req.Config["secondary_username"] = grpcUsername
+	req.Config["secondary_password"] = grpcPassword
+	req.Config["secondary_database"] = "garbage"
+
+	subject := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	_, err := subject.Initialize(context.Background(), req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secondary cluster")
+	assert.Contains(t, err.Error(), "garbage")
+}
+
+// setupSecondary initialises the plugin with the database mydb of the primary cluster, mirroring users to the same
+// database of the secondary cluster.
+func setupSecondary(t *testing.T, primary *fake.Cluster, secondary *fake.Cluster, enableACL bool, policy string) dbplugin.Database {
+	t.Helper()
+
+	req := initializeRequest(startCluster(t, primary), grpcUsername, grpcPassword, "mydb", enableACL)
+	req.Config["secondary_url"] = startCluster(t, secondary)
+	req.Config["secondary_username"] = grpcUsername
+	req.Config["secondary_password"] = grpcPassword
+	if policy != "" {
+		req.Config["secondary_policy"] = policy
+	}
+
+	db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+	dbtesting.AssertInitialize(t, db, req)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func findFakeUser(t *testing.T, cluster *fake.Cluster, name string) fake.User {
+	t.Helper()
+
+	for _, user := range cluster.Users() {
+		if user.Name == name {
+			return user
+		}
+	}
+
+	require.Failf(t, "user not found", "user %s does not exist", name)
+	return fake.User{}
+}
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+type statement struct {
+	Role string `json:"role"`
+	ACL  string `json:"acl"`
+
+	// Rules are the ACL rules of a user created with the redis backend, separated by spaces
+	Rules string `json:"rules"`
+
+	// CredentialType is the type of credential Vault must request users with: password, the default, or
+	// client_certificate
+	CredentialType string `json:"credential_type"`
+}
+
+func (s statement) hasRole() bool {
+	return s.Role != ""
+}
+
+func (s statement) hasACL() bool {
+	return s.ACL != ""
+}
+
+func (s statement) hasRules() bool {
+	return s.Rules != ""
+}
+
+func (s statement) credentialType() string {
+	if s.CredentialType == "" {
+		return dbplugin.CredentialTypePassword.String()
+	}
+	return s.CredentialType
+}
+
+// fields returns the fields of the statement to decode into, by their JSON key.
+func (s *statement) fields() map[string]*string {
+	return map[string]*string{
+		"role":            &s.Role,
+		"acl":             &s.ACL,
+		"rules":           &s.Rules,
+		"credential_type": &s.CredentialType,
+	}
+}
+
+// parseStatement decodes the single creation statement of the request strictly, so a misspelt key is an error rather
+// than ignored, and checks it can be used with the configuration. All the problems with the statement are reported
+// together.
+func (r *redisEnterpriseDB) parseStatement(req dbplugin.NewUserRequest) (statement, error) {
+	if len(req.Statements.Commands) != 1 {
+		return statement{}, errors.New("one creation statement is required")
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(req.Statements.Commands[0]), &raw); err != nil {
+		return statement{}, fmt.Errorf("cannot parse JSON for db role: %w", err)
+	}
+
+	var s statement
+	var problems []string
+	fields := s.fields()
+
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		field, ok := fields[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown field '%s' in creation statement for %s, the fields are %s", key, req.UsernameConfig.RoleName, strings.Join(slices.Sorted(maps.Keys(fields)), ", ")))
+			continue
+		}
+		if err := json.Unmarshal(raw[key], field); err != nil || bytes.Equal(raw[key], []byte("null")) {
+			problems = append(problems, fmt.Sprintf("'%s' in creation statement for %s must be a string", key, req.UsernameConfig.RoleName))
+		}
+	}
+
+	problems = append(problems, r.checkStatement(s, req.UsernameConfig.RoleName)...)
+	if err := problemsError(req.UsernameConfig.RoleName, problems); err != nil {
+		return statement{}, err
+	}
+
+	return s, nil
+}
+
+// problemsError reports all the problems with the creation statement for the Vault role together, or returns nil if
+// there are none.
+func problemsError(roleName string, problems []string) error {
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(problems[0])
+	default:
+		return fmt.Errorf("the creation statement for %s has %d problems:\n\t* %s", roleName, len(problems), strings.Join(problems, "\n\t* "))
+	}
+}
+
+// checkStatement returns the problems with using the statement with the configuration, which can be found without
+// the cluster.
+func (r *redisEnterpriseDB) checkStatement(s statement, roleName string) []string {
+	var problems []string
+
+	switch s.credentialType() {
+	case dbplugin.CredentialTypePassword.String(), dbplugin.CredentialTypeClientCertificate.String():
+	default:
+		problems = append(problems, fmt.Sprintf("unknown 'credential_type' '%s' in creation statement for %s", s.CredentialType, roleName))
+	}
+
+	if r.config.backend() == backendRedis {
+		if s.hasRole() || s.hasACL() {
+			problems = append(problems, fmt.Sprintf("'role' and 'acl' cannot be used with the redis backend, use 'rules' in the creation statement for %s", roleName))
+		}
+		if !s.hasRules() {
+			problems = append(problems, fmt.Sprintf("no 'rules' in creation statement for %s", roleName))
+		} else if err := checkRules(s.Rules, roleName); err != nil {
+			problems = append(problems, err.Error())
+		}
+		return problems
+	}
+
+	if s.hasRules() {
+		problems = append(problems, fmt.Sprintf("'rules' can only be used with the redis backend, in the creation statement for %s", roleName))
+	}
+	if !s.hasRole() && !s.hasACL() {
+		problems = append(problems, fmt.Sprintf("no 'role' or 'acl' in creation statement for %s", roleName))
+	}
+	if !s.hasRole() && s.hasACL() && !r.config.supportAclOnly() {
+		problems = append(problems, fmt.Sprintf("the ACL only feature has not been enabled for %s. You must specify a role name", roleName))
+	}
+	if !r.config.hasDatabase() && s.hasACL() {
+		problems = append(problems, fmt.Sprintf("ACL cannot be used when the database has not been specified for %s", roleName))
+	}
+
+	return problems
+}
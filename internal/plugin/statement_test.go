@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEnterpriseDB_parseStatement(t *testing.T) {
+	r := newRedis(hclog.NewNullLogger(), &mockSdk{})
+	r.config = config{Database: "mydb", Features: "acl_only"}
+
+	s, err := r.parseStatement(statementRequest(`{"role":"DB Member","acl":"Not Dangerous","credential_type":"password"}`))
+	require.NoError(t, err)
+	assert.Equal(t, statement{Role: "DB Member", ACL: "Not Dangerous", CredentialType: "password"}, s)
+}
+
+func TestRedisEnterpriseDB_parseStatement_invalid(t *testing.T) {
+	for _, spec := range []struct {
+		name      string
+		config    config
+		statement string
+		messages  []string
+	}{
+		{name: "not json", statement: `role: DB Member`, messages: []string{"cannot parse JSON"}},
+		{name: "not an object", statement: `["DB Member"]`, messages: []string{"cannot parse JSON"}},
+		{name: "misspelt", statement: `{"rol":"DB Member"}`, messages: []string{
+			"has 2 problems",
+			"unknown field 'rol' in creation statement for test, the fields are acl, credential_type, role, rules",
+			"no 'role' or 'acl'",
+		}},
+		{name: "not a string", statement: `{"role":["DB Member"],"acl":null}`, messages: []string{
+			"'role' in creation statement for test must be a string",
+			"'acl' in creation statement for test must be a string",
+		}},
+		{name: "every problem", statement: `{"acl":"Not Dangerous","rules":"+@all","credential_type":"kerberos","ttl":"1h"}`, messages: []string{
+			"has 5 problems",
+			"unknown field 'ttl'",
+			"unknown 'credential_type' 'kerberos'",
+			"'rules' can only be used with the redis backend",
+			"the ACL only feature has not been enabled",
+			"ACL cannot be used when the database has not been specified",
+		}},
+		{name: "redis backend", config: config{Backend: backendRedis}, statement: `{"role":"DB Member","rules":"+@all nopass"}`, messages: []string{
+			"'role' and 'acl' cannot be used with the redis backend",
+			"cannot change the passwords",
+		}},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			r := newRedis(hclog.NewNullLogger(), &mockSdk{})
+			r.config = spec.config
+
+			_, err := r.parseStatement(statementRequest(spec.statement))
+			require.Error(t, err)
+			for _, message := range spec.messages {
+				assert.Contains(t, err.Error(), message)
+			}
+		})
+	}
+}
+
+func statementRequest(statement string) dbplugin.NewUserRequest {
+	return dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{DisplayName: "tester", RoleName: "test"},
+		Statements:     dbplugin.Statements{Commands: []string{statement}},
+		Password:       "password",
+	}
+}
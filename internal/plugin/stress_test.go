@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The number of plugin instances, each standing in for a separate Vault node, and the number of users each creates
+const (
+	stressInstances        = 8
+	stressUsersPerInstance = 4
+)
+
+// TestStress_NewUser_aclOnly runs several instances of the plugin, as Vault HA and performance standbys would, creating
+// and deleting users in parallel against the same database. The cluster rejects updates to the database with a 409
+// while a previous update is still being applied, as Redis Enterprise does.
+//
+// Run it under the race detector with:
+//
+//	go test -race -run TestStress ./internal/plugin
+func TestStress_NewUser_aclOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+
+	cluster := newFakeCluster()
+	cluster.SetLatency(2 * time.Millisecond)
+	cluster.SetUpdateDuration(20 * time.Millisecond)
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	var instances []dbplugin.Database
+	for i := 0; i < stressInstances; i++ {
+		db := newRedis(hclog.NewNullLogger(), sdk.NewClient(hclog.NewNullLogger()))
+		dbtesting.AssertInitialize(t, db, initializeRequest(url, grpcUsername, grpcPassword, "mydb", true))
+		instances = append(instances, db)
+	}
+
+	var (
+		lock   sync.Mutex
+		live   = map[string]bool{}
+		result error
+		wg     sync.WaitGroup
+	)
+
+	for i, db := range instances {
+		wg.Add(1)
+		go func(i int, db dbplugin.Database) {
+			defer wg.Done()
+
+			for j := 0; j < stressUsersPerInstance; j++ {
+				req := newUserRequest("", "")
+				req.UsernameConfig.DisplayName = fmt.Sprintf("stress%d", i)
+				req.Statements.Commands = []string{`{"acl":"Not Dangerous"}`}
+
+				res, err := db.NewUser(context.Background(), req)
+				if err != nil {
+					lock.Lock()
+					result = multierror.Append(result, fmt.Errorf("instance %d: %w", i, err))
+					lock.Unlock()
+					continue
+				}
+
+				// Revoke every other user, so creating and deleting happen at the same time
+				if j%2 == 1 {
+					if _, err := db.DeleteUser(context.Background(), dbplugin.DeleteUserRequest{Username: res.Username}); err != nil {
+						lock.Lock()
+						result = multierror.Append(result, fmt.Errorf("instance %d: %w", i, err))
+						live[res.Username] = true
+						lock.Unlock()
+					}
+					continue
+				}
+
+				lock.Lock()
+				live[res.Username] = true
+				lock.Unlock()
+			}
+		}(i, db)
+	}
+	wg.Wait()
+
+	for _, db := range instances {
+		dbtesting.AssertClose(t, db)
+	}
+
+	require.NoError(t, result)
+	assertClusterMatchesLiveUsers(t, cluster, live)
+}
+
+// assertClusterMatchesLiveUsers checks the only users and generated roles in the cluster are those of the live users,
+// and that the database has a binding for each generated role and no other bindings than those it started with.
+func assertClusterMatchesLiveUsers(t *testing.T, cluster *fake.Cluster, live map[string]bool) {
+	t.Helper()
+
+	rolesByUID := map[int]fake.Role{}
+	for _, role := range cluster.Roles() {
+		rolesByUID[role.UID] = role
+	}
+
+	generated := map[int]bool{}
+	for _, user := range cluster.Users() {
+		if user.Name == "admin" {
+			continue
+		}
+		assert.True(t, live[user.Name], "user %s should have been deleted", user.Name)
+		delete(live, user.Name)
+
+		require.Len(t, user.Roles, 1, "user %s", user.Name)
+		role, ok := rolesByUID[user.Roles[0]]
+		require.True(t, ok, "role of user %s does not exist", user.Name)
+		assert.Equal(t, "mydb-"+user.Name, role.Name)
+		generated[role.UID] = true
+	}
+	assert.Empty(t, live, "users missing from the cluster")
+
+	for _, role := range cluster.Roles() {
+		if role.Name == "DB Member" {
+			continue
+		}
+		assert.True(t, generated[role.UID], "orphaned role %s", role.Name)
+	}
+
+	db, ok := cluster.DatabaseByName("mydb")
+	require.True(t, ok, "database mydb does not exist")
+
+	bound := map[int]bool{}
+	for _, permission := range db.RolePermissions {
+		role, ok := rolesByUID[permission.RoleUID]
+		require.True(t, ok, "binding for role %d which does not exist", permission.RoleUID)
+		assert.False(t, bound[role.UID], "role %s bound more than once", role.Name)
+		bound[role.UID] = true
+
+		if role.Name == "DB Member" {
+			continue
+		}
+		assert.True(t, generated[role.UID], "binding for role %s which has no user", role.Name)
+	}
+	for uid := range generated {
+		assert.True(t, bound[uid], "no binding for role %s", rolesByUID[uid].Name)
+	}
+}
@@ -4,16 +4,58 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/hashicorp/go-version"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestMain(m *testing.M) {
 	jsonLogging = false
+
+	// Without a real cluster, run the tests against an in-memory cluster set up the same way as the CI cluster
+	if url == "" {
+		cluster := newFakeCluster()
+		url = cluster.Start()
+		username = "admin@example.com"
+		password = "xyzzyxyzzy"
+		database = "mydb"
+
+		code := m.Run()
+		cluster.Close()
+		os.Exit(code)
+	}
+
 	os.Exit(m.Run())
 }
 
+// newFakeCluster creates a cluster with a database, "mydb", which has the role "DB Member" bound to the
+// ACL "Not Dangerous".
+func newFakeCluster() *fake.Cluster {
+	cluster := fake.NewCluster("test-cluster")
+	cluster.AddUser("admin", "admin@example.com", "xyzzyxyzzy", "admin")
+
+	role := cluster.AddRole("DB Member", "db_member")
+	acl := cluster.AddACL("Not Dangerous", "+@all -@dangerous ~*")
+	cluster.AddACL("Full Access", "+@all ~*")
+	cluster.AddDatabase("mydb", fake.RolePermission{RoleUID: role, ACLUID: acl})
+
+	return cluster
+}
+
+type testContextKey struct{}
+
+// testContext returns a context which can still be matched by matchesContext after the plugin has derived new
+// contexts from it, such as when starting a span.
+func testContext(t *testing.T) context.Context {
+	return context.WithValue(context.Background(), testContextKey{}, t.Name())
+}
+
+// mockAnyDuration matches any time.Duration argument
+var mockAnyDuration = mock.AnythingOfType("time.Duration")
+
 var _ sdkClient = &mockSdk{}
 
 type mockSdk struct {
@@ -24,6 +66,18 @@ func (m *mockSdk) Initialise(url string, username string, password string) {
 	m.Called(url, username, password)
 }
 
+func (m *mockSdk) SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) {
+	m.Called(maxIdleConns, idleConnTimeout)
+}
+
+func (m *mockSdk) SetTokenAuth(ttl time.Duration) {
+	m.Called(ttl)
+}
+
+func (m *mockSdk) SetDryRun(dryRun bool) {
+	m.Called(dryRun)
+}
+
 func (m *mockSdk) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -39,9 +93,67 @@ func (m *mockSdk) GetCluster(ctx context.Context) (sdk.Cluster, error) {
 	return args.Get(0).(sdk.Cluster), args.Error(1)
 }
 
-func (m *mockSdk) UpdateDatabaseWithRetry(ctx context.Context, id int, update sdk.UpdateDatabase) error {
-	args := m.Called(ctx, id, update)
-	return args.Error(0)
+func (m *mockSdk) GetDatabase(ctx context.Context, id int) (sdk.Database, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(sdk.Database), args.Error(1)
+}
+
+// UpdateDatabaseRolePermissions applies the update to the roles_permissions of the database returned by GetDatabase,
+// and is matched against the roles_permissions after the update.
+func (m *mockSdk) UpdateDatabaseRolePermissions(ctx context.Context, id int, update func([]sdk.RolePermission) []sdk.RolePermission) ([]sdk.RolePermission, []sdk.RolePermission, error) {
+	db, err := m.GetDatabase(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after := update(db.RolePermissions)
+	args := m.Called(ctx, id, sdk.UpdateDatabase{RolePermissions: after})
+	if err := args.Error(0); err != nil {
+		return nil, nil, err
+	}
+	return db.RolePermissions, after, nil
+}
+
+// GetVersion returns the version of Redis Enterprise the cluster is running.
+func (m *mockSdk) GetVersion(ctx context.Context) (*version.Version, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*version.Version), args.Error(1)
+}
+
+func (m *mockSdk) GetCRDB(ctx context.Context, guid string) (sdk.CRDB, error) {
+	args := m.Called(ctx, guid)
+	return args.Get(0).(sdk.CRDB), args.Error(1)
+}
+
+// UpdateCRDBRolePermissions applies the update to the roles_permissions of the Active-Active database returned by
+// GetCRDB, and is matched against the roles_permissions after the update.
+func (m *mockSdk) UpdateCRDBRolePermissions(ctx context.Context, guid string, update func([]sdk.CRDBRolePermission) []sdk.CRDBRolePermission) ([]sdk.CRDBRolePermission, []sdk.CRDBRolePermission, error) {
+	crdb, err := m.GetCRDB(ctx, guid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before := crdb.DefaultDBConfig.RolePermissions
+	after := update(before)
+	args := m.Called(ctx, guid, sdk.UpdateCRDB{DefaultDBConfig: sdk.CRDBConfig{RolePermissions: after}})
+	if err := args.Error(0); err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+func (m *mockSdk) UpdateDatabaseAuthorizedSubjects(ctx context.Context, id int, update func([]sdk.Subject) []sdk.Subject) ([]sdk.Subject, []sdk.Subject, error) {
+	db, err := m.GetDatabase(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after := update(append([]sdk.Subject(nil), db.AuthorizedSubjects...))
+	args := m.Called(ctx, id, after)
+	if err := args.Error(0); err != nil {
+		return nil, nil, err
+	}
+	return db.AuthorizedSubjects, after, nil
 }
 
 func (m *mockSdk) FindDatabaseByName(ctx context.Context, name string) (sdk.Database, error) {
@@ -49,6 +161,16 @@ func (m *mockSdk) FindDatabaseByName(ctx context.Context, name string) (sdk.Data
 	return args.Get(0).(sdk.Database), args.Error(1)
 }
 
+func (m *mockSdk) ValidateDatabaseUpdate(ctx context.Context, id int, update sdk.UpdateDatabase) error {
+	args := m.Called(ctx, id, update)
+	return args.Error(0)
+}
+
+func (m *mockSdk) GetRole(ctx context.Context, id int) (sdk.Role, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(sdk.Role), args.Error(1)
+}
+
 func (m *mockSdk) CreateRole(ctx context.Context, create sdk.CreateRole) (sdk.Role, error) {
 	args := m.Called(ctx, create)
 	return args.Get(0).(sdk.Role), args.Error(1)
@@ -69,11 +191,21 @@ func (m *mockSdk) CreateUser(ctx context.Context, create sdk.CreateUser) (sdk.Us
 	return args.Get(0).(sdk.User), args.Error(1)
 }
 
+func (m *mockSdk) ListUsers(ctx context.Context) ([]sdk.User, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]sdk.User), args.Error(1)
+}
+
 func (m *mockSdk) UpdateUserPassword(ctx context.Context, id int, update sdk.UpdateUser) error {
 	args := m.Called(ctx, id, update)
 	return args.Error(0)
 }
 
+func (m *mockSdk) RevokeUser(ctx context.Context, id int, name string, password string) error {
+	args := m.Called(ctx, id, name, password)
+	return args.Error(0)
+}
+
 func (m *mockSdk) DeleteUser(ctx context.Context, id int) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
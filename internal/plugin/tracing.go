@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/version"
+)
+
+// The name of the service the spans of the plugin are reported for.
+const tracingServiceName = "vault-plugin-database-redisenterprise"
+
+// tracerName identifies the spans of the plugin. The tracer is looked up for every span, rather than once, so the spans
+// go to whichever tracer provider is current when the plugin is initialised again.
+const tracerName = "github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/plugin"
+
+// startSpan starts a span for one of the dbplugin.Database methods.
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, recording the error if the method failed.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startTracing exports the spans of the plugin and the sdk over OTLP/HTTP to the endpoint, e.g.
+// http://otel-collector:4318, and propagates the trace context to the cluster API. Any previous exporter is shut
+// down first.
+func (r *redisEnterpriseDB) startTracing(ctx context.Context, endpoint string) error {
+	if err := r.stopTracing(ctx); err != nil {
+		return err
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return fmt.Errorf("unable to create trace exporter for %s: %w", endpoint, err)
+	}
+
+	r.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(tracingServiceName),
+			semconv.ServiceVersion(version.Version),
+		)),
+	)
+
+	otel.SetTracerProvider(r.tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r.logger.Info("exporting traces", "endpoint", endpoint)
+	return nil
+}
+
+// stopTracing flushes any remaining spans and stops exporting, if tracing was started.
+func (r *redisEnterpriseDB) stopTracing(ctx context.Context) error {
+	if r.tracerProvider == nil {
+		return nil
+	}
+
+	err := r.tracerProvider.Shutdown(ctx)
+	r.tracerProvider = nil
+	return err
+}
@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+)
+
+func TestRedisEnterpriseDB_UpdateUser_recordsSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	client := &mockSdk{}
+	db := newRedis(hclog.Default(), client)
+
+	ctx := testContext(t)
+	// The sdk must be called with the context of the span, so its requests are recorded as children
+	client.On("FindUserByName", mock.MatchedBy(func(spanCtx context.Context) bool {
+		return trace.SpanContextFromContext(spanCtx).IsValid() && spanCtx.Value(testContextKey{}) == t.Name()
+	}), "missing").Return(sdk.User{}, errors.New("not found"))
+
+	_, err := db.UpdateUser(ctx, dbplugin.UpdateUserRequest{
+		Username: "missing",
+		Password: &dbplugin.ChangePassword{NewPassword: "foo"},
+	})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "UpdateUser", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("username", "missing"))
+}
+
+func TestRedisEnterpriseDB_Initialize_stopsTracing(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	client := &mockSdk{}
+	db := newRedis(hclog.Default(), client)
+
+	request := initializeRequest("https://localhost:9443", "user", "pass", "", false)
+	request.VerifyConnection = false
+	request.Config["tracing_endpoint"] = "http://127.0.0.1:4318"
+
+	client.On("Initialise", "https://localhost:9443", "user", "pass")
+	client.On("SetConnectionPool", 0, mockAnyDuration)
+	client.On("SetDryRun", false)
+	client.On("Close").Return(nil)
+
+	_, err := db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	require.NotNil(t, db.tracerProvider)
+
+	// Vault initialises the plugin again when its configuration is updated
+	delete(request.Config, "tracing_endpoint")
+	_, err = db.Initialize(context.Background(), request)
+	require.NoError(t, err)
+	assert.Nil(t, db.tracerProvider)
+
+	require.NoError(t, db.Close())
+}
@@ -3,27 +3,67 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // UpdateUser changes a user's password
-func (r *redisEnterpriseDB) UpdateUser(ctx context.Context, req dbplugin.UpdateUserRequest) (dbplugin.UpdateUserResponse, error) {
+func (r *redisEnterpriseDB) UpdateUser(ctx context.Context, req dbplugin.UpdateUserRequest) (_ dbplugin.UpdateUserResponse, err error) {
+	defer func(start time.Time) { recordOperation("update_user", start, err) }(time.Now())
+	ctx, span := startSpan(ctx, "UpdateUser", attribute.String("username", req.Username))
+	defer func() { endSpan(span, err) }()
+
 	if req.Password == nil {
 		return dbplugin.UpdateUserResponse{}, nil
 	}
 
-	user, err := r.client.FindUserByName(ctx, req.Username)
+	// Vault would keep the new password even though the cluster never received it, which for the root credentials
+	// would lock the plugin out of the cluster
+	if r.config.DryRun {
+		return dbplugin.UpdateUserResponse{}, fmt.Errorf("the password of user %s cannot be changed with dry_run enabled", req.Username)
+	}
+
+	if err := r.updatePassword(ctx, req.Username, req.Password.NewPassword); err != nil {
+		return dbplugin.UpdateUserResponse{}, err
+	}
+
+	// The root credentials are only used by the plugin to connect to the primary cluster
+	if req.Username != r.config.Username {
+		if err := r.mirror("update_user", fmt.Sprintf("change the password of user %s", req.Username), func(secondary *redisEnterpriseDB) error {
+			return secondary.updatePassword(ctx, req.Username, req.Password.NewPassword)
+		}); err != nil {
+			return dbplugin.UpdateUserResponse{}, err
+		}
+	}
+
+	return dbplugin.UpdateUserResponse{}, nil
+}
+
+func (r *redisEnterpriseDB) updatePassword(ctx context.Context, username string, password string) error {
+	if r.config.backend() == backendRedis {
+		return r.updateACLPassword(ctx, username, password)
+	}
+
+	user, err := r.client.FindUserByName(ctx, username)
 
 	if err != nil {
-		return dbplugin.UpdateUserResponse{}, fmt.Errorf("cannot find user %s: %w", req.Username, err)
+		return fmt.Errorf("cannot find user %s: %w", username, err)
 	}
 
-	r.logger.Debug("change password", "user", req.Username, "uid", user.UID)
+	r.logger.Debug("change password", "user", username, "uid", user.UID)
 
-	if err := r.client.UpdateUserPassword(ctx, user.UID, sdk.UpdateUser{Password: req.Password.NewPassword}); err != nil {
-		return dbplugin.UpdateUserResponse{}, fmt.Errorf("cannot change user password: %w", err)
+	if err := r.client.UpdateUserPassword(ctx, user.UID, sdk.UpdateUser{Password: password}); err != nil {
+		return fmt.Errorf("cannot change user password: %w", err)
 	}
-	return dbplugin.UpdateUserResponse{}, nil
+
+	r.audit(auditEvent{
+		Type:    auditPasswordChanged,
+		User:    username,
+		UserUID: user.UID,
+	})
+
+	return nil
 }
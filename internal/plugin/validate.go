@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+// Validate initialises the plugin with the configuration, including verifying the connection, and makes every check
+// NewUser would make of the request without creating anything in the cluster. All the problems which are found are
+// reported together.
+func Validate(ctx context.Context, logger hclog.Logger, config map[string]interface{}, req dbplugin.NewUserRequest) error {
+	r := newRedis(logger, sdk.NewClient(logger))
+	db := wrapWithSanitizerMiddleware(r)
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.Initialize(ctx, dbplugin.InitializeRequest{Config: config, VerifyConnection: true}); err != nil {
+		return err
+	}
+
+	return r.validateNewUser(ctx, req)
+}
+
+// validateNewUser makes the checks of NewUser which do not change the cluster.
+func (r *redisEnterpriseDB) validateNewUser(ctx context.Context, req dbplugin.NewUserRequest) error {
+	s, err := r.parseStatement(req)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+
+	creds, err := r.newCredentials(req, s)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// The rules of the redis backend can only be checked by the database when the user is created
+	if r.config.backend() != backendRedis {
+		problems = append(problems, r.checkNewUser(ctx, s, creds)...)
+		if r.secondary != nil {
+			for _, problem := range r.secondary.checkNewUser(ctx, s, creds) {
+				problems = append(problems, fmt.Sprintf("on secondary cluster '%s': %s", r.secondary.describeCluster(), problem))
+			}
+		}
+	}
+
+	return problemsError(req.UsernameConfig.RoleName, problems)
+}
+
+// checkNewUser returns the problems with creating a user for the statement in the cluster.
+func (r *redisEnterpriseDB) checkNewUser(ctx context.Context, s statement, creds credentials) []string {
+	var problems []string
+
+	if err := r.checkCapabilityFor(creds); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if s.hasRole() {
+		if _, err := r.findBoundRole(ctx, s); err != nil {
+			problems = append(problems, err.Error())
+		}
+	} else if s.hasACL() {
+		if _, err := r.client.FindACLByName(ctx, s.ACL); err != nil {
+			problems = append(problems, r.describeError(err, fmt.Sprintf("ACL '%s'", s.ACL)).Error())
+		}
+		if _, err := r.client.FindDatabaseByName(ctx, r.config.Database); err != nil {
+			problems = append(problems, r.describeError(err, fmt.Sprintf("database '%s'", r.config.Database)).Error())
+		}
+	}
+
+	if creds.authMethod() == authMethodCertificate && r.config.supportAuthorizedSubjects() {
+		if subject, err := sdk.ParseSubject(creds.subject); err == nil {
+			if _, err := r.findSubjectDatabase(ctx, subject, creds.subject); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	return problems
+}
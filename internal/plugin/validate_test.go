@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	cluster := newFakeCluster()
+	url := startCluster(t, cluster)
+	users, roles := len(cluster.Users()), len(cluster.Roles())
+
+	for _, statement := range []string{
+		`{"role":"DB Member"}`,
+		`{"role":"DB Member","acl":"Not Dangerous"}`,
+		`{"acl":"Full Access"}`,
+	} {
+		t.Run(statement, func(t *testing.T) {
+			config := initializeRequest(url, grpcUsername, grpcPassword, "mydb", true).Config
+			require.NoError(t, Validate(context.Background(), hclog.NewNullLogger(), config, statementRequest(statement)))
+		})
+	}
+
+	// Nothing is created in the cluster
+	assert.Len(t, cluster.Users(), users)
+	assert.Len(t, cluster.Roles(), roles)
+}
+
+func TestValidate_invalid(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.AddRole("Unbound", "db_member")
+	url := startCluster(t, cluster)
+
+	for _, spec := range []struct {
+		name      string
+		database  string
+		features  string
+		statement string
+		messages  []string
+	}{
+		{name: "missing role", database: "mydb", statement: `{"role":"Missing"}`, messages: []string{"role 'Missing'"}},
+		{name: "unbound role", database: "mydb", statement: `{"role":"Unbound"}`, messages: []string{"has no binding for role 'Unbound'"}},
+		{name: "different acl", database: "mydb", statement: `{"role":"DB Member","acl":"Full Access"}`, messages: []string{"different binding for role 'DB Member' than ACL 'Full Access'"}},
+		{name: "missing acl", database: "mydb", features: "acl_only", statement: `{"acl":"Missing"}`, messages: []string{"ACL 'Missing'"}},
+		{name: "acl only disabled", database: "mydb", statement: `{"acl":"Full Access"}`, messages: []string{"the ACL only feature has not been enabled"}},
+		{name: "misspelt", database: "mydb", statement: `{"rol":"DB Member"}`, messages: []string{"unknown field 'rol'"}},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			config := initializeRequest(url, grpcUsername, grpcPassword, spec.database, false).Config
+			if spec.features != "" {
+				config["features"] = spec.features
+			}
+
+			err := Validate(context.Background(), hclog.NewNullLogger(), config, statementRequest(spec.statement))
+			require.Error(t, err)
+			for _, message := range spec.messages {
+				assert.Contains(t, err.Error(), message)
+			}
+		})
+	}
+}
+
+func TestValidate_clientCertificate(t *testing.T) {
+	cluster := newFakeCluster()
+	cluster.SetVersion("6.2.10-100")
+	url := startCluster(t, cluster)
+
+	req := newClientCertificateRequest(`{"role":"Missing","credential_type":"client_certificate"}`)
+	config := initializeRequest(url, grpcUsername, grpcPassword, "mydb", false).Config
+
+	// Every problem is reported, rather than only the first
+	err := Validate(context.Background(), hclog.NewNullLogger(), config, req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has 2 problems")
+	assert.Contains(t, err.Error(), "require Redis Enterprise 6.4.2 or later")
+	assert.Contains(t, err.Error(), "role 'Missing'")
+}
+
+func TestValidate_invalidConfig(t *testing.T) {
+	err := Validate(context.Background(), hclog.NewNullLogger(), map[string]interface{}{"url": "https://localhost:9443"}, statementRequest(`{"role":"DB Member"}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "username is required")
+}
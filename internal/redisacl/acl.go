@@ -0,0 +1,55 @@
+package redisacl
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ping checks the database can be reached and the credentials are accepted.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// WhoAmI returns the name of the user the client is authenticated as.
+func (c *Client) WhoAmI(ctx context.Context) (string, error) {
+	reply, err := c.Do(ctx, "ACL", "WHOAMI")
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply to ACL WHOAMI: %v", reply)
+	}
+	return name, nil
+}
+
+// SetUser creates the user, or changes it if it already exists, applying the ACL rules in order.
+func (c *Client) SetUser(ctx context.Context, name string, rules ...string) error {
+	_, err := c.Do(ctx, append([]string{"ACL", "SETUSER", name}, rules...)...)
+	return err
+}
+
+// UserExists returns whether there is a user with the name.
+func (c *Client) UserExists(ctx context.Context, name string) (bool, error) {
+	reply, err := c.Do(ctx, "ACL", "GETUSER", name)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// DelUser deletes the user, returning whether it existed.
+func (c *Client) DelUser(ctx context.Context, name string) (bool, error) {
+	reply, err := c.Do(ctx, "ACL", "DELUSER", name)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected reply to ACL DELUSER: %v", reply)
+	}
+	return deleted > 0, nil
+}
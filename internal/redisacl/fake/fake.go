@@ -0,0 +1,410 @@
+// Package fake provides an in-memory Redis server supporting the commands used to manage users with ACLs, so the
+// plugin can be tested without a real Redis.
+//
+// The server behaves the way Redis documents:
+//   - connections must authenticate with AUTH before running any other command than AUTH and PING
+//   - only users allowed @admin, @all or the acl command may run ACL commands other than ACL WHOAMI
+//   - ACL SETUSER creates a user which does not exist, and rejects rules it does not understand
+//   - the default user cannot be deleted
+package fake
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type User struct {
+	Name      string
+	Enabled   bool
+	Passwords []string
+	Rules     []string
+}
+
+// Server is an in-memory Redis server. The zero value is not usable, use NewServer.
+type Server struct {
+	lock  sync.Mutex
+	users map[string]*User
+
+	listener net.Listener
+}
+
+// NewServer creates a server whose default user is disabled, so every connection must authenticate as a user added
+// with AddUser. Start must be called before it can be used.
+func NewServer() *Server {
+	return &Server{
+		users: map[string]*User{
+			"default": {Name: "default"},
+		},
+	}
+}
+
+// AddUser adds an enabled user with the password and rules.
+func (s *Server) AddUser(name string, password string, rules ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.users[name] = &User{Name: name, Enabled: true, Passwords: []string{password}, Rules: rules}
+}
+
+// User returns a copy of the user with the name.
+func (s *Server) User(name string) (User, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	user, ok := s.users[name]
+	if !ok {
+		return User{}, false
+	}
+	copied := *user
+	copied.Passwords = append([]string(nil), user.Passwords...)
+	copied.Rules = append([]string(nil), user.Rules...)
+	return copied, true
+}
+
+// Users returns the names of the users.
+func (s *Server) Users() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var names []string
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start listens on a local port and returns the URL of the server.
+func (s *Server) Start() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("fake: failed to listen: %v", err))
+	}
+	s.listener = listener
+
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+
+	return "redis://" + listener.Addr().String()
+}
+
+// Close stops listening. Open connections are closed by their clients.
+func (s *Server) Close() {
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+}
+
+func (s *Server) serve(c net.Conn) {
+	defer func() { _ = c.Close() }()
+
+	reader := bufio.NewReader(c)
+	writer := bufio.NewWriter(c)
+	user := ""
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if err != io.EOF {
+				writeError(writer, "ERR Protocol error: "+err.Error())
+				_ = writer.Flush()
+			}
+			return
+		}
+
+		s.lock.Lock()
+		user = s.command(writer, user, args)
+		s.lock.Unlock()
+
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// command runs the command for the connection authenticated as the user, and returns the user the connection is
+// authenticated as afterwards. The caller must hold the lock.
+func (s *Server) command(w *bufio.Writer, user string, args []string) string {
+	if len(args) == 0 {
+		writeError(w, "ERR empty command")
+		return user
+	}
+
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "PING":
+		writeSimple(w, "PONG")
+		return user
+	case "AUTH":
+		return s.auth(w, user, args[1:])
+	}
+
+	if user == "" {
+		writeError(w, "NOAUTH Authentication required.")
+		return user
+	}
+	if name != "ACL" || len(args) < 2 {
+		writeError(w, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return user
+	}
+
+	// Any user may find out who they are
+	if strings.ToUpper(args[1]) == "WHOAMI" {
+		writeBulk(w, user)
+		return user
+	}
+
+	if !s.allowedACL(user) {
+		writeError(w, fmt.Sprintf("NOPERM User %s has no permissions to run the 'acl|%s' command", user, strings.ToLower(args[1])))
+		return user
+	}
+
+	switch strings.ToUpper(args[1]) {
+	case "SETUSER":
+		s.setUser(w, args[2:])
+	case "GETUSER":
+		s.getUser(w, args[2:])
+	case "DELUSER":
+		s.delUser(w, args[2:])
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+
+	return user
+}
+
+func (s *Server) auth(w *bufio.Writer, user string, args []string) string {
+	name, password := "default", ""
+	switch len(args) {
+	case 1:
+		password = args[0]
+	case 2:
+		name, password = args[0], args[1]
+	default:
+		writeError(w, "ERR wrong number of arguments for 'auth' command")
+		return user
+	}
+
+	u, ok := s.users[name]
+	if !ok || !u.Enabled || !contains(u.Passwords, password) {
+		writeError(w, "WRONGPASS invalid username-password pair or user is disabled.")
+		return user
+	}
+
+	writeSimple(w, "OK")
+	return name
+}
+
+func (s *Server) allowedACL(name string) bool {
+	u, ok := s.users[name]
+	if !ok {
+		return false
+	}
+
+	allowed := false
+	for _, rule := range u.Rules {
+		switch rule {
+		case "+@all", "allcommands", "+@admin", "+acl":
+			allowed = true
+		case "-@all", "nocommands", "-@admin", "-acl":
+			allowed = false
+		}
+	}
+	return allowed
+}
+
+func (s *Server) setUser(w *bufio.Writer, args []string) {
+	if len(args) == 0 {
+		writeError(w, "ERR wrong number of arguments for 'acl|setuser' command")
+		return
+	}
+
+	existing, ok := s.users[args[0]]
+	u := User{Name: args[0]}
+	if ok {
+		u = *existing
+		u.Passwords = append([]string(nil), existing.Passwords...)
+		u.Rules = append([]string(nil), existing.Rules...)
+	}
+
+	// The rules are applied to a copy, so the user is not changed if any of the rules are invalid
+	for _, rule := range args[1:] {
+		switch {
+		case rule == "on":
+			u.Enabled = true
+		case rule == "off":
+			u.Enabled = false
+		case rule == "reset":
+			u = User{Name: u.Name}
+		case rule == "resetpass" || rule == "nopass":
+			u.Passwords = nil
+		case strings.HasPrefix(rule, ">"):
+			if !contains(u.Passwords, rule[1:]) {
+				u.Passwords = append(u.Passwords, rule[1:])
+			}
+		case strings.HasPrefix(rule, "<"):
+			u.Passwords = remove(u.Passwords, rule[1:])
+		case validRule(rule):
+			u.Rules = append(u.Rules, rule)
+		default:
+			writeError(w, fmt.Sprintf("ERR Error in ACL SETUSER modifier '%s': Syntax error", rule))
+			return
+		}
+	}
+
+	s.users[u.Name] = &u
+	writeSimple(w, "OK")
+}
+
+func validRule(rule string) bool {
+	switch rule {
+	case "allkeys", "resetkeys", "allchannels", "resetchannels", "allcommands", "nocommands":
+		return true
+	}
+	return len(rule) > 1 && strings.ContainsRune("+-~%&", rune(rule[0]))
+}
+
+// getUser replies with the flags, hashed passwords and rules of the user, or nil if it does not exist.
+func (s *Server) getUser(w *bufio.Writer, args []string) {
+	if len(args) != 1 {
+		writeError(w, "ERR wrong number of arguments for 'acl|getuser' command")
+		return
+	}
+
+	u, ok := s.users[args[0]]
+	if !ok {
+		_, _ = w.WriteString("*-1\r\n")
+		return
+	}
+
+	flags := []string{"off"}
+	if u.Enabled {
+		flags = []string{"on"}
+	}
+	var hashes []string
+	for _, password := range u.Passwords {
+		sum := sha256.Sum256([]byte(password))
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+
+	_, _ = w.WriteString("*6\r\n")
+	writeBulk(w, "flags")
+	writeArray(w, flags)
+	writeBulk(w, "passwords")
+	writeArray(w, hashes)
+	writeBulk(w, "commands")
+	writeBulk(w, strings.Join(u.Rules, " "))
+}
+
+func (s *Server) delUser(w *bufio.Writer, args []string) {
+	deleted := 0
+	for _, name := range args {
+		if name == "default" {
+			writeError(w, "ERR The 'default' user cannot be removed")
+			return
+		}
+	}
+	for _, name := range args {
+		if _, ok := s.users[name]; ok {
+			delete(s.users, name)
+			deleted++
+		}
+	}
+	_, _ = fmt.Fprintf(w, ":%d\r\n", deleted)
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected '*', got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multibulk length")
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected '$', got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length")
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		args = append(args, string(value[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+func writeSimple(w *bufio.Writer, value string) {
+	_, _ = fmt.Fprintf(w, "+%s\r\n", value)
+}
+
+func writeError(w *bufio.Writer, message string) {
+	_, _ = fmt.Fprintf(w, "-%s\r\n", message)
+}
+
+func writeBulk(w *bufio.Writer, value string) {
+	_, _ = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+}
+
+func writeArray(w *bufio.Writer, values []string) {
+	_, _ = fmt.Fprintf(w, "*%d\r\n", len(values))
+	for _, value := range values {
+		writeBulk(w, value)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
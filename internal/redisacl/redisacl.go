@@ -0,0 +1,215 @@
+// Package redisacl implements the client used by the plugin to manage users directly in a Redis database with the ACL
+// commands, for when there is no access to the API of the cluster the database is in.
+package redisacl
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// The timeout for a command, when the context does not have an earlier deadline.
+const timeout = 60 * time.Second
+
+// The default size of the pool of idle connections to the database, and how long an idle connection is kept for.
+const (
+	defaultMaxIdleConns    = 10
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
+type Client struct {
+	address   string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	log       hclog.Logger
+
+	lock            sync.Mutex
+	idle            []*conn
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+}
+
+func NewClient(log hclog.Logger) *Client {
+	return &Client{
+		log:             log,
+		maxIdleConns:    defaultMaxIdleConns,
+		idleConnTimeout: defaultIdleConnTimeout,
+	}
+}
+
+// Initialise sets the database to connect to, with a URL such as redis://host:6379 or rediss://host:6380 for TLS, and
+// the user to authenticate as. Any connections to a previous database are closed.
+func (c *Client) Initialise(rawURL string, username string, password string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.User != nil {
+		return errors.New("the url must not contain credentials, use the username and password instead")
+	}
+
+	var tlsConfig *tls.Config
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		tlsConfig = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return fmt.Errorf("the url must start with redis:// or rediss://, not %s://", u.Scheme)
+	}
+
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	_ = c.Close()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.address = address
+	c.tlsConfig = tlsConfig
+	c.username = username
+	c.password = password
+	return nil
+}
+
+// SetConnectionPool sets the number of idle connections to the database that are kept for reuse and how long they are
+// kept for, with a zero value using the default.
+func (c *Client) SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) {
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.maxIdleConns = maxIdleConns
+	c.idleConnTimeout = idleConnTimeout
+}
+
+// Close closes the idle connections to the database.
+func (c *Client) Close() error {
+	c.lock.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.lock.Unlock()
+
+	for _, cn := range idle {
+		_ = cn.Close()
+	}
+	return nil
+}
+
+// Do runs the command and returns its reply. An error reply from Redis is returned as an Error.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cn.SetDeadline(deadline(ctx)); err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+
+	reply, err := cn.do(args...)
+
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = cn.Close()
+		return nil, fmt.Errorf("unable to run %s on %s: %w", args[0], c.address, err)
+	}
+
+	c.put(cn)
+	return reply, err
+}
+
+// get returns an idle connection, or a new connection if there are none.
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.lock.Lock()
+	for len(c.idle) > 0 {
+		cn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		if time.Since(cn.lastUsed) < c.idleConnTimeout {
+			c.lock.Unlock()
+			return cn, nil
+		}
+		_ = cn.Close()
+	}
+	address, tlsConfig, username, password := c.address, c.tlsConfig, c.username, c.password
+	c.lock.Unlock()
+
+	return c.dial(ctx, address, tlsConfig, username, password)
+}
+
+// put returns the connection to the pool, or closes it if the pool is full.
+func (c *Client) put(cn *conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.idle) >= c.maxIdleConns {
+		_ = cn.Close()
+		return
+	}
+	cn.lastUsed = time.Now()
+	c.idle = append(c.idle, cn)
+}
+
+// dial connects and authenticates to the database.
+func (c *Client) dial(ctx context.Context, address string, tlsConfig *tls.Config, username string, password string) (*conn, error) {
+	if address == "" {
+		return nil, errors.New("the client has not been initialised")
+	}
+
+	var dialer net.Dialer
+	nc, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", address, err)
+	}
+
+	if tlsConfig != nil {
+		tlsConn := tls.Client(nc, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("unable to connect to %s: %w", address, err)
+		}
+		nc = tlsConn
+	}
+
+	cn := newConn(nc)
+	if err := cn.SetDeadline(deadline(ctx)); err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+
+	if password != "" {
+		args := []string{"AUTH", password}
+		if username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := cn.do(args...); err != nil {
+			_ = cn.Close()
+			return nil, fmt.Errorf("unable to authenticate to %s as %s: %w", address, username, err)
+		}
+	}
+
+	return cn, nil
+}
+
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,129 @@
+package redisacl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply from Redis, such as "NOPERM this user has no permissions to run the 'acl' command".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Code returns the first word of the error, e.g. NOPERM or WRONGPASS.
+func (e Error) Code() string {
+	code, _, _ := strings.Cut(string(e), " ")
+	return code
+}
+
+// conn is a single connection to Redis, speaking RESP2.
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+
+	// lastUsed is when the connection was returned to the pool
+	lastUsed time.Time
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{
+		Conn:   c,
+		reader: bufio.NewReader(c),
+		writer: bufio.NewWriter(c),
+	}
+}
+
+// do sends the command and reads its reply. An error reply is returned as an Error, any other error means the
+// connection can no longer be used.
+func (c *conn) do(args ...string) (interface{}, error) {
+	if err := c.write(args); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// write sends the command as an array of bulk strings.
+func (c *conn) write(args []string) error {
+	if _, err := fmt.Fprintf(c.writer, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return c.writer.Flush()
+}
+
+// read reads a reply, which is a string, an int64, nil, a []interface{} of replies or an Error.
+func (c *conn) read() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return string(value[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			value, err := c.read()
+			var redisErr Error
+			if err != nil && !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			if err != nil {
+				value = err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unsupported reply %q", line)
+}
+
+func (c *conn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
@@ -36,5 +36,27 @@ func (c *Client) FindACLByName(ctx context.Context, name string) (*ACL, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("unable to find acl %s", name)
+	return nil, &ACLNotFoundError{name}
+}
+
+func (c *Client) CreateACL(ctx context.Context, create CreateACL) (ACL, error) {
+	var body ACL
+	if err := c.request(ctx, http.MethodPost, "/v1/redis_acls", create, &body); err != nil {
+		return ACL{}, err
+	}
+	return body, nil
+}
+
+func (c *Client) UpdateACL(ctx context.Context, id int, update UpdateACL) error {
+	if err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/redis_acls/%d", id), update, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) DeleteACL(ctx context.Context, id int) error {
+	if err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/redis_acls/%d", id), nil, nil); err != nil {
+		return err
+	}
+	return nil
 }
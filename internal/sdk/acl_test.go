@@ -0,0 +1,42 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ACLs(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	ctx := context.Background()
+
+	acl, err := subject.CreateACL(ctx, CreateACL{Name: "Read Only", ACL: "+@read ~*"})
+	require.NoError(t, err)
+
+	require.NoError(t, subject.UpdateACL(ctx, acl.UID, UpdateACL{ACL: "+@read ~cache:*"}))
+	found, err := subject.FindACLByName(ctx, "Read Only")
+	require.NoError(t, err)
+	assert.Equal(t, "+@read ~cache:*", found.ACL)
+
+	_, err = subject.CreateACL(ctx, CreateACL{Name: "Read Only", ACL: "+@read ~*"})
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusConflict})
+
+	require.NoError(t, subject.DeleteACL(ctx, acl.UID))
+	assert.Empty(t, cluster.ACLs())
+}
+
+func TestClient_DeleteACL_inUse(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+
+	acl := cluster.AddACL("Read Only", "+@read ~*")
+	role := cluster.AddRole("reader", "db_member")
+	cluster.AddDatabase("mydb", fake.RolePermission{RoleUID: role, ACLUID: acl})
+
+	err := subject.DeleteACL(context.Background(), acl)
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusConflict})
+	assert.Len(t, cluster.ACLs(), 1)
+}
@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The default time to live of the tokens issued by the cluster when token authentication is enabled.
+const defaultTokenTTL = 5 * time.Minute
+
+// tokenAuth holds the API token exchanged for the credentials of the client.
+type tokenAuth struct {
+	ttl time.Duration
+
+	lock    sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// refreshAt is the point at which the token should be refreshed - a fifth of the way before it expires.
+func (t *tokenAuth) refreshAt() time.Time {
+	return t.expires.Add(-t.ttl / 5)
+}
+
+type authorizeRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	TTL      int    `json:"ttl"`
+}
+
+type accessToken struct {
+	AccessToken string `json:"access_token"`
+}
+
+// SetTokenAuth changes the client to exchange the credentials for a short-lived API token, which is then sent on
+// later requests instead of the password. The token is refreshed before it expires, and the credentials exchanged
+// again if the cluster rejects it. A zero ttl uses the default of 5 minutes.
+func (c *Client) SetTokenAuth(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	c.token = &tokenAuth{ttl: ttl}
+}
+
+// setAuthorization adds the credentials to the request, using basic authentication unless token authentication has
+// been enabled.
+func (c *Client) setAuthorization(ctx context.Context, req *http.Request) error {
+	if c.token == nil {
+		req.SetBasicAuth(c.username, c.password)
+		return nil
+	}
+
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "JWT "+token)
+	return nil
+}
+
+// currentToken returns a token which is valid for a while yet, refreshing or obtaining a new token as required.
+func (c *Client) currentToken(ctx context.Context) (string, error) {
+	c.token.lock.Lock()
+	defer c.token.lock.Unlock()
+
+	now := time.Now()
+	if c.token.value != "" && now.Before(c.token.refreshAt()) {
+		return c.token.value, nil
+	}
+
+	if c.token.value != "" && now.Before(c.token.expires) {
+		err := c.refreshToken(ctx)
+		if err == nil {
+			return c.token.value, nil
+		}
+		c.log.Debug("unable to refresh token, re-authorising", "err", err)
+	}
+
+	if err := c.authorise(ctx); err != nil {
+		return "", err
+	}
+
+	return c.token.value, nil
+}
+
+// authorise exchanges the credentials for a new token. The caller must hold the token lock.
+func (c *Client) authorise(ctx context.Context) error {
+	issued := time.Now()
+
+	var body accessToken
+	if err := c.send(ctx, http.MethodPost, "/v1/users/authorize", authorizeRequest{
+		Username: c.username,
+		Password: c.password,
+		TTL:      int(c.token.ttl.Seconds()),
+	}, &body, nil); err != nil {
+		return fmt.Errorf("unable to authorise with the cluster: %w", err)
+	}
+
+	c.token.value = body.AccessToken
+	c.token.expires = issued.Add(c.token.ttl)
+	return nil
+}
+
+// refreshToken exchanges the current, still valid, token for a new one without sending the credentials. The caller
+// must hold the token lock.
+func (c *Client) refreshToken(ctx context.Context) error {
+	issued := time.Now()
+	current := c.token.value
+
+	var body accessToken
+	if err := c.send(ctx, http.MethodPost, "/v1/users/refresh_jwt", authorizeRequest{
+		TTL: int(c.token.ttl.Seconds()),
+	}, &body, func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "JWT "+current)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.token.value = body.AccessToken
+	c.token.expires = issued.Add(c.token.ttl)
+	return nil
+}
+
+// resetToken discards the current token so the next request will authorise again.
+func (c *Client) resetToken() {
+	c.token.lock.Lock()
+	defer c.token.lock.Unlock()
+
+	c.token.value = ""
+	c.token.expires = time.Time{}
+}
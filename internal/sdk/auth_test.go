@@ -0,0 +1,196 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer is a cluster API which only accepts tokens, issuing a new token every time the credentials are
+// exchanged or the token refreshed.
+type tokenServer struct {
+	t        *testing.T
+	username string
+	password string
+
+	lock       sync.Mutex
+	issued     int
+	valid      map[string]bool
+	authorised int
+	refreshed  int
+}
+
+func newTokenServer(t *testing.T, username string, password string) (*tokenServer, string) {
+	t.Helper()
+
+	s := &tokenServer{
+		t:        t,
+		username: username,
+		password: password,
+		valid:    map[string]bool{},
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, _, ok := r.BasicAuth(); ok {
+		http.Error(w, "basic auth is not expected", http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/users/authorize":
+		var body authorizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Username != s.username || body.Password != s.password {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(s.t, 60, body.TTL)
+		s.authorised++
+		s.issue(w)
+	case "/v1/users/refresh_jwt":
+		if !s.authorisedRequest(r) {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		s.refreshed++
+		s.issue(w)
+	default:
+		if !s.authorisedRequest(r) {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"name":"cluster"}`)
+	}
+}
+
+func (s *tokenServer) issue(w http.ResponseWriter) {
+	s.issued++
+	token := fmt.Sprintf("token-%d", s.issued)
+	s.valid[token] = true
+	_ = json.NewEncoder(w).Encode(accessToken{AccessToken: token})
+}
+
+func (s *tokenServer) authorisedRequest(r *http.Request) bool {
+	return s.valid[r.Header.Get("Authorization")[len("JWT "):]]
+}
+
+func (s *tokenServer) revokeAll() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.valid = map[string]bool{}
+}
+
+func TestClient_SetTokenAuth_authorisesOnce(t *testing.T) {
+	server, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "Password")
+	subject.SetTokenAuth(time.Minute)
+
+	for i := 0; i < 3; i++ {
+		_, err := subject.GetCluster(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, server.authorised)
+	assert.Equal(t, 0, server.refreshed)
+}
+
+func TestClient_SetTokenAuth_refreshesBeforeExpiry(t *testing.T) {
+	server, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "Password")
+	subject.SetTokenAuth(time.Minute)
+
+	_, err := subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	// Move the expiry of the token so it is due for a refresh
+	subject.token.expires = time.Now().Add(5 * time.Second)
+
+	_, err = subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, server.authorised)
+	assert.Equal(t, 1, server.refreshed)
+	assert.Equal(t, "token-2", subject.token.value)
+}
+
+func TestClient_SetTokenAuth_reauthorisesAfterExpiry(t *testing.T) {
+	server, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "Password")
+	subject.SetTokenAuth(time.Minute)
+
+	_, err := subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	subject.token.expires = time.Now().Add(-time.Second)
+
+	_, err = subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, server.authorised)
+	assert.Equal(t, 0, server.refreshed)
+}
+
+func TestClient_SetTokenAuth_reauthorisesOnUnauthorised(t *testing.T) {
+	server, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "Password")
+	subject.SetTokenAuth(time.Minute)
+
+	_, err := subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	server.revokeAll()
+
+	_, err = subject.GetCluster(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, server.authorised)
+}
+
+func TestClient_SetTokenAuth_reportsBadCredentials(t *testing.T) {
+	_, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "wrong")
+	subject.SetTokenAuth(time.Minute)
+
+	_, err := subject.GetCluster(context.Background())
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusUnauthorized, path: "/v1/users/authorize"})
+}
+
+func TestClient_Initialise_disablesTokenAuth(t *testing.T) {
+	_, url := newTokenServer(t, "expected", "Password")
+
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise(url, "expected", "Password")
+	subject.SetTokenAuth(time.Minute)
+
+	// The token server rejects basic authentication, which is used again until token authentication is re-enabled
+	subject.Initialise(url, "expected", "Password")
+	_, err := subject.GetCluster(context.Background())
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusBadRequest, path: "/v1/cluster"})
+}
@@ -0,0 +1,120 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// The states of a CRDB task which has finished being applied
+const (
+	crdbTaskFinished = "finished"
+	crdbTaskFailed   = "failed"
+)
+
+// How often to check the progress of a change to an Active-Active database, and how long to wait for it to be applied
+// to every instance.
+var (
+	crdbTaskPollInterval = 500 * time.Millisecond
+	crdbTaskTimeout      = 2 * time.Minute
+)
+
+func (c *Client) GetCRDB(ctx context.Context, guid string) (CRDB, error) {
+	var body CRDB
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/crdbs/%s", guid), nil, &body); err != nil {
+		return CRDB{}, err
+	}
+
+	return body, nil
+}
+
+// UpdateCRDB starts applying the update to every instance of the Active-Active database, returning the task tracking
+// its progress.
+func (c *Client) UpdateCRDB(ctx context.Context, guid string, update UpdateCRDB) (CRDBTask, error) {
+	var body CRDBTask
+	if err := c.request(ctx, http.MethodPatch, fmt.Sprintf("/v1/crdbs/%s", guid), update, &body); err != nil {
+		return CRDBTask{}, err
+	}
+
+	return body, nil
+}
+
+func (c *Client) GetCRDBTask(ctx context.Context, id string) (CRDBTask, error) {
+	var body CRDBTask
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/crdb_tasks/%s", id), nil, &body); err != nil {
+		return CRDBTask{}, err
+	}
+
+	return body, nil
+}
+
+// WaitForCRDBTask waits until the task has been applied to every instance of the Active-Active database.
+func (c *Client) WaitForCRDBTask(ctx context.Context, id string) error {
+	if c.dryRun {
+		// The update which would have started the task was not sent
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, crdbTaskTimeout)
+	defer cancel()
+
+	for {
+		task, err := c.GetCRDBTask(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		switch task.Status {
+		case crdbTaskFinished:
+			return nil
+		case crdbTaskFailed:
+			return fmt.Errorf("crdb task %s failed: %s", id, task.ErrorMessage)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("crdb task %s has not finished (%s): %w", id, task.Status, ctx.Err())
+		case <-time.After(crdbTaskPollInterval):
+		}
+	}
+}
+
+// UpdateCRDBRolePermissions applies the update to the current roles_permissions of the Active-Active database and
+// waits for it to reach every instance. As with UpdateDatabaseRolePermissions, the database is read again before every
+// attempt so a conflicting (409) change made by someone else is kept. The roles_permissions before and after the
+// update are returned.
+func (c *Client) UpdateCRDBRolePermissions(ctx context.Context, guid string, update func([]CRDBRolePermission) []CRDBRolePermission) ([]CRDBRolePermission, []CRDBRolePermission, error) {
+	for i := 0; i < updateRolePermissionsRetryLimit; i++ {
+		attemptCtx := withRetryAttempt(ctx, i)
+
+		crdb, err := c.GetCRDB(attemptCtx, guid)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		before := crdb.DefaultDBConfig.RolePermissions
+		after := update(append([]CRDBRolePermission(nil), before...))
+
+		task, err := c.UpdateCRDB(attemptCtx, guid, UpdateCRDB{DefaultDBConfig: CRDBConfig{RolePermissions: after}})
+		if err != nil {
+			if errors.Is(err, &HttpError{status: http.StatusConflict}) {
+				metrics.IncrCounter([]string{"sdk", "update_crdb", "conflict"}, 1)
+				time.Sleep(conflictBackoff())
+				continue
+			}
+			return nil, nil, err
+		}
+
+		if err := c.WaitForCRDBTask(ctx, task.ID); err != nil {
+			return nil, nil, err
+		}
+
+		return before, after, nil
+	}
+
+	return nil, nil, fmt.Errorf("cannot update crdb %s roles_permissions - too many retries after conflicts (409)", guid)
+}
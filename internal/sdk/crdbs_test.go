@@ -0,0 +1,63 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+)
+
+func TestClient_UpdateCRDBRolePermissions(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	role := cluster.AddRole("role", "db_member")
+	guid, dbUID := cluster.AddCRDB("aadb", "other")
+	cluster.SetUpdateDuration(600 * time.Millisecond)
+
+	before, after, err := subject.UpdateCRDBRolePermissions(context.Background(), guid, func(permissions []CRDBRolePermission) []CRDBRolePermission {
+		return append(permissions, CRDBRolePermission{Role: "role", ACL: "acl"})
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, before)
+	assert.Equal(t, []CRDBRolePermission{{Role: "role", ACL: "acl"}}, after)
+
+	crdb, ok := cluster.CRDB(guid)
+	require.True(t, ok)
+	assert.Equal(t, []fake.CRDBRolePermission{{Role: "role", ACL: "acl"}}, crdb.DefaultDBConfig.RolePermissions)
+
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Equal(t, []fake.RolePermission{{RoleUID: role, ACLUID: acl}}, db.RolePermissions)
+}
+
+func TestClient_FindDatabaseByName_crdb(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	guid, _ := cluster.AddCRDB("aadb", "other")
+
+	db, err := subject.FindDatabaseByName(context.Background(), "aadb")
+	require.NoError(t, err)
+
+	assert.True(t, db.CRDT)
+	assert.Equal(t, guid, db.CRDTGUID)
+
+	crdb, err := subject.GetCRDB(context.Background(), guid)
+	require.NoError(t, err)
+	assert.Len(t, crdb.Instances, 2)
+}
+
+func TestClient_WaitForCRDBTask_failed(t *testing.T) {
+	url := testServer(t, "/v1/crdb_tasks/abc", http.MethodGet, "user", "pass", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id": "abc", "status": "failed", "error_message": "instance unreachable"}`))
+	})
+
+	subject := &Client{url: url, username: "user", password: "pass", client: http.DefaultClient}
+
+	err := subject.WaitForCRDBTask(context.Background(), "abc")
+	assert.EqualError(t, err, "crdb task abc failed: instance unreachable")
+}
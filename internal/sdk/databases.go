@@ -4,12 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"time"
+
+	metrics "github.com/armon/go-metrics"
 )
 
 const updateRolePermissionsRetryLimit = 30
 
+// conflictBackoff is how long to wait before retrying an update to a database which conflicted with another. The wait
+// is randomised around 500ms so that several instances of the plugin conflicting with each other do not retry in
+// lockstep.
+func conflictBackoff() time.Duration {
+	return 250*time.Millisecond + time.Duration(rand.Int63n(int64(500*time.Millisecond)))
+}
+
 func (c *Client) ListDatabases(ctx context.Context) ([]Database, error) {
 	var body []Database
 	if err := c.request(ctx, http.MethodGet, "/v1/bdbs", nil, &body); err != nil {
@@ -19,6 +29,15 @@ func (c *Client) ListDatabases(ctx context.Context) ([]Database, error) {
 	return body, nil
 }
 
+func (c *Client) GetDatabase(ctx context.Context, id int) (Database, error) {
+	var body Database
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/v1/bdbs/%d", id), nil, &body); err != nil {
+		return Database{}, err
+	}
+
+	return body, nil
+}
+
 func (c *Client) UpdateDatabase(ctx context.Context, id int, update UpdateDatabase) error {
 	if err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/bdbs/%d", id), update, nil); err != nil {
 		return err
@@ -27,12 +46,74 @@ func (c *Client) UpdateDatabase(ctx context.Context, id int, update UpdateDataba
 	return nil
 }
 
-func (c *Client) UpdateDatabaseWithRetry(ctx context.Context, id int, update UpdateDatabase) error {
+// ValidateDatabaseUpdate checks whether the database would accept the update, without applying it.
+func (c *Client) ValidateDatabaseUpdate(ctx context.Context, id int, update UpdateDatabase) error {
+	if err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/bdbs/%d?dry_run=true", id), update, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateDatabaseRolePermissions applies the update to the current roles_permissions of the database. The database is
+// read again before every attempt, so an update which conflicts (409) with a change made by someone else, such as
+// another instance of the plugin, is applied on top of that change rather than overwriting it. The roles_permissions
+// before and after the update are returned.
+func (c *Client) UpdateDatabaseRolePermissions(ctx context.Context, id int, update func([]RolePermission) []RolePermission) ([]RolePermission, []RolePermission, error) {
+	var before, after []RolePermission
+	err := c.updateDatabaseOnLatest(ctx, id, "roles_permissions", func(db Database) interface{} {
+		before = db.RolePermissions
+		after = update(append([]RolePermission(nil), before...))
+		return UpdateDatabase{RolePermissions: after}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+// UpdateDatabaseAuthorizedSubjects applies the update to the current authorized_subjects of the database, retrying
+// after conflicts in the same way as UpdateDatabaseRolePermissions. The authorized_subjects before and after the
+// update are returned.
+func (c *Client) UpdateDatabaseAuthorizedSubjects(ctx context.Context, id int, update func([]Subject) []Subject) ([]Subject, []Subject, error) {
+	var before, after []Subject
+	err := c.updateDatabaseOnLatest(ctx, id, "authorized_subjects", func(db Database) interface{} {
+		before = db.AuthorizedSubjects
+		after = update(append([]Subject(nil), before...))
+		if after == nil {
+			// An empty list removes every subject, whereas null would be rejected
+			after = []Subject{}
+		}
+		return updateAuthorizedSubjects{AuthorizedSubjects: after}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+type updateAuthorizedSubjects struct {
+	AuthorizedSubjects []Subject `json:"authorized_subjects"`
+}
+
+// updateDatabaseOnLatest reads the database and sends the update built from it, reading it again and retrying if the
+// update conflicts (409) with a change made by someone else.
+func (c *Client) updateDatabaseOnLatest(ctx context.Context, id int, field string, update func(Database) interface{}) error {
 	for i := 0; i < updateRolePermissionsRetryLimit; i++ {
-		err := c.UpdateDatabase(ctx, id, update)
+		attemptCtx := withRetryAttempt(ctx, i)
+
+		db, err := c.GetDatabase(attemptCtx, id)
+		if err != nil {
+			return err
+		}
+
+		err = c.request(attemptCtx, http.MethodPut, fmt.Sprintf("/v1/bdbs/%d", id), update(db), nil)
 		if err != nil {
 			if errors.Is(err, &HttpError{status: http.StatusConflict}) {
-				time.Sleep(500 * time.Millisecond)
+				metrics.IncrCounter([]string{"sdk", "update_database", "conflict"}, 1)
+				time.Sleep(conflictBackoff())
 				continue
 			}
 			return err
@@ -41,7 +122,7 @@ func (c *Client) UpdateDatabaseWithRetry(ctx context.Context, id int, update Upd
 		return nil
 	}
 
-	return fmt.Errorf("cannot update database %d roles_permissions - too many retries after conflicts (409)", id)
+	return fmt.Errorf("cannot update database %d %s - too many retries after conflicts (409)", id, field)
 }
 
 func (c *Client) FindDatabaseByName(ctx context.Context, name string) (Database, error) {
@@ -56,5 +137,5 @@ func (c *Client) FindDatabaseByName(ctx context.Context, name string) (Database,
 		}
 	}
 
-	return Database{}, fmt.Errorf("unable to find database %s", name)
+	return Database{}, &DatabaseNotFoundError{name}
 }
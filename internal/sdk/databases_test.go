@@ -2,102 +2,158 @@ package sdk
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
 )
 
-func TestClient_UpdateDatabaseWithRetry_retries(t *testing.T) {
-	counter := 0
-	var body []byte
-	username := "expected"
-	password := "Password"
-
-	url := testServer(t, "/v1/bdbs/3", http.MethodPut, username, password, func(w http.ResponseWriter, r *http.Request) {
-		counter++
-		if counter < 3 {
-			http.Error(w, "try again", http.StatusConflict)
-			return
-		}
+func TestClient_UpdateDatabaseRolePermissions_waitsForAction(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	role := cluster.AddRole("role", "db_member")
+	dbUID := cluster.AddDatabase("db")
+	cluster.SetUpdateDuration(700 * time.Millisecond)
 
-		var err error
-		body, err = ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("can't read body %s", err), http.StatusInternalServerError)
-			return
-		}
+	ctx := context.Background()
 
-		w.WriteHeader(http.StatusOK)
+	// The first update starts an action, so the second is rejected until the action completes
+	require.NoError(t, subject.UpdateDatabase(ctx, dbUID, UpdateDatabase{RolePermissions: []RolePermission{}}))
+	_, _, err := subject.UpdateDatabaseRolePermissions(ctx, dbUID, func(permissions []RolePermission) []RolePermission {
+		return append(permissions, RolePermission{RoleUID: role, ACLUID: acl})
 	})
+	require.NoError(t, err)
+
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Equal(t, []fake.RolePermission{{RoleUID: role, ACLUID: acl}}, db.RolePermissions)
+}
+
+func TestClient_UpdateDatabaseRolePermissions_rejectsUnknownRole(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	dbUID := cluster.AddDatabase("db")
 
-	subject := &Client{
-		url:      url,
-		username: username,
-		password: password,
-		client:   http.DefaultClient,
-	}
-
-	err := subject.UpdateDatabaseWithRetry(context.TODO(), 3, UpdateDatabase{
-		RolePermissions: []RolePermission{
-			{
-				RoleUID: 1,
-				ACLUID:  2,
-			},
-		},
+	_, _, err := subject.UpdateDatabaseRolePermissions(context.Background(), dbUID, func(permissions []RolePermission) []RolePermission {
+		return append(permissions, RolePermission{RoleUID: 999, ACLUID: acl})
 	})
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusBadRequest})
+}
 
+func TestClient_UpdateDatabaseRolePermissions_keepsConcurrentChanges(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	mine := cluster.AddRole("mine", "db_member")
+	theirs := cluster.AddRole("theirs", "db_member")
+	dbUID := cluster.AddDatabase("db")
+	// Shorter than the backoff, so the update only conflicts once
+	cluster.SetUpdateDuration(200 * time.Millisecond)
+
+	ctx := context.Background()
+	calls := 0
+
+	before, after, err := subject.UpdateDatabaseRolePermissions(ctx, dbUID, func(permissions []RolePermission) []RolePermission {
+		calls++
+		if calls == 1 {
+			// Someone else changes the database after it has been read, so this update conflicts
+			require.NoError(t, subject.UpdateDatabase(ctx, dbUID, UpdateDatabase{
+				RolePermissions: []RolePermission{{RoleUID: theirs, ACLUID: acl}},
+			}))
+		}
+		return append(permissions, RolePermission{RoleUID: mine, ACLUID: acl})
+	})
 	require.NoError(t, err)
-	assert.JSONEq(t, `{"roles_permissions": [{"role_uid": 1, "redis_acl_uid": 2}]}`, string(body))
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []RolePermission{{RoleUID: theirs, ACLUID: acl}}, before)
+	assert.Equal(t, []RolePermission{{RoleUID: theirs, ACLUID: acl}, {RoleUID: mine, ACLUID: acl}}, after)
+
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Equal(t, []fake.RolePermission{{RoleUID: theirs, ACLUID: acl}, {RoleUID: mine, ACLUID: acl}}, db.RolePermissions)
 }
 
-func TestClient_UpdateDatabaseWithRetry_givesUpOnError(t *testing.T) {
-	counter := 0
-	var body []byte
-	username := "expected"
-	password := "Password"
-
-	url := testServer(t, "/v1/bdbs/3", http.MethodPut, username, password, func(w http.ResponseWriter, r *http.Request) {
-		counter++
-		if counter < 3 {
-			http.Error(w, "try again", http.StatusConflict)
-			return
+func TestClient_UpdateDatabaseAuthorizedSubjects_keepsConcurrentChanges(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	role := cluster.AddRole("role", "db_member")
+	dbUID := cluster.AddDatabase("db", fake.RolePermission{RoleUID: role, ACLUID: acl})
+	// Shorter than the backoff, so the update only conflicts once
+	cluster.SetUpdateDuration(200 * time.Millisecond)
+
+	ctx := context.Background()
+	mine := Subject{CN: "mine", OU: []string{"billing"}}
+	theirs := Subject{CN: "theirs"}
+	calls := 0
+
+	before, after, err := subject.UpdateDatabaseAuthorizedSubjects(ctx, dbUID, func(subjects []Subject) []Subject {
+		calls++
+		if calls == 1 {
+			// Someone else changes the database after it has been read, so this update conflicts
+			_, _, err := subject.UpdateDatabaseAuthorizedSubjects(ctx, dbUID, func(subjects []Subject) []Subject {
+				return append(subjects, theirs)
+			})
+			require.NoError(t, err)
 		}
+		return append(subjects, mine)
+	})
+	require.NoError(t, err)
 
-		var err error
-		body, err = ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("can't read body %s", err), http.StatusInternalServerError)
-			return
-		}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []Subject{theirs}, before)
+	assert.Equal(t, []Subject{theirs, mine}, after)
+
+	// Only the authorized_subjects are updated
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Equal(t, []fake.Subject{{CN: "theirs"}, {CN: "mine", OU: []string{"billing"}}}, db.AuthorizedSubjects)
+	assert.Equal(t, []fake.RolePermission{{RoleUID: role, ACLUID: acl}}, db.RolePermissions)
+}
+
+func TestClient_UpdateDatabaseAuthorizedSubjects_removesLast(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	dbUID := cluster.AddDatabase("db")
 
-		http.Error(w, "done", http.StatusTeapot)
+	ctx := context.Background()
+	_, _, err := subject.UpdateDatabaseAuthorizedSubjects(ctx, dbUID, func(subjects []Subject) []Subject {
+		return append(subjects, Subject{CN: "billing"})
 	})
+	require.NoError(t, err)
 
-	subject := &Client{
-		url:      url,
-		username: username,
-		password: password,
-		client:   http.DefaultClient,
-	}
-
-	err := subject.UpdateDatabaseWithRetry(context.TODO(), 3, UpdateDatabase{
-		RolePermissions: []RolePermission{
-			{
-				RoleUID: 1,
-				ACLUID:  2,
-			},
-		},
+	_, after, err := subject.UpdateDatabaseAuthorizedSubjects(ctx, dbUID, func(subjects []Subject) []Subject {
+		return nil
 	})
+	require.NoError(t, err)
+	assert.Equal(t, []Subject{}, after)
 
-	assert.Equal(t, &HttpError{
-		method: "PUT",
-		path:   "/v1/bdbs/3",
-		status: 418,
-		body:   "done",
-	}, err)
-	assert.JSONEq(t, `{"roles_permissions": [{"role_uid": 1, "redis_acl_uid": 2}]}`, string(body))
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Empty(t, db.AuthorizedSubjects)
+}
+
+func TestClient_ValidateDatabaseUpdate(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	role := cluster.AddRole("role", "db_member")
+	dbUID := cluster.AddDatabase("db")
+
+	ctx := context.Background()
+
+	require.NoError(t, subject.ValidateDatabaseUpdate(ctx, dbUID, UpdateDatabase{
+		RolePermissions: []RolePermission{{RoleUID: role, ACLUID: acl}},
+	}))
+
+	// The update is only validated
+	db, ok := cluster.Database(dbUID)
+	require.True(t, ok)
+	assert.Empty(t, db.RolePermissions)
+
+	err := subject.ValidateDatabaseUpdate(ctx, dbUID, UpdateDatabase{
+		RolePermissions: []RolePermission{{RoleUID: 999, ACLUID: acl}},
+	})
+	assert.True(t, IsValidation(err))
 }
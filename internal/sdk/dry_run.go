@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// redactedFields are the fields of a request body which are never logged, at any depth.
+var redactedFields = map[string]bool{
+	"password": true,
+}
+
+// SetDryRun changes whether the client only logs the changes it would make to the cluster. Every read is still made,
+// but each create, update and delete is logged with its payload, with any secret redacted, instead of being sent, and
+// succeeds with an empty response.
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// isChange returns whether a request changes the cluster. Updates the cluster is only asked to validate, with the
+// dry_run query parameter, are not changes.
+func isChange(method string, path string) bool {
+	return method != http.MethodGet && !strings.Contains(path, "dry_run=true")
+}
+
+func (c *Client) logDryRun(method string, path string, requestBody interface{}) {
+	c.log.Info("dry run, request not sent", "method", method, "path", path, "body", redact(requestBody))
+}
+
+// redact returns the request body as JSON with the values of the redacted fields replaced.
+func redact(requestBody interface{}) string {
+	if requestBody == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(requestBody)
+	if err != nil {
+		return "[unable to encode body]"
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "[unable to encode body]"
+	}
+
+	data, err = json.Marshal(redactValue(value))
+	if err != nil {
+		return "[unable to encode body]"
+	}
+	return string(data)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedFields[key] {
+				v[key] = "[redacted]"
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = redactValue(element)
+		}
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+)
+
+func TestClient_SetDryRun(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	role := cluster.AddRole("role", "db_member")
+	dbUID := cluster.AddDatabase("db", fake.RolePermission{RoleUID: role, ACLUID: acl})
+
+	var logs bytes.Buffer
+	subject.log = hclog.New(&hclog.LoggerOptions{Output: &logs, Level: hclog.Info})
+	subject.SetDryRun(true)
+
+	ctx := context.Background()
+
+	created, err := subject.CreateUser(ctx, CreateUser{Name: "dry", Password: "Secret123", Roles: []int{role}, AuthMethod: "regular"})
+	require.NoError(t, err)
+	assert.Equal(t, User{}, created)
+
+	_, _, err = subject.UpdateDatabaseRolePermissions(ctx, dbUID, func(permissions []RolePermission) []RolePermission {
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, subject.DeleteRole(ctx, role))
+
+	// Reads are still made
+	_, err = subject.FindRoleByName(ctx, "role")
+	require.NoError(t, err)
+
+	assert.Len(t, cluster.Users(), 1)
+	assert.Len(t, cluster.Roles(), 1)
+	db, _ := cluster.DatabaseByName("db")
+	assert.Len(t, db.RolePermissions, 1)
+
+	assert.Contains(t, logs.String(), "dry run, request not sent: method=POST path=/v1/users")
+	assert.Contains(t, logs.String(), "[redacted]")
+	assert.NotContains(t, logs.String(), "Secret123")
+	assert.Contains(t, logs.String(), "method=PUT path=/v1/bdbs/")
+	assert.Contains(t, logs.String(), "method=DELETE path=/v1/roles/")
+
+	subject.SetDryRun(false)
+	_, err = subject.CreateUser(ctx, CreateUser{Name: "wet", Password: "Secret123", Roles: []int{role}, AuthMethod: "regular"})
+	require.NoError(t, err)
+	assert.Len(t, cluster.Users(), 2)
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "", redact(nil))
+	assert.Equal(t, `{"name":"user","nested":[{"password":"[redacted]"}],"password":"[redacted]"}`,
+		redact(map[string]interface{}{"name": "user", "password": "secret", "nested": []interface{}{map[string]string{"password": "secret"}}}))
+}
@@ -0,0 +1,66 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// IsNotFound returns true if the error is because something looked up does not exist in the cluster.
+func IsNotFound(err error) bool {
+	return errors.Is(err, &UserNotFoundError{}) ||
+		errors.Is(err, &RoleNotFoundError{}) ||
+		errors.Is(err, &ACLNotFoundError{}) ||
+		errors.Is(err, &DatabaseNotFoundError{}) ||
+		hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorised returns true if the error is because the cluster rejected the credentials of the client, or the user
+// does not have permission to make the request.
+func IsUnauthorised(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized, http.StatusForbidden)
+}
+
+// IsConflict returns true if the error is because the request conflicts with the state of the cluster, such as an
+// object with the same name already existing or a database being busy with another action. Whether the request can be
+// retried depends on the request, so conflicts are not reported by IsRetryable.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsRetryable returns true if the error is likely to be temporary, such as the cluster being unreachable or
+// overloaded, so the same request may succeed if retried later.
+func IsRetryable(err error) bool {
+	if hasStatus(err, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout) {
+		return true
+	}
+
+	// The caller giving up is not something that will change by trying again
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsValidation returns true if the error is because the cluster rejected the content of the request as invalid.
+func IsValidation(err error) bool {
+	return hasStatus(err, http.StatusBadRequest, http.StatusUnprocessableEntity)
+}
+
+// hasStatus returns true if the error is a response from the cluster with one of the statuses.
+func hasStatus(err error, statuses ...int) bool {
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+
+	for _, status := range statuses {
+		if httpErr.status == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClassification(t *testing.T) {
+	for _, spec := range []struct {
+		name         string
+		err          error
+		notFound     bool
+		unauthorised bool
+		conflict     bool
+		retryable    bool
+		validation   bool
+	}{
+		{name: "user not found", err: &UserNotFoundError{"user"}, notFound: true},
+		{name: "role not found", err: &RoleNotFoundError{"role"}, notFound: true},
+		{name: "acl not found", err: &ACLNotFoundError{"acl"}, notFound: true},
+		{name: "database not found", err: &DatabaseNotFoundError{"db"}, notFound: true},
+		{name: "wrapped not found", err: fmt.Errorf("lookup: %w", &ACLNotFoundError{"acl"}), notFound: true},
+		{name: "404", err: &HttpError{status: http.StatusNotFound}, notFound: true},
+		{name: "401", err: &HttpError{status: http.StatusUnauthorized}, unauthorised: true},
+		{name: "403", err: &HttpError{status: http.StatusForbidden}, unauthorised: true},
+		{name: "409", err: &HttpError{status: http.StatusConflict}, conflict: true},
+		{name: "429", err: &HttpError{status: http.StatusTooManyRequests}, retryable: true},
+		{name: "503", err: &HttpError{status: http.StatusServiceUnavailable}, retryable: true},
+		{name: "deadline exceeded", err: fmt.Errorf("request: %w", context.DeadlineExceeded), retryable: true},
+		{name: "cancelled", err: fmt.Errorf("request: %w", context.Canceled)},
+		{name: "400", err: &HttpError{status: http.StatusBadRequest}, validation: true},
+		{name: "500", err: &HttpError{status: http.StatusInternalServerError}},
+		{name: "other", err: errors.New("other")},
+	} {
+		t.Run(spec.name, func(t *testing.T) {
+			assert.Equal(t, spec.notFound, IsNotFound(spec.err), "IsNotFound")
+			assert.Equal(t, spec.unauthorised, IsUnauthorised(spec.err), "IsUnauthorised")
+			assert.Equal(t, spec.conflict, IsConflict(spec.err), "IsConflict")
+			assert.Equal(t, spec.retryable, IsRetryable(spec.err), "IsRetryable")
+			assert.Equal(t, spec.validation, IsValidation(spec.err), "IsValidation")
+		})
+	}
+}
+
+func TestClient_FindACLByName_notFound(t *testing.T) {
+	_, subject := fakeCluster(t)
+
+	_, err := subject.FindACLByName(context.Background(), "missing")
+	assert.ErrorIs(t, err, &ACLNotFoundError{"missing"})
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClient_FindDatabaseByName_notFound(t *testing.T) {
+	_, subject := fakeCluster(t)
+
+	_, err := subject.FindDatabaseByName(context.Background(), "missing")
+	assert.ErrorIs(t, err, &DatabaseNotFoundError{"missing"})
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClient_request_unauthorised(t *testing.T) {
+	_, subject := fakeCluster(t)
+	subject.password = "wrong"
+
+	_, err := subject.GetCluster(context.Background())
+	assert.True(t, IsUnauthorised(err))
+	assert.False(t, IsNotFound(err))
+}
+
+func TestClient_request_unreachableIsRetryable(t *testing.T) {
+	subject := NewClient(hclog.NewNullLogger())
+	subject.Initialise("https://127.0.0.1:1", "user", "password")
+
+	_, err := subject.GetCluster(context.Background())
+	assert.True(t, IsRetryable(err))
+	assert.False(t, IsNotFound(err))
+}
@@ -0,0 +1,203 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type CRDB struct {
+	GUID            string         `json:"guid"`
+	Name            string         `json:"name"`
+	Instances       []CRDBInstance `json:"instances"`
+	DefaultDBConfig CRDBConfig     `json:"default_db_config"`
+
+	// busyUntil is when the task started by the last update completes
+	busyUntil time.Time
+}
+
+type CRDBInstance struct {
+	ID      int         `json:"id"`
+	Cluster CRDBCluster `json:"cluster"`
+}
+
+type CRDBCluster struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type CRDBConfig struct {
+	RolePermissions []CRDBRolePermission `json:"roles_permissions"`
+}
+
+type CRDBRolePermission struct {
+	Role string `json:"role_name"`
+	ACL  string `json:"redis_acl_name"`
+}
+
+type crdbTask struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	finishAt time.Time
+}
+
+// AddCRDB creates an Active-Active database with an instance in this cluster and in each of the other participating
+// clusters, returning its GUID and the UID of the local instance. Only the local instance is served by this cluster.
+func (c *Cluster) AddCRDB(name string, participants ...string) (string, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	guid := randomID()
+	crdb := &CRDB{
+		GUID:      guid,
+		Name:      name,
+		Instances: []CRDBInstance{{ID: 1, Cluster: CRDBCluster{Name: c.name, URL: c.serverURL()}}},
+	}
+	for i, participant := range participants {
+		crdb.Instances = append(crdb.Instances, CRDBInstance{
+			ID:      i + 2,
+			Cluster: CRDBCluster{Name: participant, URL: fmt.Sprintf("https://%s:9443", participant)},
+		})
+	}
+	c.crdbs[guid] = crdb
+
+	uid := c.uid()
+	c.databases[uid] = &Database{UID: uid, Name: name, CRDT: true, CRDTGUID: guid}
+
+	return guid, uid
+}
+
+// CRDB returns a copy of the Active-Active database, or false if it does not exist.
+func (c *Cluster) CRDB(guid string) (CRDB, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	crdb, ok := c.crdbs[guid]
+	if !ok {
+		return CRDB{}, false
+	}
+	copied := *crdb
+	copied.Instances = append([]CRDBInstance(nil), crdb.Instances...)
+	copied.DefaultDBConfig.RolePermissions = append([]CRDBRolePermission(nil), crdb.DefaultDBConfig.RolePermissions...)
+	return copied, true
+}
+
+// serverURL returns the URL the cluster is served on, or an empty string if it has not been started.
+func (c *Cluster) serverURL() string {
+	if c.server == nil {
+		return ""
+	}
+	return c.server.URL
+}
+
+func (c *Cluster) getCRDB(w http.ResponseWriter, r *http.Request) {
+	crdb, ok := c.crdbs[r.PathValue("guid")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "crdb_not_found", "crdb does not exist")
+		return
+	}
+	writeJSON(w, crdb)
+}
+
+type updateCRDB struct {
+	DefaultDBConfig *CRDBConfig `json:"default_db_config"`
+}
+
+func (c *Cluster) updateCRDB(w http.ResponseWriter, r *http.Request) {
+	crdb, ok := c.crdbs[r.PathValue("guid")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "crdb_not_found", "crdb does not exist")
+		return
+	}
+
+	if time.Now().Before(crdb.busyUntil) {
+		writeError(w, http.StatusConflict, "crdb_busy", "crdb is currently busy with another task")
+		return
+	}
+
+	var body updateCRDB
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.DefaultDBConfig != nil {
+		// Only the local instance can be checked, the other clusters are assumed to have the same roles and ACLs
+		permissions, ok := c.resolveCRDBRolePermissions(w, body.DefaultDBConfig.RolePermissions)
+		if !ok {
+			return
+		}
+
+		crdb.DefaultDBConfig.RolePermissions = body.DefaultDBConfig.RolePermissions
+		for _, db := range c.databases {
+			if db.CRDTGUID == crdb.GUID {
+				db.RolePermissions = permissions
+			}
+		}
+	}
+
+	crdb.busyUntil = time.Now().Add(c.updateDuration)
+	task := &crdbTask{ID: randomID(), Status: "queued", finishAt: crdb.busyUntil}
+	c.crdbTasks[task.ID] = task
+
+	writeJSON(w, task)
+}
+
+// resolveCRDBRolePermissions converts the roles_permissions of an Active-Active database, which refer to roles and
+// ACLs by name, to those of the local instance. The caller must hold the lock.
+func (c *Cluster) resolveCRDBRolePermissions(w http.ResponseWriter, permissions []CRDBRolePermission) ([]RolePermission, bool) {
+	resolved := []RolePermission{}
+	bound := map[string]bool{}
+	for _, permission := range permissions {
+		role, ok := c.roleByName(permission.Role)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("role %s does not exist", permission.Role))
+			return nil, false
+		}
+		acl, ok := c.aclByName(permission.ACL)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("redis acl %s does not exist", permission.ACL))
+			return nil, false
+		}
+		if bound[permission.Role] {
+			writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("role %s is bound more than once", permission.Role))
+			return nil, false
+		}
+		bound[permission.Role] = true
+
+		resolved = append(resolved, RolePermission{RoleUID: role.UID, ACLUID: acl.UID})
+	}
+	return resolved, true
+}
+
+func (c *Cluster) getCRDBTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := c.crdbTasks[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "task_not_found", "task does not exist")
+		return
+	}
+
+	if !time.Now().Before(task.finishAt) {
+		task.Status = "finished"
+	}
+	writeJSON(w, task)
+}
+
+// roleByName returns the role with the name. The caller must hold the lock.
+func (c *Cluster) roleByName(name string) (*Role, bool) {
+	for _, role := range c.roles {
+		if role.Name == name {
+			return role, true
+		}
+	}
+	return nil, false
+}
+
+// aclByName returns the ACL with the name. The caller must hold the lock.
+func (c *Cluster) aclByName(name string) (*ACL, bool) {
+	for _, acl := range c.acls {
+		if acl.Name == name {
+			return acl, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,958 @@
+// Package fake provides an in-memory Redis Enterprise cluster serving the parts of the REST API used by the plugin, so
+// the plugin and the sdk can be tested end to end without a real cluster.
+//
+// The cluster behaves the way the Redis Enterprise API documents:
+//   - names of users and roles must be unique
+//   - users with the certificate auth method have a certificate subject line instead of a password
+//   - deleting a role also removes its bindings from the roles_permissions of every database
+//   - names of Redis ACLs must be unique, and an ACL cannot be deleted while a database binds a role to it
+//   - roles_permissions may only refer to roles and ACLs which exist, and a role may only be bound once
+//   - updating a database starts an action, and further updates are rejected with a 409 until it completes
+//   - changes to an Active-Active database are applied to its instances by a task, and conflict until it completes
+//   - API tokens are only issued by clusters running 6.2.4 or later
+//
+// Tests can also inject latency, errors and conflicts.
+package fake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// The format of the password_issue_date of a user.
+const passwordIssueDateFormat = "2006-01-02T15:04:05Z"
+
+type User struct {
+	UID               int    `json:"uid"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Role              string `json:"role"`
+	Roles             []int  `json:"role_uids"`
+	AuthMethod        string `json:"auth_method"`
+	EmailAlerts       bool   `json:"email_alerts"`
+	PasswordIssueDate string `json:"password_issue_date"`
+
+	CertificateSubjectLine string `json:"certificate_subject_line,omitempty"`
+
+	Password string `json:"-"`
+}
+
+type Role struct {
+	UID        int    `json:"uid"`
+	Name       string `json:"name"`
+	Management string `json:"management"`
+}
+
+type ACL struct {
+	UID  int    `json:"uid"`
+	Name string `json:"name"`
+	ACL  string `json:"acl"`
+}
+
+type RolePermission struct {
+	RoleUID int `json:"role_uid"`
+	ACLUID  int `json:"redis_acl_uid"`
+}
+
+// Subject is one of the authorized_subjects of a database.
+type Subject struct {
+	CN string   `json:"CN"`
+	OU []string `json:"OU,omitempty"`
+	O  string   `json:"O,omitempty"`
+	L  string   `json:"L,omitempty"`
+	ST string   `json:"ST,omitempty"`
+	C  string   `json:"C,omitempty"`
+}
+
+type Database struct {
+	UID                int              `json:"uid"`
+	Name               string           `json:"name"`
+	RolePermissions    []RolePermission `json:"roles_permissions"`
+	CRDT               bool             `json:"crdt"`
+	CRDTGUID           string           `json:"crdt_guid,omitempty"`
+	AuthorizedSubjects []Subject        `json:"authorized_subjects,omitempty"`
+
+	// busyUntil is when the action started by the last update completes
+	busyUntil time.Time
+}
+
+// fault is an error injected for the next requests matching the method and path.
+type fault struct {
+	method    string
+	path      string
+	status    int
+	remaining int
+}
+
+// DefaultVersion is the version of Redis Enterprise the cluster reports unless changed with SetVersion.
+const DefaultVersion = "7.2.4-92"
+
+type Node struct {
+	UID             int    `json:"uid"`
+	SoftwareVersion string `json:"software_version"`
+}
+
+// Cluster is an in-memory Redis Enterprise cluster. The zero value is not usable, use NewCluster.
+type Cluster struct {
+	name    string
+	version string
+
+	lock      sync.Mutex
+	nextUID   int
+	users     map[int]*User
+	roles     map[int]*Role
+	acls      map[int]*ACL
+	databases map[int]*Database
+	tokens    map[string]token
+	crdbs     map[string]*CRDB
+	crdbTasks map[string]*crdbTask
+
+	latency        time.Duration
+	updateDuration time.Duration
+	faults         []*fault
+
+	server *httptest.Server
+}
+
+type token struct {
+	userUID int
+	expires time.Time
+}
+
+// NewCluster creates an empty cluster with the given name. Start must be called before it can be used.
+func NewCluster(name string) *Cluster {
+	return &Cluster{
+		name:      name,
+		version:   DefaultVersion,
+		nextUID:   1,
+		users:     map[int]*User{},
+		roles:     map[int]*Role{},
+		acls:      map[int]*ACL{},
+		databases: map[int]*Database{},
+		tokens:    map[string]token{},
+		crdbs:     map[string]*CRDB{},
+		crdbTasks: map[string]*crdbTask{},
+	}
+}
+
+// Start serves the cluster API over TLS on a local port and returns its URL.
+func (c *Cluster) Start() string {
+	c.server = httptest.NewTLSServer(c.handler())
+	return c.server.URL
+}
+
+// Close stops serving the cluster API.
+func (c *Cluster) Close() {
+	if c.server != nil {
+		c.server.Close()
+	}
+}
+
+// SetLatency delays every request by the duration.
+func (c *Cluster) SetLatency(latency time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.latency = latency
+}
+
+// SetVersion sets the version of Redis Enterprise the nodes of the cluster report, e.g. 6.2.10-100.
+func (c *Cluster) SetVersion(version string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.version = version
+}
+
+// SetUpdateDuration sets how long the action started by a database update takes to complete. Any update to the same
+// database before it completes is rejected with a 409.
+func (c *Cluster) SetUpdateDuration(duration time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.updateDuration = duration
+}
+
+// InjectError causes the next count requests with the method and path, e.g. PUT /v1/bdbs/1, to fail with the status.
+func (c *Cluster) InjectError(method string, path string, status int, count int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.faults = append(c.faults, &fault{method: method, path: path, status: status, remaining: count})
+}
+
+// InjectConflicts causes the next count updates of the database to fail with a 409.
+func (c *Cluster) InjectConflicts(databaseUID int, count int) {
+	c.InjectError(http.MethodPut, fmt.Sprintf("/v1/bdbs/%d", databaseUID), http.StatusConflict, count)
+}
+
+// AddUser creates a user which can authenticate against the cluster API, returning its UID.
+func (c *Cluster) AddUser(name string, email string, password string, management string, roleUIDs ...int) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	uid := c.uid()
+	c.users[uid] = &User{
+		UID:               uid,
+		Name:              name,
+		Email:             email,
+		Role:              management,
+		Roles:             roleUIDs,
+		AuthMethod:        "regular",
+		PasswordIssueDate: time.Now().UTC().Format(passwordIssueDateFormat),
+		Password:          password,
+	}
+	return uid
+}
+
+// AddRole creates a role, returning its UID.
+func (c *Cluster) AddRole(name string, management string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	uid := c.uid()
+	c.roles[uid] = &Role{UID: uid, Name: name, Management: management}
+	return uid
+}
+
+// AddACL creates a Redis ACL, returning its UID.
+func (c *Cluster) AddACL(name string, acl string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	uid := c.uid()
+	c.acls[uid] = &ACL{UID: uid, Name: name, ACL: acl}
+	return uid
+}
+
+// AddDatabase creates a database with the role permissions, returning its UID.
+func (c *Cluster) AddDatabase(name string, permissions ...RolePermission) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	uid := c.uid()
+	c.databases[uid] = &Database{UID: uid, Name: name, RolePermissions: permissions}
+	return uid
+}
+
+// Users returns a copy of all the users.
+func (c *Cluster) Users() []User {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var users []User
+	for _, uid := range sortedKeys(c.users) {
+		users = append(users, *c.users[uid])
+	}
+	return users
+}
+
+// Roles returns a copy of all the roles.
+func (c *Cluster) Roles() []Role {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var roles []Role
+	for _, uid := range sortedKeys(c.roles) {
+		roles = append(roles, *c.roles[uid])
+	}
+	return roles
+}
+
+// ACLs returns a copy of all the Redis ACLs.
+func (c *Cluster) ACLs() []ACL {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var acls []ACL
+	for _, uid := range sortedKeys(c.acls) {
+		acls = append(acls, *c.acls[uid])
+	}
+	return acls
+}
+
+// Database returns a copy of the database, or false if it does not exist.
+func (c *Cluster) Database(uid int) (Database, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	db, ok := c.databases[uid]
+	if !ok {
+		return Database{}, false
+	}
+	copied := *db
+	copied.RolePermissions = append([]RolePermission(nil), db.RolePermissions...)
+	copied.AuthorizedSubjects = append([]Subject(nil), db.AuthorizedSubjects...)
+	return copied, true
+}
+
+// DatabaseByName returns a copy of the database with the name, or false if it does not exist.
+func (c *Cluster) DatabaseByName(name string) (Database, bool) {
+	c.lock.Lock()
+	uid := -1
+	for _, db := range c.databases {
+		if db.Name == name {
+			uid = db.UID
+		}
+	}
+	c.lock.Unlock()
+
+	return c.Database(uid)
+}
+
+// uid allocates a new UID. The caller must hold the lock.
+func (c *Cluster) uid() int {
+	uid := c.nextUID
+	c.nextUID++
+	return uid
+}
+
+func (c *Cluster) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /v1/users/authorize", c.authorize)
+	mux.HandleFunc("POST /v1/users/refresh_jwt", c.authenticated(c.refreshToken))
+
+	mux.HandleFunc("GET /v1/cluster", c.authenticated(c.getCluster))
+	mux.HandleFunc("GET /v1/nodes", c.authenticated(c.listNodes))
+
+	mux.HandleFunc("GET /v1/users", c.authenticated(c.listUsers))
+	mux.HandleFunc("POST /v1/users", c.authenticated(c.createUser))
+	mux.HandleFunc("GET /v1/users/{uid}", c.authenticated(c.getUser))
+	mux.HandleFunc("PUT /v1/users/{uid}", c.authenticated(c.updateUser))
+	mux.HandleFunc("DELETE /v1/users/{uid}", c.authenticated(c.deleteUser))
+
+	mux.HandleFunc("GET /v1/roles", c.authenticated(c.listRoles))
+	mux.HandleFunc("POST /v1/roles", c.authenticated(c.createRole))
+	mux.HandleFunc("GET /v1/roles/{uid}", c.authenticated(c.getRole))
+	mux.HandleFunc("PUT /v1/roles/{uid}", c.authenticated(c.updateRole))
+	mux.HandleFunc("DELETE /v1/roles/{uid}", c.authenticated(c.deleteRole))
+
+	mux.HandleFunc("GET /v1/redis_acls", c.authenticated(c.listACLs))
+	mux.HandleFunc("POST /v1/redis_acls", c.authenticated(c.createACL))
+	mux.HandleFunc("GET /v1/redis_acls/{uid}", c.authenticated(c.getACL))
+	mux.HandleFunc("PUT /v1/redis_acls/{uid}", c.authenticated(c.updateACL))
+	mux.HandleFunc("DELETE /v1/redis_acls/{uid}", c.authenticated(c.deleteACL))
+
+	mux.HandleFunc("GET /v1/bdbs", c.authenticated(c.listDatabases))
+	mux.HandleFunc("GET /v1/bdbs/{uid}", c.authenticated(c.getDatabase))
+	mux.HandleFunc("PUT /v1/bdbs/{uid}", c.authenticated(c.updateDatabase))
+
+	mux.HandleFunc("GET /v1/crdbs/{guid}", c.authenticated(c.getCRDB))
+	mux.HandleFunc("PATCH /v1/crdbs/{guid}", c.authenticated(c.updateCRDB))
+	mux.HandleFunc("GET /v1/crdb_tasks/{id}", c.authenticated(c.getCRDBTask))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.lock.Lock()
+		latency := c.latency
+		status := c.injectedFault(r)
+		c.lock.Unlock()
+
+		time.Sleep(latency)
+
+		if status != 0 {
+			writeError(w, status, "injected", "injected error")
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// injectedFault returns the status of an error injected for the request, or zero. The caller must hold the lock.
+func (c *Cluster) injectedFault(r *http.Request) int {
+	for i, f := range c.faults {
+		if f.method == r.Method && f.path == r.URL.Path {
+			f.remaining--
+			if f.remaining <= 0 {
+				c.faults = append(c.faults[:i], c.faults[i+1:]...)
+			}
+			return f.status
+		}
+	}
+	return 0
+}
+
+// authenticated only calls the handler when the request has valid credentials, either basic authentication or a
+// token issued by /v1/users/authorize. The handler is called with the lock held.
+func (c *Cluster) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if _, ok := c.authenticate(r); !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "authentication failed")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// authenticate returns the user making the request. The caller must hold the lock.
+func (c *Cluster) authenticate(r *http.Request) (*User, bool) {
+	if value := r.Header.Get("Authorization"); strings.HasPrefix(value, "JWT ") {
+		t, ok := c.tokens[strings.TrimPrefix(value, "JWT ")]
+		if !ok || time.Now().After(t.expires) {
+			return nil, false
+		}
+		user, ok := c.users[t.userUID]
+		return user, ok
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, false
+	}
+	return c.login(username, password)
+}
+
+// login finds the user with the name or email and password. The caller must hold the lock.
+func (c *Cluster) login(username string, password string) (*User, bool) {
+	for _, user := range c.users {
+		if (user.Name == username || user.Email == username) && user.Password != "" && user.Password == password {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+type authorizeRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TTL      int    `json:"ttl"`
+}
+
+func (c *Cluster) authorize(w http.ResponseWriter, r *http.Request) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.supportsTokens() {
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+
+	var body authorizeRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	user, ok := c.login(body.Username, body.Password)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "authentication failed")
+		return
+	}
+
+	c.issueToken(w, user.UID, body.TTL)
+}
+
+func (c *Cluster) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var body authorizeRequest
+	if !decode(w, r, &body) {
+		return
+	}
+
+	user, _ := c.authenticate(r)
+	delete(c.tokens, strings.TrimPrefix(r.Header.Get("Authorization"), "JWT "))
+	c.issueToken(w, user.UID, body.TTL)
+}
+
+// tokenVersion is the version of Redis Enterprise which introduced API tokens.
+var tokenVersion = version.Must(version.NewVersion("6.2.4"))
+
+// supportsTokens returns true if the version of the cluster issues API tokens. The build number is ignored. The caller
+// must hold the lock.
+func (c *Cluster) supportsTokens() bool {
+	release, _, _ := strings.Cut(c.version, "-")
+	v, err := version.NewVersion(release)
+	return err == nil && v.GreaterThanOrEqual(tokenVersion)
+}
+
+// issueToken creates a token for the user. The caller must hold the lock.
+func (c *Cluster) issueToken(w http.ResponseWriter, userUID int, ttl int) {
+	if ttl <= 0 {
+		ttl = 300
+	}
+
+	accessToken := randomID()
+	c.tokens[accessToken] = token{userUID: userUID, expires: time.Now().Add(time.Duration(ttl) * time.Second)}
+
+	writeJSON(w, map[string]string{"access_token": accessToken})
+}
+
+func (c *Cluster) getCluster(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]string{"name": c.name})
+}
+
+func (c *Cluster) listNodes(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, []Node{{UID: 1, SoftwareVersion: c.version}})
+}
+
+func (c *Cluster) listUsers(w http.ResponseWriter, _ *http.Request) {
+	users := []*User{}
+	for _, uid := range sortedKeys(c.users) {
+		users = append(users, c.users[uid])
+	}
+	writeJSON(w, users)
+}
+
+func (c *Cluster) getUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.users[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "user does not exist")
+		return
+	}
+	writeJSON(w, user)
+}
+
+type createUser struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	Role        string `json:"role"`
+	Roles       []int  `json:"role_uids"`
+	EmailAlerts bool   `json:"email_alerts"`
+	AuthMethod  string `json:"auth_method"`
+
+	CertificateSubjectLine string `json:"certificate_subject_line"`
+}
+
+func (c *Cluster) createUser(w http.ResponseWriter, r *http.Request) {
+	var body createUser
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" && body.Email == "" {
+		writeError(w, http.StatusBadRequest, "invalid_schema", "name or email is required")
+		return
+	}
+	if body.AuthMethod == "" {
+		body.AuthMethod = "regular"
+	}
+	switch body.AuthMethod {
+	case "regular":
+		if body.Password == "" {
+			writeError(w, http.StatusBadRequest, "invalid_schema", "password is required")
+			return
+		}
+	case "certificate":
+		if body.CertificateSubjectLine == "" {
+			writeError(w, http.StatusBadRequest, "invalid_schema", "certificate_subject_line is required")
+			return
+		}
+		if body.Password != "" {
+			writeError(w, http.StatusBadRequest, "invalid_schema", "password cannot be set for a user with certificate authentication")
+			return
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_schema", fmt.Sprintf("unknown auth_method '%s'", body.AuthMethod))
+		return
+	}
+	for _, user := range c.users {
+		if (body.Name != "" && user.Name == body.Name) || (body.Email != "" && user.Email == body.Email) {
+			writeError(w, http.StatusConflict, "user_already_exists", "a user with the same name or email already exists")
+			return
+		}
+	}
+	for _, roleUID := range body.Roles {
+		if _, ok := c.roles[roleUID]; !ok {
+			writeError(w, http.StatusBadRequest, "role_not_found", fmt.Sprintf("role %d does not exist", roleUID))
+			return
+		}
+	}
+
+	uid := c.uid()
+	user := &User{
+		UID:               uid,
+		Name:              body.Name,
+		Email:             body.Email,
+		Role:              body.Role,
+		Roles:             body.Roles,
+		AuthMethod:        body.AuthMethod,
+		EmailAlerts:       body.EmailAlerts,
+		PasswordIssueDate: time.Now().UTC().Format(passwordIssueDateFormat),
+		Password:          body.Password,
+
+		CertificateSubjectLine: body.CertificateSubjectLine,
+	}
+	c.users[uid] = user
+
+	writeJSON(w, user)
+}
+
+type updateUser struct {
+	Name     *string `json:"name"`
+	Password *string `json:"password"`
+	Roles    *[]int  `json:"role_uids"`
+}
+
+func (c *Cluster) updateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := c.users[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "user does not exist")
+		return
+	}
+
+	var body updateUser
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Password != nil && user.AuthMethod == "certificate" {
+		writeError(w, http.StatusBadRequest, "invalid_schema", "password cannot be set for a user with certificate authentication")
+		return
+	}
+	if body.Name != nil {
+		for _, other := range c.users {
+			if other.UID != user.UID && other.Name == *body.Name {
+				writeError(w, http.StatusConflict, "user_already_exists", "a user with the same name already exists")
+				return
+			}
+		}
+	}
+
+	if body.Roles != nil {
+		for _, roleUID := range *body.Roles {
+			if _, ok := c.roles[roleUID]; !ok {
+				writeError(w, http.StatusBadRequest, "role_not_found", fmt.Sprintf("role %d does not exist", roleUID))
+				return
+			}
+		}
+		user.Roles = *body.Roles
+	}
+	if body.Name != nil {
+		user.Name = *body.Name
+	}
+	if body.Password != nil {
+		user.Password = *body.Password
+		user.PasswordIssueDate = time.Now().UTC().Format(passwordIssueDateFormat)
+	}
+
+	writeJSON(w, user)
+}
+
+func (c *Cluster) deleteUser(w http.ResponseWriter, r *http.Request) {
+	uid := pathUID(r)
+	if _, ok := c.users[uid]; !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "user does not exist")
+		return
+	}
+
+	delete(c.users, uid)
+	for value, t := range c.tokens {
+		if t.userUID == uid {
+			delete(c.tokens, value)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Cluster) listRoles(w http.ResponseWriter, _ *http.Request) {
+	roles := []*Role{}
+	for _, uid := range sortedKeys(c.roles) {
+		roles = append(roles, c.roles[uid])
+	}
+	writeJSON(w, roles)
+}
+
+func (c *Cluster) getRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := c.roles[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "role_not_found", "role does not exist")
+		return
+	}
+	writeJSON(w, role)
+}
+
+func (c *Cluster) createRole(w http.ResponseWriter, r *http.Request) {
+	var body Role
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" || body.Management == "" {
+		writeError(w, http.StatusBadRequest, "invalid_schema", "name and management are required")
+		return
+	}
+	for _, role := range c.roles {
+		if role.Name == body.Name {
+			writeError(w, http.StatusConflict, "role_already_exists", "a role with the same name already exists")
+			return
+		}
+	}
+
+	uid := c.uid()
+	role := &Role{UID: uid, Name: body.Name, Management: body.Management}
+	c.roles[uid] = role
+
+	writeJSON(w, role)
+}
+
+func (c *Cluster) updateRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := c.roles[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "role_not_found", "role does not exist")
+		return
+	}
+
+	var body Role
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Management != "" {
+		role.Management = body.Management
+	}
+
+	writeJSON(w, role)
+}
+
+func (c *Cluster) deleteRole(w http.ResponseWriter, r *http.Request) {
+	uid := pathUID(r)
+	if _, ok := c.roles[uid]; !ok {
+		writeError(w, http.StatusNotFound, "role_not_found", "role does not exist")
+		return
+	}
+
+	delete(c.roles, uid)
+
+	// Deleting a role cascades to the bindings of every database and the users with the role
+	for _, db := range c.databases {
+		var permissions []RolePermission
+		changed := false
+		for _, permission := range db.RolePermissions {
+			if permission.RoleUID == uid {
+				changed = true
+				continue
+			}
+			permissions = append(permissions, permission)
+		}
+		if changed {
+			db.RolePermissions = permissions
+			db.busyUntil = time.Now().Add(c.updateDuration)
+		}
+	}
+	for _, user := range c.users {
+		var roles []int
+		for _, roleUID := range user.Roles {
+			if roleUID != uid {
+				roles = append(roles, roleUID)
+			}
+		}
+		user.Roles = roles
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Cluster) listACLs(w http.ResponseWriter, _ *http.Request) {
+	acls := []*ACL{}
+	for _, uid := range sortedKeys(c.acls) {
+		acls = append(acls, c.acls[uid])
+	}
+	writeJSON(w, acls)
+}
+
+func (c *Cluster) getACL(w http.ResponseWriter, r *http.Request) {
+	acl, ok := c.acls[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "redis_acl_not_found", "redis acl does not exist")
+		return
+	}
+	writeJSON(w, acl)
+}
+
+func (c *Cluster) createACL(w http.ResponseWriter, r *http.Request) {
+	var body ACL
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.Name == "" || body.ACL == "" {
+		writeError(w, http.StatusBadRequest, "invalid_schema", "name and acl are required")
+		return
+	}
+	for _, acl := range c.acls {
+		if acl.Name == body.Name {
+			writeError(w, http.StatusConflict, "redis_acl_already_exists", "a redis acl with the same name already exists")
+			return
+		}
+	}
+
+	uid := c.uid()
+	acl := &ACL{UID: uid, Name: body.Name, ACL: body.ACL}
+	c.acls[uid] = acl
+
+	writeJSON(w, acl)
+}
+
+func (c *Cluster) updateACL(w http.ResponseWriter, r *http.Request) {
+	acl, ok := c.acls[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "redis_acl_not_found", "redis acl does not exist")
+		return
+	}
+
+	var body ACL
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.ACL != "" {
+		acl.ACL = body.ACL
+	}
+
+	writeJSON(w, acl)
+}
+
+func (c *Cluster) deleteACL(w http.ResponseWriter, r *http.Request) {
+	uid := pathUID(r)
+	if _, ok := c.acls[uid]; !ok {
+		writeError(w, http.StatusNotFound, "redis_acl_not_found", "redis acl does not exist")
+		return
+	}
+	for _, db := range c.databases {
+		for _, permission := range db.RolePermissions {
+			if permission.ACLUID == uid {
+				writeError(w, http.StatusConflict, "redis_acl_in_use", fmt.Sprintf("redis acl is used by database %s", db.Name))
+				return
+			}
+		}
+	}
+
+	delete(c.acls, uid)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *Cluster) listDatabases(w http.ResponseWriter, _ *http.Request) {
+	dbs := []*Database{}
+	for _, uid := range sortedKeys(c.databases) {
+		dbs = append(dbs, c.databases[uid])
+	}
+	writeJSON(w, dbs)
+}
+
+func (c *Cluster) getDatabase(w http.ResponseWriter, r *http.Request) {
+	db, ok := c.databases[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "db_not_exist", "database does not exist")
+		return
+	}
+	writeJSON(w, db)
+}
+
+type updateDatabase struct {
+	RolePermissions    *[]RolePermission `json:"roles_permissions"`
+	AuthorizedSubjects *[]Subject        `json:"authorized_subjects"`
+}
+
+func (c *Cluster) updateDatabase(w http.ResponseWriter, r *http.Request) {
+	db, ok := c.databases[pathUID(r)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "db_not_exist", "database does not exist")
+		return
+	}
+
+	if time.Now().Before(db.busyUntil) {
+		writeError(w, http.StatusConflict, "db_busy", "database is currently busy with another action")
+		return
+	}
+
+	var body updateDatabase
+	if !decode(w, r, &body) {
+		return
+	}
+
+	if body.RolePermissions != nil {
+		bound := map[int]bool{}
+		for _, permission := range *body.RolePermissions {
+			if _, ok := c.roles[permission.RoleUID]; !ok {
+				writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("role %d does not exist", permission.RoleUID))
+				return
+			}
+			if _, ok := c.acls[permission.ACLUID]; !ok {
+				writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("redis acl %d does not exist", permission.ACLUID))
+				return
+			}
+			if bound[permission.RoleUID] {
+				writeError(w, http.StatusBadRequest, "invalid_role_permissions", fmt.Sprintf("role %d is bound more than once", permission.RoleUID))
+				return
+			}
+			bound[permission.RoleUID] = true
+		}
+	}
+
+	if body.AuthorizedSubjects != nil {
+		for _, subject := range *body.AuthorizedSubjects {
+			if subject.CN == "" {
+				writeError(w, http.StatusBadRequest, "invalid_schema", "the CN of an authorized subject is required")
+				return
+			}
+		}
+	}
+
+	// A dry run only validates the update
+	if _, ok := r.URL.Query()["dry_run"]; ok {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if body.RolePermissions != nil {
+		db.RolePermissions = *body.RolePermissions
+	}
+	if body.AuthorizedSubjects != nil {
+		db.AuthorizedSubjects = *body.AuthorizedSubjects
+	}
+	db.busyUntil = time.Now().Add(c.updateDuration)
+
+	writeJSON(w, db)
+}
+
+func pathUID(r *http.Request) int {
+	uid, err := strconv.Atoi(r.PathValue("uid"))
+	if err != nil {
+		return -1
+	}
+	return uid
+}
+
+func decode(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error_code": code, "description": description})
+}
+
+// randomID returns a random hex string, as used for tokens and GUIDs.
+func randomID() string {
+	value := make([]byte, 16)
+	_, _ = rand.Read(value)
+	return hex.EncodeToString(value)
+}
+
+// sortedKeys returns the UIDs in ascending order, so the cluster lists objects in the order they were created.
+func sortedKeys[T any](m map[int]T) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,49 @@
+package sdk
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// recordRequest emits the count and latency of a request against the cluster API. A status of zero means the request
+// failed before a response was received.
+func recordRequest(method string, path string, status int, start time.Time) {
+	statusLabel := "error"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+
+	labels := []metrics.Label{
+		{Name: "method", Value: method},
+		{Name: "path", Value: pathTemplate(path)},
+		{Name: "status", Value: statusLabel},
+	}
+
+	metrics.IncrCounterWithLabels([]string{"sdk", "request"}, 1, labels)
+	metrics.MeasureSinceWithLabels([]string{"sdk", "request", "duration"}, start, labels)
+}
+
+// pathTemplate replaces the identifiers in a request path with a placeholder so all the requests for the same endpoint
+// are recorded together, e.g. /v1/bdbs/3 becomes /v1/bdbs/:uid
+func pathTemplate(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = ":uid"
+		}
+
+		// Active-Active databases and their tasks are identified by GUIDs rather than UIDs
+		if i > 0 && (segments[i-1] == "crdbs" || segments[i-1] == "crdb_tasks") {
+			segments[i] = ":guid"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
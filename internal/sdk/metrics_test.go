@@ -0,0 +1,62 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplate(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/v1/bdbs":            "/v1/bdbs",
+		"/v1/bdbs/3":          "/v1/bdbs/:uid",
+		"/v1/users/12":        "/v1/users/:uid",
+		"/v1/bdbs/3?dry_run":  "/v1/bdbs/:uid",
+		"/v1/users/authorize": "/v1/users/authorize",
+		"/v1/crdbs/1a2b-3c4d": "/v1/crdbs/:guid",
+		"/v1/crdb_tasks/9f8e": "/v1/crdb_tasks/:guid",
+	} {
+		assert.Equal(t, expected, pathTemplate(path), path)
+	}
+}
+
+func TestClient_UpdateDatabaseRolePermissions_recordsMetrics(t *testing.T) {
+	sink := inmemSink(t)
+
+	cluster, subject := fakeCluster(t)
+	dbUID := cluster.AddDatabase("db")
+	cluster.InjectConflicts(dbUID, 1)
+
+	_, _, err := subject.UpdateDatabaseRolePermissions(context.TODO(), dbUID, func(permissions []RolePermission) []RolePermission {
+		return permissions
+	})
+	require.NoError(t, err)
+
+	counters := sink.Data()[0].Counters
+	assert.Equal(t, 1, counters["sdk.update_database.conflict"].Count)
+	assert.Equal(t, 2, counters["sdk.request;method=GET;path=/v1/bdbs/:uid;status=200"].Count)
+	assert.Equal(t, 1, counters["sdk.request;method=PUT;path=/v1/bdbs/:uid;status=409"].Count)
+	assert.Equal(t, 1, counters["sdk.request;method=PUT;path=/v1/bdbs/:uid;status=200"].Count)
+}
+
+// inmemSink replaces the global metrics with an in-memory sink for the duration of the test.
+func inmemSink(t *testing.T) *metrics.InmemSink {
+	t.Helper()
+
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	conf := metrics.DefaultConfig("")
+	conf.EnableHostname = false
+	conf.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(conf, sink)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig(""), &metrics.BlackholeSink{})
+	})
+
+	return sink
+}
@@ -5,12 +5,14 @@ import (
 )
 
 type User struct {
-	UID               int    `json:"uid"`
-	Role              string `json:"role"`
-	Roles             []int  `json:"role_uids,omitempty"`
-	Name              string `json:"name"`
-	Email             string `json:"email"`
-	PasswordIssueDate string `json:"password_issue_date"`
+	UID                    int    `json:"uid"`
+	Role                   string `json:"role"`
+	Roles                  []int  `json:"role_uids,omitempty"`
+	Name                   string `json:"name"`
+	Email                  string `json:"email"`
+	PasswordIssueDate      string `json:"password_issue_date"`
+	AuthMethod             string `json:"auth_method,omitempty"`
+	CertificateSubjectLine string `json:"certificate_subject_line,omitempty"`
 }
 
 type CreateUser struct {
@@ -20,12 +22,23 @@ type CreateUser struct {
 	Roles       []int  `json:"role_uids,omitempty"`
 	EmailAlerts bool   `json:"email_alerts"`
 	AuthMethod  string `json:"auth_method,omitempty"`
+
+	// CertificateSubjectLine is the subject of the client certificate a user with the certificate auth method
+	// authenticates with, in the form of RFC 2253
+	CertificateSubjectLine string `json:"certificate_subject_line,omitempty"`
 }
 
 type UpdateUser struct {
 	Password string `json:"password,omitempty"`
 }
 
+// RevokeUser renames a user, removes every role of the user and, when it is set, replaces the password of the user.
+type RevokeUser struct {
+	Name     string `json:"name"`
+	Roles    []int  `json:"role_uids"`
+	Password string `json:"password,omitempty"`
+}
+
 type Role struct {
 	UID        int    `json:"uid"`
 	Name       string `json:"name"`
@@ -37,10 +50,22 @@ type CreateRole struct {
 	Management string `json:"management"`
 }
 
+type UpdateRole struct {
+	Management string `json:"management"`
+}
+
 type Database struct {
 	UID             int              `json:"uid"`
 	Name            string           `json:"name"`
 	RolePermissions []RolePermission `json:"roles_permissions"`
+
+	// CRDT is set if the database is an instance of an Active-Active database, identified by CRDTGUID
+	CRDT     bool   `json:"crdt"`
+	CRDTGUID string `json:"crdt_guid,omitempty"`
+
+	// AuthorizedSubjects are the subjects of the client certificates the database accepts with TLS client
+	// authentication, when it validates the full subject
+	AuthorizedSubjects []Subject `json:"authorized_subjects,omitempty"`
 }
 
 type UpdateDatabase struct {
@@ -56,21 +81,85 @@ func (d Database) FindPermissionForRole(uid int) *RolePermission {
 	return nil
 }
 
+// Subject is the subject of a client certificate, with the attributes Redis Enterprise can validate.
+type Subject struct {
+	CN string   `json:"CN"`
+	OU []string `json:"OU,omitempty"`
+	O  string   `json:"O,omitempty"`
+	L  string   `json:"L,omitempty"`
+	ST string   `json:"ST,omitempty"`
+	C  string   `json:"C,omitempty"`
+}
+
 type RolePermission struct {
 	RoleUID int `json:"role_uid"`
 	ACLUID  int `json:"redis_acl_uid"`
 }
 
+// CRDB is an Active-Active database, with an instance in each of the participating clusters.
+type CRDB struct {
+	GUID            string         `json:"guid"`
+	Name            string         `json:"name"`
+	Instances       []CRDBInstance `json:"instances"`
+	DefaultDBConfig CRDBConfig     `json:"default_db_config"`
+}
+
+type CRDBInstance struct {
+	ID      int         `json:"id"`
+	Cluster CRDBCluster `json:"cluster"`
+}
+
+type CRDBCluster struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// CRDBConfig is the configuration applied to every instance of an Active-Active database. The UIDs of roles and ACLs
+// differ between clusters, so they are bound by name.
+type CRDBConfig struct {
+	RolePermissions []CRDBRolePermission `json:"roles_permissions"`
+}
+
+type CRDBRolePermission struct {
+	Role string `json:"role_name"`
+	ACL  string `json:"redis_acl_name"`
+}
+
+type UpdateCRDB struct {
+	DefaultDBConfig CRDBConfig `json:"default_db_config"`
+}
+
+// CRDBTask is the progress of a change to an Active-Active database being applied to its instances.
+type CRDBTask struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
 type Cluster struct {
 	Name string `json:"name"`
 }
 
+type Node struct {
+	UID             int    `json:"uid"`
+	SoftwareVersion string `json:"software_version"`
+}
+
 type ACL struct {
 	UID  int    `json:"uid"`
 	Name string `json:"name"`
 	ACL  string `json:"acl"`
 }
 
+type CreateACL struct {
+	Name string `json:"name"`
+	ACL  string `json:"acl"`
+}
+
+type UpdateACL struct {
+	ACL string `json:"acl"`
+}
+
 var _ error = &UserNotFoundError{}
 
 type UserNotFoundError struct {
@@ -109,6 +198,44 @@ func (u *RoleNotFoundError) Is(target error) bool {
 	return u.name == t.name || t.name == ""
 }
 
+var _ error = &ACLNotFoundError{}
+
+type ACLNotFoundError struct {
+	name string
+}
+
+func (a *ACLNotFoundError) Error() string {
+	return fmt.Sprintf("unable to find acl %s", a.name)
+}
+
+func (a *ACLNotFoundError) Is(target error) bool {
+	t, ok := target.(*ACLNotFoundError)
+	if !ok {
+		return false
+	}
+
+	return a.name == t.name || t.name == ""
+}
+
+var _ error = &DatabaseNotFoundError{}
+
+type DatabaseNotFoundError struct {
+	name string
+}
+
+func (d *DatabaseNotFoundError) Error() string {
+	return fmt.Sprintf("unable to find database %s", d.name)
+}
+
+func (d *DatabaseNotFoundError) Is(target error) bool {
+	t, ok := target.(*DatabaseNotFoundError)
+	if !ok {
+		return false
+	}
+
+	return d.name == t.name || t.name == ""
+}
+
 var _ error = &HttpError{}
 
 type HttpError struct {
@@ -133,3 +260,25 @@ func (h *HttpError) Is(target error) bool {
 		(h.body == t.body || t.body == "") &&
 		(h.status == t.status || t.status == 0)
 }
+
+// NewHttpError creates the error for a response from an API with an unexpected status, so that other implementations of
+// the API, such as Redis Cloud, report errors which are classified in the same way.
+func NewHttpError(method string, path string, status int, body string) *HttpError {
+	return &HttpError{method: method, path: path, status: status, body: body}
+}
+
+func NewUserNotFoundError(name string) *UserNotFoundError {
+	return &UserNotFoundError{name}
+}
+
+func NewRoleNotFoundError(name string) *RoleNotFoundError {
+	return &RoleNotFoundError{name}
+}
+
+func NewACLNotFoundError(name string) *ACLNotFoundError {
+	return &ACLNotFoundError{name}
+}
+
+func NewDatabaseNotFoundError(name string) *DatabaseNotFoundError {
+	return &DatabaseNotFoundError{name}
+}
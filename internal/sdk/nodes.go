@@ -0,0 +1,53 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+func (c *Client) ListNodes(ctx context.Context) ([]Node, error) {
+	var body []Node
+	if err := c.request(ctx, http.MethodGet, "/v1/nodes", nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// GetVersion returns the version of Redis Enterprise running on the cluster. While the cluster is being upgraded the
+// nodes run different versions, so the oldest is returned as only what it supports can be relied on.
+func (c *Client) GetVersion(ctx context.Context) (*version.Version, error) {
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest *version.Version
+	for _, node := range nodes {
+		v, err := parseSoftwareVersion(node.SoftwareVersion)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the software version of node %d: %w", node.UID, err)
+		}
+
+		if oldest == nil || v.LessThan(oldest) {
+			oldest = v
+		}
+	}
+
+	if oldest == nil {
+		return nil, errors.New("unable to find the software version of the cluster: no nodes")
+	}
+
+	return oldest, nil
+}
+
+// parseSoftwareVersion parses a version such as 6.2.10-100, where 100 is the build number rather than a pre-release.
+func parseSoftwareVersion(softwareVersion string) (*version.Version, error) {
+	release := strings.SplitN(softwareVersion, "-", 2)[0]
+	return version.NewVersion(release)
+}
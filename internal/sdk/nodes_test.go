@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetVersion(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	cluster.SetVersion("6.2.10-100")
+
+	v, err := subject.GetVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "6.2.10", v.String())
+}
+
+func TestClient_GetVersion_oldestNode(t *testing.T) {
+	url := testServer(t, "/v1/nodes", http.MethodGet, "user", "pass", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"uid": 1, "software_version": "7.2.4-92"}, {"uid": 2, "software_version": "6.4.2-61"}, {"uid": 3, "software_version": "7.2.4-92"}]`))
+	})
+
+	subject := &Client{url: url, username: "user", password: "pass", client: http.DefaultClient}
+
+	v, err := subject.GetVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "6.4.2", v.String())
+}
+
+func TestParseSoftwareVersion_buildNumberIsNotPrerelease(t *testing.T) {
+	build, err := parseSoftwareVersion("6.2.10-100")
+	require.NoError(t, err)
+	release, err := parseSoftwareVersion("6.2.10")
+	require.NoError(t, err)
+
+	assert.True(t, build.Equal(release))
+}
@@ -31,6 +31,13 @@ func (c *Client) CreateRole(ctx context.Context, create CreateRole) (Role, error
 	return body, nil
 }
 
+func (c *Client) UpdateRole(ctx context.Context, id int, update UpdateRole) error {
+	if err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/roles/%d", id), update, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *Client) DeleteRole(ctx context.Context, id int) error {
 	if err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/roles/%d", id), nil, nil); err != nil {
 		return err
@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk/fake"
+)
+
+func TestClient_DeleteRole_removesRolePermissions(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	acl := cluster.AddACL("acl", "+@all ~*")
+	other := cluster.AddRole("other", "db_member")
+	dbUID := cluster.AddDatabase("db", fake.RolePermission{RoleUID: other, ACLUID: acl})
+
+	ctx := context.Background()
+	role, err := subject.CreateRole(ctx, CreateRole{Name: "generated", Management: "db_member"})
+	require.NoError(t, err)
+
+	require.NoError(t, subject.UpdateDatabase(ctx, dbUID, UpdateDatabase{
+		RolePermissions: []RolePermission{
+			{RoleUID: other, ACLUID: acl},
+			{RoleUID: role.UID, ACLUID: acl},
+		},
+	}))
+
+	require.NoError(t, subject.DeleteRole(ctx, role.UID))
+
+	db, err := subject.FindDatabaseByName(ctx, "db")
+	require.NoError(t, err)
+	assert.Equal(t, []RolePermission{{RoleUID: other, ACLUID: acl}}, db.RolePermissions)
+
+	_, err = subject.FindRoleByName(ctx, "generated")
+	assert.ErrorIs(t, err, &RoleNotFoundError{})
+}
+
+func TestClient_CreateRole_rejectsDuplicateName(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	cluster.AddRole("existing", "db_member")
+
+	_, err := subject.CreateRole(context.Background(), CreateRole{Name: "existing", Management: "db_member"})
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusConflict})
+}
+
+// fakeCluster starts an in-memory cluster with an admin user and returns a client authenticated as that user.
+func fakeCluster(t *testing.T) (*fake.Cluster, *Client) {
+	t.Helper()
+
+	cluster := fake.NewCluster("test")
+	cluster.AddUser("admin", "admin@example.com", "Password", "admin")
+	url := cluster.Start()
+	t.Cleanup(cluster.Close)
+
+	client := NewClient(hclog.NewNullLogger())
+	client.Initialise(url, "admin@example.com", "Password")
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return cluster, client
+}
+
+func TestClient_UpdateRole(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	uid := cluster.AddRole("reader", "db_viewer")
+
+	require.NoError(t, subject.UpdateRole(context.Background(), uid, UpdateRole{Management: "db_member"}))
+
+	role, err := subject.GetRole(context.Background(), uid)
+	require.NoError(t, err)
+	assert.Equal(t, "db_member", role.Management)
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -21,28 +22,52 @@ type Client struct {
 	url      string
 	username string
 	password string
-	client   *http.Client
 	log      hclog.Logger
+
+	// lock guards client, which is replaced when the pool of connections is changed
+	lock   sync.RWMutex
+	client *http.Client
+
+	// token is only set when token authentication has been enabled, otherwise basic authentication is used
+	token *tokenAuth
+
+	// dryRun is set when the changes the client would make are logged rather than sent to the cluster
+	dryRun bool
 }
 
 // The timeout for the REST client requests.
 const timeout = 60
 
+// The default size of the pool of idle connections to the cluster API, and how long an idle connection is kept for.
+const (
+	defaultMaxIdleConns    = 100
+	defaultIdleConnTimeout = 90 * time.Second
+)
+
 func NewClient(log hclog.Logger) *Client {
 	return &Client{
-		client: &http.Client{
-			Timeout: timeout * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-
-				// Values copied from http.DefaultTransport
-				MaxIdleConns:          100,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
+		client: newHTTPClient(defaultMaxIdleConns, defaultIdleConnTimeout),
+		log:    log,
+	}
+}
+
+// newHTTPClient returns a client for the cluster API with its own pool of connections.
+func newHTTPClient(maxIdleConns int, idleConnTimeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+
+			// Values copied from http.DefaultTransport
+			MaxIdleConns:          maxIdleConns,
+			IdleConnTimeout:       idleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+
+			// All requests go to the same cluster API, so allow every idle connection to be kept for that host
+			// rather than the default of 2
+			MaxIdleConnsPerHost: maxIdleConns,
 		},
-		log: log,
 	}
 }
 
@@ -50,16 +75,70 @@ func (c *Client) Initialise(url string, username string, password string) {
 	c.url = strings.TrimSuffix(url, "/")
 	c.username = username
 	c.password = password
+
+	// Any token was exchanged for the previous credentials, so token authentication must be enabled again
+	c.token = nil
+}
+
+// SetConnectionPool sets the number of idle connections to the cluster API that are kept for reuse and how long they
+// are kept for, with a zero value using the default. The requests already being made finish with the previous pool,
+// whose idle connections are closed.
+func (c *Client) SetConnectionPool(maxIdleConns int, idleConnTimeout time.Duration) {
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+
+	c.lock.Lock()
+	previous := c.client
+	c.client = newHTTPClient(maxIdleConns, idleConnTimeout)
+	c.lock.Unlock()
+
+	if previous != nil {
+		previous.CloseIdleConnections()
+	}
+}
+
+// httpClient returns the client for the current pool of connections.
+func (c *Client) httpClient() *http.Client {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.client
 }
 
 func (c *Client) Close() error {
-	c.client.CloseIdleConnections()
+	c.httpClient().CloseIdleConnections()
 	return nil
 }
 
 func (c *Client) request(ctx context.Context, method string, path string, requestBody interface{}, responseBody interface{}) error {
+	if c.dryRun && isChange(method, path) {
+		c.logDryRun(method, path, requestBody)
+		return nil
+	}
+
+	err := c.send(ctx, method, path, requestBody, responseBody, c.setAuthorization)
+	if c.token != nil && errors.Is(err, &HttpError{status: http.StatusUnauthorized}) {
+		// The token may have been revoked or expired early, so authorise again and retry the request once
+		c.log.Debug("token rejected, re-authorising", "method", method, "path", path)
+		c.resetToken()
+		err = c.send(ctx, method, path, requestBody, responseBody, c.setAuthorization)
+	}
+
+	return err
+}
+
+// send performs a single request against the cluster API. The authorise function, if provided, adds the credentials
+// to the request.
+func (c *Client) send(ctx context.Context, method string, path string, requestBody interface{}, responseBody interface{}, authorise func(ctx context.Context, req *http.Request) error) (err error) {
 	url := fmt.Sprintf("%s%s", c.url, path)
 
+	status := 0
+	ctx, span := startRequestSpan(ctx, method, path)
+	defer func() { endRequestSpan(span, status, err) }()
+
 	requestBodyReader := &bytes.Buffer{}
 	if requestBody != nil {
 		if err := json.NewEncoder(requestBodyReader).Encode(requestBody); err != nil {
@@ -68,22 +147,30 @@ func (c *Client) request(ctx context.Context, method string, path string, reques
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, requestBodyReader)
-	req.Close = true
 	if err != nil {
 		return fmt.Errorf("unable to perform request %s %s: %w", method, path, err)
 	}
-	req.SetBasicAuth(c.username, c.password)
+	if authorise != nil {
+		if err := authorise(ctx, req); err != nil {
+			return err
+		}
+	}
 	req.Header.Set("Accept", "application/json")
 	if requestBody != nil {
 		req.Header.Set("Content-Type", "application/json;charset=utf-8")
 	}
+	injectTraceContext(ctx, req)
 
-	res, err := c.client.Do(req)
+	start := time.Now()
+	res, err := c.httpClient().Do(req)
 	if err != nil {
+		recordRequest(method, path, 0, start)
 		return fmt.Errorf("unable to perform request %s %s: %w", method, path, err)
 	}
 
 	defer exhaustCloseWithLogOnError(c.log, res.Body)
+	defer recordRequest(method, path, res.StatusCode, start)
+	status = res.StatusCode
 
 	if res.StatusCode != http.StatusOK {
 		body, err := ioutil.ReadAll(res.Body)
@@ -1,12 +1,18 @@
 package sdk
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -68,3 +74,57 @@ type mockSink struct {
 func (m *mockSink) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
 	m.Called(name, level, msg, args)
 }
+
+func TestClient_SetConnectionPool(t *testing.T) {
+	subject := NewClient(hclog.NewNullLogger())
+
+	previous := subject.httpClient()
+	subject.SetConnectionPool(5, 15*time.Second)
+
+	// A new pool is swapped in, rather than the one requests may be using being changed
+	transport := subject.httpClient().Transport.(*http.Transport)
+	assert.NotSame(t, previous, subject.httpClient())
+	assert.Equal(t, 5, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 15*time.Second, transport.IdleConnTimeout)
+
+	// Removing the settings restores the defaults
+	subject.SetConnectionPool(0, 0)
+
+	transport = subject.httpClient().Transport.(*http.Transport)
+	assert.Equal(t, defaultMaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+}
+
+// BenchmarkClient_request compares reusing connections to the cluster API with opening a new TLS connection for every
+// request, which is what the client used to do.
+func BenchmarkClient_request(b *testing.B) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"name":"cluster"}`)
+	}))
+	b.Cleanup(server.Close)
+
+	for _, bm := range []struct {
+		name      string
+		keepAlive bool
+	}{
+		{name: "keep-alive", keepAlive: true},
+		{name: "connection-per-request", keepAlive: false},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			subject := NewClient(hclog.NewNullLogger())
+			subject.Initialise(server.URL, "user", "pass")
+			subject.httpClient().Transport.(*http.Transport).DisableKeepAlives = !bm.keepAlive
+			b.Cleanup(func() {
+				_ = subject.Close()
+			})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := subject.GetCluster(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ParseSubject parses the subject of a certificate in the string form of RFC 2253, e.g. "CN=billing,O=Example", as
+// passed to a database plugin by Vault. Attributes which Redis Enterprise cannot validate are rejected, rather than
+// authorizing every certificate which differs only in those.
+func ParseSubject(line string) (Subject, error) {
+	var subject Subject
+
+	for _, attribute := range splitSubject(line) {
+		// Separators are often followed by a space, as in the form Redis Enterprise documents
+		name, value, ok := strings.Cut(strings.TrimLeft(attribute, " "), "=")
+		if !ok {
+			return Subject{}, fmt.Errorf("invalid attribute '%s' in subject '%s'", attribute, line)
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+
+		value, err := unescapeSubjectValue(value)
+		if err != nil {
+			return Subject{}, fmt.Errorf("invalid value of %s in subject '%s': %w", name, line, err)
+		}
+
+		var single *string
+		switch name {
+		case "CN":
+			single = &subject.CN
+		case "O":
+			single = &subject.O
+		case "L":
+			single = &subject.L
+		case "ST":
+			single = &subject.ST
+		case "C":
+			single = &subject.C
+		case "OU":
+			subject.OU = append(subject.OU, value)
+			continue
+		default:
+			return Subject{}, fmt.Errorf("attribute %s of subject '%s' cannot be validated by Redis Enterprise", name, line)
+		}
+
+		if *single != "" {
+			return Subject{}, fmt.Errorf("attribute %s appears more than once in subject '%s'", name, line)
+		}
+		*single = value
+	}
+
+	if subject.CN == "" {
+		return Subject{}, fmt.Errorf("subject '%s' has no CN", line)
+	}
+
+	return subject, nil
+}
+
+// Equal returns whether the subjects have the same attributes, with the OUs in the same order.
+func (s Subject) Equal(other Subject) bool {
+	if s.CN != other.CN || s.O != other.O || s.L != other.L || s.ST != other.ST || s.C != other.C || len(s.OU) != len(other.OU) {
+		return false
+	}
+	for i := range s.OU {
+		if s.OU[i] != other.OU[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitSubject splits the subject into its attributes at the commas and plus signs which are not escaped. Attributes
+// of multi-valued RDNs, joined with plus signs, are treated the same as any other.
+func splitSubject(line string) []string {
+	var attributes []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ',', '+':
+			attributes = append(attributes, line[start:i])
+			start = i + 1
+		}
+	}
+	return append(attributes, line[start:])
+}
+
+// unescapeSubjectValue removes the escaping of special characters, and of bytes as two hex digits, from the value.
+func unescapeSubjectValue(value string) (string, error) {
+	if strings.HasPrefix(value, "#") {
+		return "", fmt.Errorf("values encoded as hex strings are not supported")
+	}
+
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			result.WriteByte(value[i])
+			continue
+		}
+		if i+1 >= len(value) {
+			return "", fmt.Errorf("'%s' ends with an escape", value)
+		}
+		if i+2 < len(value) {
+			if b, err := hex.DecodeString(value[i+1 : i+3]); err == nil {
+				result.Write(b)
+				i += 2
+				continue
+			}
+		}
+		result.WriteByte(value[i+1])
+		i++
+	}
+	return result.String(), nil
+}
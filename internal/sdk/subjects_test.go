@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubject(t *testing.T) {
+	for _, spec := range []struct {
+		line     string
+		expected Subject
+	}{
+		{line: "CN=billing", expected: Subject{CN: "billing"}},
+		{line: "CN=billing,OU=Payments,OU=Europe,O=Example,L=London,ST=England,C=GB", expected: Subject{CN: "billing", OU: []string{"Payments", "Europe"}, O: "Example", L: "London", ST: "England", C: "GB"}},
+		{line: "CN=billing, O=Example", expected: Subject{CN: "billing", O: "Example"}},
+		{line: "cn=billing+o=Example", expected: Subject{CN: "billing", O: "Example"}},
+		{line: `CN=billing\, payments,O=Example\+Co`, expected: Subject{CN: "billing, payments", O: "Example+Co"}},
+		{line: `CN=caf\C3\A9`, expected: Subject{CN: "café"}},
+	} {
+		t.Run(spec.line, func(t *testing.T) {
+			subject, err := ParseSubject(spec.line)
+			require.NoError(t, err)
+			assert.Equal(t, spec.expected, subject)
+		})
+	}
+}
+
+func TestParseSubject_invalid(t *testing.T) {
+	for _, spec := range []struct {
+		line    string
+		message string
+	}{
+		{line: "", message: "invalid attribute"},
+		{line: "O=Example", message: "has no CN"},
+		{line: "CN=billing,SERIALNUMBER=1234", message: "attribute SERIALNUMBER"},
+		{line: "CN=billing,CN=payments", message: "more than once"},
+		{line: "CN=#0403616263", message: "hex strings"},
+		{line: `CN=billing\`, message: "ends with an escape"},
+	} {
+		t.Run(spec.line, func(t *testing.T) {
+			_, err := ParseSubject(spec.line)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), spec.message)
+		})
+	}
+}
+
+// The subjects Vault passes to plugins are formatted by the standard library
+func TestParseSubject_pkixName(t *testing.T) {
+	name := pkix.Name{
+		CommonName:         "billing, \"payments\"",
+		OrganizationalUnit: []string{"Payments"},
+		Organization:       []string{"Example"},
+		Country:            []string{"GB"},
+	}
+
+	subject, err := ParseSubject(name.String())
+	require.NoError(t, err)
+	assert.Equal(t, Subject{CN: "billing, \"payments\"", OU: []string{"Payments"}, O: "Example", C: "GB"}, subject)
+}
+
+func TestSubject_Equal(t *testing.T) {
+	subject := Subject{CN: "billing", OU: []string{"Payments", "Europe"}, O: "Example"}
+
+	assert.True(t, subject.Equal(Subject{CN: "billing", OU: []string{"Payments", "Europe"}, O: "Example"}))
+	assert.False(t, subject.Equal(Subject{CN: "billing", OU: []string{"Europe", "Payments"}, O: "Example"}))
+	assert.False(t, subject.Equal(Subject{CN: "billing", OU: []string{"Payments"}, O: "Example"}))
+	assert.False(t, subject.Equal(Subject{CN: "billing", OU: []string{"Payments", "Europe"}}))
+}
@@ -0,0 +1,55 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of the requests to the cluster API. The tracer is looked up for each request, as the
+// global tracer provider may be replaced after the package is loaded.
+const tracerName = "github.com/RedisLabs/vault-plugin-database-redisenterprise/internal/sdk"
+
+type retryAttemptKey struct{}
+
+// withRetryAttempt records which attempt of a retried request is being made, so it can be added to the span.
+func withRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryAttemptKey{}, attempt)
+}
+
+// startRequestSpan starts a client span for a request against the cluster API.
+func startRequestSpan(ctx context.Context, method string, path string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.URLPath(path),
+		semconv.HTTPRoute(pathTemplate(path)),
+	}
+	if attempt, ok := ctx.Value(retryAttemptKey{}).(int); ok {
+		attributes = append(attributes, attribute.Int("http.resend_count", attempt))
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, method+" "+pathTemplate(path), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// injectTraceContext adds the traceparent header, so the request can be correlated by the cluster.
+func injectTraceContext(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// endRequestSpan ends the span, recording the status of the response or the error if the request failed.
+func endRequestSpan(span trace.Span, status int, err error) {
+	if status != 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClient_UpdateDatabaseRolePermissions_recordsSpans(t *testing.T) {
+	recorder := spanRecorder(t)
+
+	cluster, subject := fakeCluster(t)
+	dbUID := cluster.AddDatabase("db")
+	cluster.InjectConflicts(dbUID, 1)
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	_, _, err := subject.UpdateDatabaseRolePermissions(ctx, dbUID, func(permissions []RolePermission) []RolePermission {
+		return permissions
+	})
+	parent.End()
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5)
+
+	// Every attempt reads the database before updating it
+	for i, span := range spans[:4] {
+		attempt := i / 2
+		if i%2 == 0 {
+			assert.Equal(t, "GET /v1/bdbs/:uid", span.Name())
+		} else {
+			assert.Equal(t, "PUT /v1/bdbs/:uid", span.Name())
+		}
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, span.Attributes(), attribute.Int("http.resend_count", attempt))
+	}
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("http.response.status_code", http.StatusConflict))
+	assert.Contains(t, spans[3].Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+}
+
+func TestClient_request_propagatesTraceContext(t *testing.T) {
+	recorder := spanRecorder(t)
+
+	var traceparent string
+	url := testServer(t, "/v1/bdbs/3", http.MethodGet, "expected", "Password", func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{"uid": 3}`))
+	})
+
+	subject := &Client{
+		url:      url,
+		username: "expected",
+		password: "Password",
+		client:   http.DefaultClient,
+	}
+
+	_, err := subject.GetDatabase(context.Background(), 3)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, traceparent, spans[0].SpanContext().SpanID().String())
+}
+
+// spanRecorder replaces the global tracer provider with one recording the spans for the duration of the test.
+func spanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return recorder
+}
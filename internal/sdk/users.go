@@ -40,6 +40,16 @@ func (c *Client) UpdateUserPassword(ctx context.Context, id int, update UpdateUs
 	return nil
 }
 
+// RevokeUser removes every role of the user, so it can no longer access anything, renames it, and replaces its password
+// unless the password is empty, such as for a user authenticating with a client certificate.
+func (c *Client) RevokeUser(ctx context.Context, id int, name string, password string) error {
+	if err := c.request(ctx, http.MethodPut, fmt.Sprintf("/v1/users/%d", id), RevokeUser{Name: name, Roles: []int{}, Password: password}, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) DeleteUser(ctx context.Context, id int) error {
 	if err := c.request(ctx, http.MethodDelete, fmt.Sprintf("/v1/users/%d", id), nil, nil); err != nil {
 		return err
@@ -56,3 +56,43 @@ func TestClient_FindUserByName_fallBackToEmail(t *testing.T) {
 
 	assert.Equal(t, expectedId, actual.UID)
 }
+
+func TestClient_CreateUser_rejectsDuplicateName(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	role := cluster.AddRole("role", "db_member")
+	cluster.AddUser("existing", "", "Password", "", role)
+
+	_, err := subject.CreateUser(context.Background(), CreateUser{
+		Name:       "existing",
+		Password:   "Password",
+		Roles:      []int{role},
+		AuthMethod: "regular",
+	})
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusConflict})
+}
+
+func TestClient_UpdateUserPassword_injectedError(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	uid := cluster.AddUser("user", "", "Password", "db_member")
+	cluster.InjectError(http.MethodPut, fmt.Sprintf("/v1/users/%d", uid), http.StatusServiceUnavailable, 1)
+
+	ctx := context.Background()
+	err := subject.UpdateUserPassword(ctx, uid, UpdateUser{Password: "new"})
+	assert.ErrorIs(t, err, &HttpError{status: http.StatusServiceUnavailable})
+
+	require.NoError(t, subject.UpdateUserPassword(ctx, uid, UpdateUser{Password: "new"}))
+}
+
+func TestClient_RevokeUser(t *testing.T) {
+	cluster, subject := fakeCluster(t)
+	role := cluster.AddRole("role", "db_member")
+	uid := cluster.AddUser("user", "", "Password", "", role)
+
+	require.NoError(t, subject.RevokeUser(context.Background(), uid, "user_revoked", "Replaced"))
+
+	user, err := subject.GetUser(context.Background(), uid)
+	require.NoError(t, err)
+	assert.Equal(t, "user_revoked", user.Name)
+	assert.Empty(t, user.Roles)
+	assert.Equal(t, "Replaced", cluster.Users()[1].Password)
+}